@@ -0,0 +1,98 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/plugins/importer"
+	"rina.icu/hoshino/store"
+)
+
+var (
+	challengeCmd = &cobra.Command{
+		Use:   "challenge",
+		Short: "Manage the challenges of Hoshino",
+	}
+
+	challengeImportCmd = &cobra.Command{
+		Use:   "import <dir>",
+		Short: "Create or update the challenges from the challenge.yml files in the directory",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			gameUUID, _ := cmd.Flags().GetString("game")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+			config := loadConfig()
+			s, err := store.GetStore(config)
+			if err != nil {
+				return err
+			}
+
+			game, err := s.GetGameByUUID(gameUUID)
+			if err != nil {
+				return fmt.Errorf("unable to fetch game %s: %w", gameUUID, err)
+			}
+
+			i := importer.NewImporter(s, game, config.DataDir)
+			i.DryRun = dryRun
+
+			results, err := i.Import(args[0])
+			engine := scoreboard.NewEngine(s)
+			reload := false
+			for _, result := range results {
+				if result.Rescore && !dryRun {
+					// persist the recalculated scores of the solves
+					if challenge, err := s.GetChallengeByUUID(result.UUID); err == nil {
+						engine.Rescore(game, challenge)
+					}
+				}
+
+				fmt.Printf("%s (%s)\n", result.Name, result.UUID)
+				if len(result.Changes) == 0 {
+					fmt.Println("  no changes")
+				}
+				for _, change := range result.Changes {
+					fmt.Printf("  %s\n", change)
+				}
+				for _, warning := range result.Warnings {
+					fmt.Printf("  warning: %s\n", warning)
+				}
+				reload = reload || result.Rescore || result.Refund
+			}
+			if err != nil {
+				return err
+			}
+
+			if dryRun {
+				fmt.Println("Dry run, nothing has been changed.")
+			} else if reload {
+				fmt.Println("Scores have changed, restart the server to reload the scoreboard.")
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	challengeImportCmd.Flags().String("game", "", "UUID of the game")
+	challengeImportCmd.Flags().Bool("dry-run", false, "print the changes without applying them")
+	challengeImportCmd.MarkFlagRequired("game")
+
+	challengeCmd.AddCommand(challengeImportCmd)
+	cmd.AddCommand(challengeCmd)
+}
@@ -0,0 +1,131 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/plugins/importer"
+	"rina.icu/hoshino/store"
+)
+
+var (
+	exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export the data of Hoshino",
+	}
+
+	exportScoreboardCmd = &cobra.Command{
+		Use:   "scoreboard",
+		Short: "Export the final standings of a game in CTFtime format",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			gameUUID, _ := cmd.Flags().GetString("game")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+
+			s, err := store.GetStore(loadConfig())
+			if err != nil {
+				return err
+			}
+
+			game, err := s.GetGameByUUID(gameUUID)
+			if err != nil {
+				return fmt.Errorf("unable to fetch game %s: %w", gameUUID, err)
+			}
+
+			sb := scoreboard.NewEngine(s).Scoreboard(game, false)
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			switch format {
+			case "ctftime":
+				encoder := json.NewEncoder(w)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(sb.CTFtime())
+			case "csv":
+				return sb.WriteCSV(w)
+			default:
+				return fmt.Errorf("unsupported format %s", format)
+			}
+		},
+	}
+
+	exportGameCmd = &cobra.Command{
+		Use:   "game",
+		Short: "Export a game with its challenges and attachments to an archive",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			gameUUID, _ := cmd.Flags().GetString("game")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+
+			s, err := store.GetStore(loadConfig())
+			if err != nil {
+				return err
+			}
+
+			game, err := s.GetGameByUUID(gameUUID)
+			if err != nil {
+				return fmt.Errorf("unable to fetch game %s: %w", gameUUID, err)
+			}
+
+			if output == "" {
+				output = fmt.Sprintf("%s.%s", game.UUID, format)
+			}
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+
+			err = importer.ExportGame(s, game, f, format)
+			f.Close()
+			if err != nil {
+				os.Remove(output)
+				return err
+			}
+
+			fmt.Printf("Game %s has been exported to %s\n", game.Name, output)
+			return nil
+		},
+	}
+)
+
+func init() {
+	exportScoreboardCmd.Flags().String("game", "", "UUID of the game")
+	exportScoreboardCmd.Flags().String("format", "ctftime", "output format, ctftime or csv")
+	exportScoreboardCmd.Flags().StringP("output", "o", "", "output file, defaults to stdout")
+	exportScoreboardCmd.MarkFlagRequired("game")
+
+	exportGameCmd.Flags().String("game", "", "UUID of the game")
+	exportGameCmd.Flags().String("format", "zip", "archive format, zip or tar.gz")
+	exportGameCmd.Flags().StringP("output", "o", "", "output file, defaults to <uuid>.<format>")
+	exportGameCmd.MarkFlagRequired("game")
+
+	exportCmd.AddCommand(exportScoreboardCmd)
+	exportCmd.AddCommand(exportGameCmd)
+	cmd.AddCommand(exportCmd)
+}
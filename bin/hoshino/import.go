@@ -0,0 +1,171 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/plugins/importer"
+	"rina.icu/hoshino/store"
+)
+
+var (
+	importCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Import the data into Hoshino",
+	}
+
+	importCTFdCmd = &cobra.Command{
+		Use:   "ctfd <archive>",
+		Short: "Create a game from a CTFd export",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, _ := cmd.Flags().GetString("name")
+			username, _ := cmd.Flags().GetString("user")
+
+			config := loadConfig()
+			s, err := store.GetStore(config)
+			if err != nil {
+				return err
+			}
+
+			user, err := importUser(s, username)
+			if err != nil {
+				return err
+			}
+
+			dir, cleanup, err := extractArchive(args[0])
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			result, err := importer.ImportCTFd(s, dir, config.DataDir, user, name)
+			if err != nil {
+				return err
+			}
+
+			// persist the scores of the imported solves
+			game, err := s.GetGameByUUID(result.UUID)
+			if err != nil {
+				return err
+			}
+			engine := scoreboard.NewEngine(s)
+			for _, challenge := range game.Challenges {
+				engine.Rescore(game, challenge)
+			}
+
+			fmt.Printf("Game %s (%s) has been created with %d challenges, %d teams and %d solves as a hidden draft.\n",
+				result.Name, result.UUID, result.Challenges, result.Teams, result.Solves)
+			for _, unmapped := range result.Unmapped {
+				fmt.Printf("  unmapped: %s\n", unmapped)
+			}
+			return nil
+		},
+	}
+
+	importGameCmd = &cobra.Command{
+		Use:   "game <archive>",
+		Short: "Create a game from an archive exported by Hoshino",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, _ := cmd.Flags().GetString("name")
+			username, _ := cmd.Flags().GetString("user")
+
+			config := loadConfig()
+			s, err := store.GetStore(config)
+			if err != nil {
+				return err
+			}
+
+			user, err := importUser(s, username)
+			if err != nil {
+				return err
+			}
+
+			dir, cleanup, err := extractArchive(args[0])
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+
+			result, err := importer.ImportGame(s, dir, config.DataDir, user, name)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Game %s (%s) has been created with %d challenges as a hidden draft.\n", result.Name, result.UUID, result.Challenges)
+			for _, username := range result.Unmapped {
+				fmt.Printf("  warning: user %s is not found\n", username)
+			}
+			for _, warning := range result.Warnings {
+				fmt.Printf("  warning: %s\n", warning)
+			}
+			return nil
+		},
+	}
+)
+
+// importUser returns the user by the username, nil if the username is empty
+func importUser(s *store.Store, username string) (*store.User, error) {
+	if username == "" {
+		return nil, nil
+	}
+	user, err := s.GetUserByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch user %s: %w", username, err)
+	}
+	return user, nil
+}
+
+// extractArchive extracts the archive into a temporary directory, the directories are used as they are
+func extractArchive(archive string) (string, func(), error) {
+	info, err := os.Stat(archive)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		return archive, func() {}, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "hoshino-import-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+
+	dir := filepath.Join(tmp, "content")
+	if err := importer.Extract(archive, dir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dir, cleanup, nil
+}
+
+func init() {
+	importGameCmd.Flags().String("name", "", "name of the new game, defaults to the name in the archive")
+	importGameCmd.Flags().String("user", "", "username of the user replacing the users not found")
+
+	importCTFdCmd.Flags().String("name", "", "name of the new game, defaults to the name of the CTF")
+	importCTFdCmd.Flags().String("user", "", "username of the creator of the game")
+
+	importCmd.AddCommand(importGameCmd)
+	importCmd.AddCommand(importCTFdCmd)
+	cmd.AddCommand(importCmd)
+}
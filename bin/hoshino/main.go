@@ -51,36 +51,7 @@ var (
 		Use:   "hoshino",
 		Short: "Hoshino is a lightweight CTF platform designed for team internal training.",
 		Run: func(_ *cobra.Command, _ []string) {
-			instanceConfig := &config.Config{
-				Mode:       viper.GetString("mode"),
-				Address:    viper.GetString("address"),
-				Port:       viper.GetInt("port"),
-				DataDir:    viper.GetString("data_dir"),
-				DSN:        viper.GetString("dsn"),
-				Driver:     viper.GetString("driver"),
-				Secret:     viper.GetString("secret"),
-				Kubeconfig: viper.GetString("kube_config"),
-				SMTP: func() config.SMTP {
-					var smtp config.SMTP
-					if err := viper.UnmarshalKey("smtp", &smtp); err != nil {
-						panic(err)
-					}
-					return smtp
-				}(),
-				CORS: func() config.CORS {
-					var cors config.CORS
-					if err := viper.UnmarshalKey("cors", &cors); err != nil {
-						panic(err)
-					}
-					return cors
-				}(),
-
-				Version: version.Version,
-			}
-
-			if err := instanceConfig.Validate(); err != nil {
-				panic(err)
-			}
+			instanceConfig := loadConfig()
 
 			printGreetings()
 			slog.Info(fmt.Sprintf("Hoshino v%s", version.Version))
@@ -168,6 +139,42 @@ func init() {
 	}
 }
 
+// loadConfig builds the instance config from the config file
+func loadConfig() *config.Config {
+	instanceConfig := &config.Config{
+		Mode:       viper.GetString("mode"),
+		Address:    viper.GetString("address"),
+		Port:       viper.GetInt("port"),
+		DataDir:    viper.GetString("data_dir"),
+		DSN:        viper.GetString("dsn"),
+		Driver:     viper.GetString("driver"),
+		Secret:     viper.GetString("secret"),
+		Kubeconfig: viper.GetString("kube_config"),
+		SMTP: func() config.SMTP {
+			var smtp config.SMTP
+			if err := viper.UnmarshalKey("smtp", &smtp); err != nil {
+				panic(err)
+			}
+			return smtp
+		}(),
+		CORS: func() config.CORS {
+			var cors config.CORS
+			if err := viper.UnmarshalKey("cors", &cors); err != nil {
+				panic(err)
+			}
+			return cors
+		}(),
+
+		Version: version.Version,
+	}
+
+	if err := instanceConfig.Validate(); err != nil {
+		panic(err)
+	}
+
+	return instanceConfig
+}
+
 func printGreetings() {
 	print(banner)
 
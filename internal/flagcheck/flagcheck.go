@@ -0,0 +1,56 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flagcheck checks the flag settings of the challenges before they are saved.
+package flagcheck
+
+import (
+	"errors"
+
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/store"
+)
+
+// Validate checks the flag format of the challenge in its match mode,
+// the format of a dynamic flag is a template which can't be a pattern,
+// a stateless one should be keyed by the flag secret,
+// and the team flags are delivered only without containers
+func Validate(challenge *store.Challenge) error {
+	switch challenge.TeamFlag {
+	case store.TeamFlagNone:
+	case store.TeamFlagDescription, store.TeamFlagAttachment:
+		if !challenge.NoContainer || !challenge.DynamicFlag {
+			return errors.New("team flags are for containerless challenges with dynamic flags")
+		}
+	default:
+		return errors.New("unknown team flag delivery")
+	}
+	if challenge.StatelessFlag {
+		if !challenge.DynamicFlag {
+			return errors.New("stateless flags should be dynamic")
+		}
+		if err := util.ValidateStatelessFlagTemplate(challenge.FlagFormat); err != nil {
+			return err
+		}
+	}
+	if challenge.DynamicFlag {
+		if challenge.FlagMatch == store.FlagMatchRegex {
+			return errors.New("dynamic flags can't be matched by regex")
+		}
+		if err := util.ValidateFlagTemplate(challenge.FlagFormat); err != nil {
+			return err
+		}
+	}
+	return challenge.FlagMatch.Validate(challenge.FlagFormat)
+}
@@ -0,0 +1,40 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flagcheck
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/store"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(&store.Challenge{FlagFormat: "flag{static}"}))
+	assert.NoError(t, Validate(&store.Challenge{FlagFormat: `flag\{[0-9]+\}`, FlagMatch: store.FlagMatchRegex}))
+	assert.Error(t, Validate(&store.Challenge{FlagFormat: "flag{(", FlagMatch: store.FlagMatchRegex}))
+
+	assert.NoError(t, Validate(&store.Challenge{FlagFormat: "{hex:16}", DynamicFlag: true}))
+	assert.Error(t, Validate(&store.Challenge{FlagFormat: "{hex:100}", DynamicFlag: true}))
+	assert.Error(t, Validate(&store.Challenge{FlagFormat: "{hex:16}", DynamicFlag: true, FlagMatch: store.FlagMatchRegex}))
+
+	assert.NoError(t, Validate(&store.Challenge{FlagFormat: "{hmac:16}", DynamicFlag: true, StatelessFlag: true}))
+	assert.Error(t, Validate(&store.Challenge{FlagFormat: "{rand:16}", DynamicFlag: true, StatelessFlag: true}))
+	assert.Error(t, Validate(&store.Challenge{FlagFormat: "{hmac:16}", StatelessFlag: true}))
+
+	assert.NoError(t, Validate(&store.Challenge{FlagFormat: "{hex:16}", DynamicFlag: true, NoContainer: true, TeamFlag: store.TeamFlagAttachment}))
+	assert.Error(t, Validate(&store.Challenge{FlagFormat: "{hex:16}", DynamicFlag: true, TeamFlag: store.TeamFlagAttachment}))
+	assert.Error(t, Validate(&store.Challenge{FlagFormat: "flag", TeamFlag: store.TeamFlagDelivery(42)}))
+}
@@ -0,0 +1,51 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hub
+
+import (
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/store"
+)
+
+// PublishGameEvent pushes the game event, the hidden events are only pushed to the managers
+func (h *Hub) PublishGameEvent(event *store.GameEvent) {
+	audience := AudienceAll
+	if !event.Visibility {
+		audience = AudienceManagers
+	}
+
+	typ := MessageTypeEvent
+	if event.Type == store.GameEventTypeAnnouncement {
+		typ = MessageTypeAnnouncement
+	}
+
+	h.Publish(event.GameID, audience, typ, event.Brief())
+}
+
+// PublishScoreboard pushes the scoreboard deltas of the game,
+// the players only get the frozen deltas while the scoreboard has a freeze
+func (h *Hub) PublishScoreboard(gameID uint, live []*scoreboard.Delta, frozen []*scoreboard.Delta) {
+	if frozen == nil {
+		h.Publish(gameID, AudienceAll, MessageTypeScoreboard, live)
+		return
+	}
+
+	if len(live) > 0 {
+		h.Publish(gameID, AudienceManagers, MessageTypeScoreboard, live)
+	}
+	if len(frozen) > 0 {
+		h.Publish(gameID, AudiencePlayers, MessageTypeScoreboard, frozen)
+	}
+}
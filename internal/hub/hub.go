@@ -0,0 +1,195 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hub
+
+import (
+	"sync"
+	"time"
+)
+
+// Audience of a message
+type Audience int
+
+const (
+	AudienceAll Audience = iota
+	AudiencePlayers
+	AudienceManagers
+)
+
+const (
+	MessageTypeEvent        = "event"
+	MessageTypeAnnouncement = "announcement"
+	MessageTypeScoreboard   = "scoreboard"
+
+	// keep-alive of the WebSocket streams
+	MessageTypePing = "ping"
+
+	// sent when the messages after the last received one are no longer retained,
+	// the client should fetch the full state again
+	MessageTypeReset = "reset"
+)
+
+// Message pushed to the subscribers of a game
+type Message struct {
+	// ID of the message, increasing in the hub
+	ID   uint64 `json:"id"`
+	Type string `json:"type"`
+	Data any    `json:"data"`
+
+	game     uint
+	audience Audience
+}
+
+// Subscriber of the messages of a game
+type Subscriber struct {
+	// C receives the messages, it's closed when the subscriber is removed
+	// or falls too far behind, the client should reconnect and resume then
+	C <-chan *Message
+
+	c       chan *Message
+	game    uint
+	manager bool
+}
+
+func (sub *Subscriber) accepts(m *Message) bool {
+	switch m.audience {
+	case AudiencePlayers:
+		return !sub.manager
+	case AudienceManagers:
+		return sub.manager
+	default:
+		return true
+	}
+}
+
+// Hub is an in-process pub/sub of the game messages,
+// it retains the latest messages of every game for the clients to resume
+type Hub struct {
+	lock sync.Mutex
+
+	seq  uint64
+	size int
+
+	// first ID of the hub, older IDs are from the previous runs
+	start uint64
+
+	history map[uint][]*Message
+
+	// latest ID of the messages dropped from the history of each game
+	dropped map[uint]uint64
+
+	subscribers map[uint]map[*Subscriber]struct{}
+}
+
+// New creates a hub retaining at most size messages per game
+func New(size int) *Hub {
+	// start from the current time, so the IDs stay increasing across restarts
+	start := uint64(time.Now().UnixMicro())
+
+	return &Hub{
+		seq:         start,
+		size:        size,
+		start:       start,
+		history:     make(map[uint][]*Message),
+		dropped:     make(map[uint]uint64),
+		subscribers: make(map[uint]map[*Subscriber]struct{}),
+	}
+}
+
+// Publish pushes the message to the subscribers of the game,
+// subscribers that cannot keep up are dropped
+func (h *Hub) Publish(game uint, audience Audience, typ string, data any) *Message {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.seq++
+	m := &Message{ID: h.seq, Type: typ, Data: data, game: game, audience: audience}
+
+	history := append(h.history[game], m)
+	if len(history) > h.size {
+		h.dropped[game] = history[len(history)-h.size-1].ID
+		history = history[len(history)-h.size:]
+	}
+	h.history[game] = history
+
+	for sub := range h.subscribers[game] {
+		if !sub.accepts(m) {
+			continue
+		}
+
+		select {
+		case sub.c <- m:
+		default:
+			h.remove(sub)
+		}
+	}
+
+	return m
+}
+
+// Subscribe subscribes to the messages of the game, the retained messages after
+// the last received ID are replayed first, 0 means not resuming
+func (h *Hub) Subscribe(game uint, manager bool, last uint64) *Subscriber {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	backlog := []*Message{}
+	if last != 0 {
+		if last < h.start || last < h.dropped[game] {
+			backlog = append(backlog, &Message{ID: last, Type: MessageTypeReset})
+		}
+		for _, m := range h.history[game] {
+			if m.ID > last {
+				backlog = append(backlog, m)
+			}
+		}
+	}
+
+	c := make(chan *Message, h.size+len(backlog))
+	sub := &Subscriber{C: c, c: c, game: game, manager: manager}
+	for _, m := range backlog {
+		if m.Type == MessageTypeReset || sub.accepts(m) {
+			c <- m
+		}
+	}
+
+	if h.subscribers[game] == nil {
+		h.subscribers[game] = make(map[*Subscriber]struct{})
+	}
+	h.subscribers[game][sub] = struct{}{}
+
+	return sub
+}
+
+// Unsubscribe removes the subscriber and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.remove(sub)
+}
+
+func (h *Hub) remove(sub *Subscriber) {
+	subs := h.subscribers[sub.game]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.game)
+	}
+	close(sub.c)
+}
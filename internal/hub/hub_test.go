@@ -0,0 +1,104 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(sub *Subscriber) []*Message {
+	messages := []*Message{}
+	for {
+		select {
+		case m, ok := <-sub.C:
+			if !ok {
+				return messages
+			}
+			messages = append(messages, m)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestPublish(t *testing.T) {
+	h := New(16)
+
+	player := h.Subscribe(1, false, 0)
+	manager := h.Subscribe(1, true, 0)
+	other := h.Subscribe(2, false, 0)
+
+	h.Publish(1, AudienceAll, MessageTypeEvent, "solved")
+	h.Publish(1, AudienceManagers, MessageTypeEvent, "cheated")
+	h.Publish(1, AudiencePlayers, MessageTypeScoreboard, "frozen")
+
+	messages := receive(player)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "solved", messages[0].Data)
+	assert.Equal(t, "frozen", messages[1].Data)
+	assert.Less(t, messages[0].ID, messages[1].ID)
+
+	messages = receive(manager)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "cheated", messages[1].Data)
+
+	assert.Empty(t, receive(other))
+
+	h.Unsubscribe(player)
+	_, ok := <-player.C
+	assert.False(t, ok)
+
+	// unsubscribing twice is fine
+	h.Unsubscribe(player)
+}
+
+func TestResume(t *testing.T) {
+	h := New(2)
+
+	first := h.Publish(1, AudienceAll, MessageTypeEvent, 1)
+	second := h.Publish(1, AudienceAll, MessageTypeEvent, 2)
+	h.Publish(1, AudienceAll, MessageTypeEvent, 3)
+	h.Publish(1, AudienceAll, MessageTypeEvent, 4)
+
+	// the messages after the second one are still retained
+	messages := receive(h.Subscribe(1, false, second.ID))
+	assert.Len(t, messages, 2)
+	assert.Equal(t, 3, messages[0].Data)
+
+	// the second message has been dropped
+	messages = receive(h.Subscribe(1, false, first.ID))
+	assert.Len(t, messages, 3)
+	assert.Equal(t, MessageTypeReset, messages[0].Type)
+
+	// IDs of the previous runs
+	messages = receive(h.Subscribe(1, false, 1))
+	assert.Equal(t, MessageTypeReset, messages[0].Type)
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	h := New(2)
+
+	sub := h.Subscribe(1, false, 0)
+	for i := 0; i < 3; i++ {
+		h.Publish(1, AudienceAll, MessageTypeEvent, i)
+	}
+
+	// the subscriber is dropped once its buffer is full
+	assert.Len(t, receive(sub), 2)
+	_, ok := <-sub.C
+	assert.False(t, ok)
+}
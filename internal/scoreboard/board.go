@@ -0,0 +1,826 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scoreboard
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+
+	"rina.icu/hoshino/store"
+)
+
+// solve is the in-memory state of a solved flag
+type solve struct {
+	flagID    uint
+	team      *team
+	challenge *challenge
+	solvedAt  int64
+	cheated   bool
+	score     int
+
+	// score counted in the frozen view, calculated with only the solves before the freeze
+	frozenScore int
+
+	// order of the solve in the challenge, 0 if the solve is not counted
+	order int
+}
+
+type team struct {
+	id   uint
+	uuid string
+	name string
+
+	// banned or not approved teams are not ranked
+	unranked bool
+
+	// solves of the team, keyed by the challenge ID
+	solves map[uint]*solve
+
+	// costs of the unlocked hints, keyed by the hint ID
+	penalties map[uint]*penalty
+
+	// found sub flags of the multi-part challenges, keyed by the challenge ID
+	parts map[uint][]*part
+}
+
+// penalty is the score deducted from a team for unlocking a hint
+type penalty struct {
+	cost       int
+	unlockedAt int64
+}
+
+// part is a sub flag found by a team, worth the weight in percent of the challenge value
+// until the team solves the challenge
+type part struct {
+	subFlagID uint
+	challenge *challenge
+	weight    int
+	solvedAt  int64
+}
+
+func (p *part) score(value int) int {
+	return value * p.weight / 100
+}
+
+type challenge struct {
+	challenge *store.Challenge
+	formula   *formula
+
+	// solves of the challenge, sorted by the solved time
+	solves []*solve
+
+	// score of the next solve, the value of the sub flags found by the teams
+	value int
+
+	// value of the challenge in the frozen view
+	frozenValue int
+
+	// teams which have found some sub flags of the challenge
+	partial map[uint]*team
+}
+
+// entry is the ranking entry of a team in a view
+type entry struct {
+	team  *team
+	score int
+	last  int64
+}
+
+// compareEntry sorts by the score descending,
+// ties are broken by the earlier last solve and then the team ID
+func compareEntry(a, b *entry) int {
+	if a.score != b.score {
+		return cmp.Compare(b.score, a.score)
+	}
+	if a.last != b.last {
+		return cmp.Compare(a.last, b.last)
+	}
+	return cmp.Compare(a.team.id, b.team.id)
+}
+
+// view is the ranking counting only the solves before the cutoff,
+// 0 means counting all the solves
+type view struct {
+	cutoff  int64
+	entries map[uint]*entry
+
+	// entries of the ranked teams, sorted by compareEntry
+	ranking []*entry
+
+	// lazily built snapshot, dropped when the ranking changes
+	snapshot *Scoreboard
+}
+
+func newView(cutoff int64) *view {
+	return &view{cutoff: cutoff, entries: make(map[uint]*entry)}
+}
+
+func (v *view) counts(s *solve) bool {
+	return s.order > 0 && v.before(s.solvedAt)
+}
+
+func (v *view) before(t int64) bool {
+	return v.cutoff == 0 || t < v.cutoff
+}
+
+// score returns the score of the solve counted in the view
+func (v *view) score(s *solve) int {
+	if v.cutoff != 0 {
+		return s.frozenScore
+	}
+	return s.score
+}
+
+// partScore returns the score of the found sub flag counted in the view
+func (v *view) partScore(p *part) int {
+	if v.cutoff != 0 {
+		return p.score(p.challenge.frozenValue)
+	}
+	return p.score(p.challenge.value)
+}
+
+func (v *view) remove(e *entry) {
+	if i, ok := slices.BinarySearchFunc(v.ranking, e, compareEntry); ok {
+		v.ranking = slices.Delete(v.ranking, i, i+1)
+	}
+}
+
+func (v *view) insert(e *entry) {
+	if e.team.unranked {
+		return
+	}
+	i, _ := slices.BinarySearchFunc(v.ranking, e, compareEntry)
+	v.ranking = slices.Insert(v.ranking, i, e)
+}
+
+// recount recalculates the score and the last solve of the entry from the solves
+func (v *view) recount(e *entry) {
+	e.score, e.last = 0, 0
+	for _, s := range e.team.solves {
+		if v.counts(s) {
+			e.score += v.score(s)
+			e.last = max(e.last, s.solvedAt)
+		}
+	}
+	for _, p := range e.team.penalties {
+		if v.before(p.unlockedAt) {
+			e.score -= p.cost
+		}
+	}
+	for _, p := range v.parts(e.team) {
+		e.score += v.partScore(p)
+		e.last = max(e.last, p.solvedAt)
+	}
+}
+
+// parts returns the found sub flags counted in the view,
+// which are of the challenges not solved in the view
+func (v *view) parts(t *team) []*part {
+	parts := []*part{}
+	for id, found := range t.parts {
+		if s, ok := t.solves[id]; ok && v.counts(s) {
+			continue
+		}
+		for _, p := range found {
+			if v.before(p.solvedAt) {
+				parts = append(parts, p)
+			}
+		}
+	}
+	return parts
+}
+
+// refresh recounts the given teams and moves them to their new positions,
+// the whole ranking is sorted again if too many teams are affected
+func (v *view) refresh(teams []*team) {
+	v.snapshot = nil
+
+	if len(teams) > len(v.ranking)/8 {
+		for _, t := range teams {
+			v.recount(v.entry(t))
+		}
+		v.ranking = v.ranking[:0]
+		for _, e := range v.entries {
+			if !e.team.unranked {
+				v.ranking = append(v.ranking, e)
+			}
+		}
+		slices.SortFunc(v.ranking, compareEntry)
+		return
+	}
+
+	for _, t := range teams {
+		e := v.entry(t)
+		v.remove(e)
+		v.recount(e)
+		v.insert(e)
+	}
+}
+
+// rank returns the score and the rank of the entry, rank is 0 if the team is not ranked
+func (v *view) rank(e *entry) (int, int) {
+	i, found := slices.BinarySearchFunc(v.ranking, e, compareEntry)
+	if !found {
+		return e.score, 0
+	}
+	return e.score, i + 1
+}
+
+func (v *view) entry(t *team) *entry {
+	e, ok := v.entries[t.id]
+	if !ok {
+		e = &entry{team: t}
+		v.entries[t.id] = e
+	}
+	return e
+}
+
+// Board is the in-memory scoreboard of a game
+type Board struct {
+	lock sync.Mutex
+
+	autoBan    bool
+	freezeTime int64
+	revealed   bool
+
+	// teams need to be approved to be ranked
+	requireApproval bool
+
+	// teams without an approved writeup are not ranked
+	writeupEnforced bool
+
+	teams      map[uint]*team
+	challenges map[uint]*challenge
+
+	live   *view
+	frozen *view
+
+	// called with the deltas of the live and the frozen view after a change
+	listener func(live []*Delta, frozen []*Delta)
+}
+
+func NewBoard(game *store.Game) *Board {
+	b := &Board{
+		autoBan:    game.AutoBan,
+		freezeTime: game.FreezeTime,
+		revealed:   game.Revealed,
+
+		requireApproval: game.RequireApproval,
+		writeupEnforced: game.WriteupEnforced(),
+		teams:           make(map[uint]*team),
+		challenges:      make(map[uint]*challenge),
+		live:            newView(0),
+	}
+
+	if game.FreezeTime != 0 && !game.Revealed {
+		b.frozen = newView(game.FreezeTime)
+	}
+
+	return b
+}
+
+// OnChange sets the listener called after the standings of some teams have changed,
+// frozen deltas are nil if the game has no frozen scoreboard
+func (b *Board) OnChange(listener func(live []*Delta, frozen []*Delta)) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.listener = listener
+}
+
+func (b *Board) views() []*view {
+	if b.frozen != nil {
+		return []*view{b.live, b.frozen}
+	}
+	return []*view{b.live}
+}
+
+// Load fills the board with the teams, the solved flags sorted by the solved time, the unlocked hints
+// and the found sub flags, the stored scores of the flags are trusted
+func (b *Board) Load(teams []*store.Team, flags []*store.Flag, unlocks []*store.HintUnlock, parts []*store.SubFlagSolve) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, t := range teams {
+		b.team(t)
+	}
+
+	for _, flag := range flags {
+		t, ok := b.teams[flag.TeamID]
+		if !ok || t.solves[flag.ChallengeID] != nil {
+			continue
+		}
+
+		c := b.challenge(flag.Challenge)
+		s := &solve{
+			flagID:    flag.ID,
+			team:      t,
+			challenge: c,
+			solvedAt:  flag.SolvedAt,
+			cheated:   flag.State == store.FlagCheated,
+			score:     flag.Score,
+		}
+		t.solves[flag.ChallengeID] = s
+		c.solves = append(c.solves, s)
+	}
+
+	for _, unlock := range unlocks {
+		if t, ok := b.teams[unlock.TeamID]; ok {
+			t.penalties[unlock.HintID] = &penalty{cost: unlock.Cost, unlockedAt: unlock.UnlockedAt}
+		}
+	}
+
+	for _, solve := range parts {
+		if t, ok := b.teams[solve.TeamID]; ok {
+			b.addPart(t, b.challenge(solve.Challenge), solve)
+		}
+	}
+
+	for _, c := range b.challenges {
+		b.reorder(c)
+		b.revalue(c)
+	}
+
+	all := make([]*team, 0, len(b.teams))
+	for _, t := range b.teams {
+		all = append(all, t)
+	}
+	for _, v := range b.views() {
+		v.refresh(all)
+	}
+}
+
+// team returns the state of the team, the ranked state is only taken when the team is new,
+// for the preloaded teams of the flags may be stale
+func (b *Board) team(t *store.Team) *team {
+	state, ok := b.teams[t.ID]
+	if !ok {
+		state = &team{
+			id:        t.ID,
+			unranked:  !b.ranked(t),
+			solves:    make(map[uint]*solve),
+			penalties: make(map[uint]*penalty),
+			parts:     make(map[uint][]*part),
+		}
+		b.teams[t.ID] = state
+	}
+	state.uuid = t.UUID
+	state.name = t.Name
+	return state
+}
+
+func (b *Board) ranked(t *store.Team) bool {
+	approved := !b.requireApproval || t.Approved()
+	return !t.Banned && approved && (!b.writeupEnforced || t.WriteupApproved)
+}
+
+// teamCount returns the number of the ranked teams, the team count of the score formula
+func (b *Board) teamCount() int {
+	count := 0
+	for _, t := range b.teams {
+		if !t.unranked {
+			count++
+		}
+	}
+	return count
+}
+
+func (b *Board) challenge(c *store.Challenge) *challenge {
+	state, ok := b.challenges[c.ID]
+	if !ok {
+		state = &challenge{partial: make(map[uint]*team)}
+		b.challenges[c.ID] = state
+	}
+
+	if state.challenge == nil || state.challenge.ScoreFormula != c.ScoreFormula {
+		state.formula = compile(c)
+	}
+
+	// keep a copy, so that editing the challenge in place is noticed by the next rescore
+	copied := *c
+	state.challenge = &copied
+	return state
+}
+
+// reorder assigns the order of the solves in the challenge,
+// cheated solves are not counted if the game bans cheaters automatically
+func (b *Board) reorder(c *challenge) {
+	order := 1
+	for _, s := range c.solves {
+		if s.cheated && b.autoBan {
+			s.order = 0
+			continue
+		}
+		s.order = order
+		order++
+	}
+}
+
+// rescore recalculates the scores of the solves in the challenge,
+// returns the solves whose score has changed
+func (b *Board) rescore(c *challenge) []*solve {
+	b.reorder(c)
+
+	changed := []*solve{}
+	teamCount := b.teamCount()
+	for _, s := range c.solves {
+		if s.order == 0 {
+			continue
+		}
+
+		score := c.formula.evaluate(c.challenge, teamCount, len(c.solves), s.order)
+		if score != s.score {
+			s.score = score
+			changed = append(changed, s)
+		}
+	}
+
+	b.revalue(c)
+	return changed
+}
+
+// revalue calculates the score of the next solve of the challenge,
+// and the scores counted in the frozen view
+func (b *Board) revalue(c *challenge) {
+	order := 1
+	for _, s := range c.solves {
+		order = max(order, s.order+1)
+	}
+	c.value = c.formula.evaluate(c.challenge, b.teamCount(), max(len(c.solves), 1), order)
+
+	b.freeze(c)
+}
+
+// freeze calculates the scores of the challenge counted in the frozen view as if the solves
+// after the freeze didn't happen, so that they don't change the frozen standings
+func (b *Board) freeze(c *challenge) {
+	if b.frozen == nil {
+		return
+	}
+
+	// the solves are sorted by the solved time
+	solves := c.solves
+	if i := slices.IndexFunc(solves, func(s *solve) bool { return !b.frozen.before(s.solvedAt) }); i >= 0 {
+		solves = solves[:i]
+	}
+
+	teamCount := b.teamCount()
+	order := 1
+	for _, s := range solves {
+		if s.order == 0 {
+			continue
+		}
+		order = max(order, s.order+1)
+
+		// fixed scores don't depend on the solves
+		s.frozenScore = s.score
+		if c.formula != nil {
+			s.frozenScore = c.formula.evaluate(c.challenge, teamCount, len(solves), s.order)
+		}
+	}
+	c.frozenValue = c.formula.evaluate(c.challenge, teamCount, max(len(solves), 1), order)
+}
+
+// addPart adds the found sub flag to the team, returns false if it has been found
+func (b *Board) addPart(t *team, c *challenge, solve *store.SubFlagSolve) bool {
+	for _, p := range t.parts[solve.ChallengeID] {
+		if p.subFlagID == solve.SubFlagID {
+			return false
+		}
+	}
+
+	t.parts[solve.ChallengeID] = append(t.parts[solve.ChallengeID], &part{
+		subFlagID: solve.SubFlagID,
+		challenge: c,
+		weight:    solve.Weight,
+		solvedAt:  solve.SolvedAt,
+	})
+	c.partial[t.id] = t
+	return true
+}
+
+// affected returns the teams which have solved the challenge or found some of its sub flags
+func (b *Board) affected(c *challenge) []*team {
+	teams := make([]*team, 0, len(c.solves)+len(c.partial))
+	for _, s := range c.solves {
+		teams = append(teams, s.team)
+	}
+	for _, t := range c.partial {
+		if _, ok := t.solves[c.challenge.ID]; !ok {
+			teams = append(teams, t)
+		}
+	}
+	return teams
+}
+
+// UpdateTeam adds the team to the board,
+// or applies its name, ban, registration and writeup state.
+// Returns the flags whose score has changed with the number of the ranked teams.
+func (b *Board) UpdateTeam(t *store.Team) []*store.Flag {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	teamCount := b.teamCount()
+	state := b.team(t)
+	state.unranked = !b.ranked(t)
+	if b.teamCount() == teamCount {
+		b.refresh([]*team{state})
+		return nil
+	}
+
+	// the team count of the score formulas has changed, rescore all the challenges
+	changed := []*solve{}
+	for _, c := range b.challenges {
+		changed = append(changed, b.rescore(c)...)
+	}
+
+	all := make([]*team, 0, len(b.teams))
+	for _, t := range b.teams {
+		all = append(all, t)
+	}
+	b.refresh(all)
+
+	return changedFlags(changed)
+}
+
+// refresh refreshes the teams in all the views and notifies the listener
+// of the teams whose score or rank has changed
+func (b *Board) refresh(teams []*team) {
+	deltas := make([][]*Delta, 2)
+
+	for i, v := range b.views() {
+		type standing struct{ score, rank int }
+
+		before := make(map[uint]standing, len(teams))
+		for _, t := range teams {
+			score, rank := v.rank(v.entry(t))
+			before[t.id] = standing{score, rank}
+		}
+
+		v.refresh(teams)
+
+		deltas[i] = []*Delta{}
+		for _, t := range teams {
+			score, rank := v.rank(v.entry(t))
+			if before[t.id] != (standing{score, rank}) {
+				deltas[i] = append(deltas[i], &Delta{Team: t.uuid, Name: t.name, Score: score, Rank: rank})
+			}
+		}
+	}
+
+	if b.listener != nil && (len(deltas[0]) > 0 || len(deltas[1]) > 0) {
+		b.listener(deltas[0], deltas[1])
+	}
+}
+
+// Update applies the state of the flag to the board and rescores its challenge,
+// the flag should be preloaded with the team and the challenge.
+// Returns the flags whose score has changed.
+func (b *Board) Update(flag *store.Flag) []*store.Flag {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if flag.State < store.FlagSolved {
+		return nil
+	}
+
+	t := b.team(flag.Team)
+	c := b.challenge(flag.Challenge)
+
+	s, ok := t.solves[flag.ChallengeID]
+	if !ok {
+		s = &solve{flagID: flag.ID, team: t, challenge: c, solvedAt: flag.SolvedAt}
+		t.solves[flag.ChallengeID] = s
+
+		i, _ := slices.BinarySearchFunc(c.solves, s, func(a, b *solve) int {
+			if a.solvedAt != b.solvedAt {
+				return cmp.Compare(a.solvedAt, b.solvedAt)
+			}
+			return cmp.Compare(a.flagID, b.flagID)
+		})
+		c.solves = slices.Insert(c.solves, i, s)
+	} else if s.flagID != flag.ID {
+		// the team has solved the challenge with another flag
+		return nil
+	}
+	s.cheated = flag.State == store.FlagCheated
+
+	return b.apply(c)
+}
+
+// Unlock deducts the cost of the unlocked hint from the team,
+// the unlock should be preloaded with the team
+func (b *Board) Unlock(unlock *store.HintUnlock) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	t := b.team(unlock.Team)
+	if _, ok := t.penalties[unlock.HintID]; ok {
+		return
+	}
+
+	t.penalties[unlock.HintID] = &penalty{cost: unlock.Cost, unlockedAt: unlock.UnlockedAt}
+	b.refresh([]*team{t})
+}
+
+// SolvePart adds the value of the found sub flag to the team,
+// the solve should be preloaded with the team and the challenge
+func (b *Board) SolvePart(solve *store.SubFlagSolve) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	t := b.team(solve.Team)
+	_, known := b.challenges[solve.ChallengeID]
+	c := b.challenge(solve.Challenge)
+	if !known {
+		b.revalue(c)
+	}
+
+	if b.addPart(t, c, solve) {
+		b.refresh([]*team{t})
+	}
+}
+
+// Rescore recalculates the scores of the challenge, used when the challenge is edited.
+// Returns the flags whose score has changed.
+func (b *Board) Rescore(c *store.Challenge) []*store.Flag {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.apply(b.challenge(c))
+}
+
+func (b *Board) apply(c *challenge) []*store.Flag {
+	changed := b.rescore(c)
+	b.refresh(b.affected(c))
+	return changedFlags(changed)
+}
+
+// changedFlags returns the flags of the solves with only the ID and the new score
+func changedFlags(changed []*solve) []*store.Flag {
+	flags := make([]*store.Flag, 0, len(changed))
+	for _, s := range changed {
+		flag := &store.Flag{Score: s.score}
+		flag.ID = s.flagID
+		flags = append(flags, flag)
+	}
+	return flags
+}
+
+// Scoreboard returns the snapshot of the live or frozen standings
+func (b *Board) Scoreboard(frozen bool) *Scoreboard {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	v := b.live
+	if frozen && b.frozen != nil {
+		v = b.frozen
+	}
+
+	if v.snapshot != nil {
+		return v.snapshot
+	}
+
+	sb := &Scoreboard{Standings: make([]*Standing, 0, len(v.ranking))}
+	for i, e := range v.ranking {
+		standing := &Standing{
+			Rank:      i + 1,
+			TeamID:    e.team.id,
+			Team:      e.team.uuid,
+			Name:      e.team.name,
+			Score:     e.score,
+			LastSolve: e.last,
+			Solves:    make(map[string]*Solve),
+		}
+		for _, s := range e.team.solves {
+			if v.counts(s) {
+				standing.Solves[s.challenge.challenge.UUID] = &Solve{
+					SolvedAt: s.solvedAt,
+					Score:    v.score(s),
+					Order:    s.order,
+					Blood:    blood(s.order),
+				}
+			}
+		}
+		for _, p := range e.team.penalties {
+			if v.before(p.unlockedAt) {
+				standing.Penalty += p.cost
+				standing.Penalties = append(standing.Penalties, &Penalty{UnlockedAt: p.unlockedAt, Cost: p.cost})
+			}
+		}
+		for _, p := range v.parts(e.team) {
+			standing.Partial += v.partScore(p)
+			standing.Parts = append(standing.Parts, &Part{SolvedAt: p.solvedAt, Score: v.partScore(p)})
+		}
+		sb.Standings = append(sb.Standings, standing)
+	}
+
+	v.snapshot = sb
+	return sb
+}
+
+// Order returns the solve order of the flag in its challenge, the same order used by
+// the score formula, 0 if the flag is not counted
+func (b *Board) Order(flag *store.Flag) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	t, ok := b.teams[flag.TeamID]
+	if !ok {
+		return 0
+	}
+
+	s, ok := t.solves[flag.ChallengeID]
+	if !ok || s.flagID != flag.ID {
+		return 0
+	}
+	return s.order
+}
+
+// Bloods returns the first three solves of the challenge counted in the live or frozen view
+func (b *Board) Bloods(challengeID uint, frozen bool) []*Blood {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	v := b.live
+	if frozen && b.frozen != nil {
+		v = b.frozen
+	}
+
+	bloods := []*Blood{}
+	c, ok := b.challenges[challengeID]
+	if !ok {
+		return bloods
+	}
+
+	for _, s := range c.solves {
+		if len(bloods) == 3 {
+			break
+		}
+		if !v.counts(s) || blood(s.order) == 0 {
+			continue
+		}
+
+		bloods = append(bloods, &Blood{
+			Blood:    s.order,
+			Team:     s.team.uuid,
+			Name:     s.team.name,
+			SolvedAt: s.solvedAt,
+		})
+	}
+	return bloods
+}
+
+// SolvedCount returns the number of the solves of the challenge counted in the live or frozen view
+func (b *Board) SolvedCount(challengeID uint, frozen bool) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	v := b.live
+	if frozen && b.frozen != nil {
+		v = b.frozen
+	}
+
+	c, ok := b.challenges[challengeID]
+	if !ok {
+		return 0
+	}
+
+	count := 0
+	for _, s := range c.solves {
+		if v.counts(s) {
+			count++
+		}
+	}
+	return count
+}
+
+// Rank returns the score and the rank of the team, rank is 0 if the team is not ranked
+func (b *Board) Rank(t *store.Team, frozen bool) (int, int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	v := b.live
+	if frozen && b.frozen != nil {
+		v = b.frozen
+	}
+
+	e, ok := v.entries[t.ID]
+	if !ok {
+		return 0, 0
+	}
+	return v.rank(e)
+}
@@ -0,0 +1,476 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scoreboard
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/store"
+)
+
+func TestLoad(t *testing.T) {
+	alice, bob, carol := newTeam(1, "alice"), newTeam(2, "bob"), newTeam(3, "carol")
+	carol.Banned = true
+	web, pwn := newChallenge(1, "web"), newChallenge(2, "pwn")
+
+	flags := []*store.Flag{
+		newFlag(bob, web, 100, 100),
+		newFlag(carol, web, 150, 100),
+		newFlag(alice, web, 200, 100),
+		newFlag(alice, pwn, 300, 200),
+		newFlag(bob, pwn, 400, 200),
+	}
+
+	board := load(&store.Game{FreezeTime: 350, RequireApproval: true}, []*store.Team{alice, bob, carol}, flags)
+
+	sb := board.Scoreboard(false)
+	assert.Len(t, sb.Standings, 2, "banned teams should be excluded")
+
+	// same score, alice solved the last challenge earlier
+	assert.Equal(t, "alice", sb.Standings[0].Team)
+	assert.Equal(t, 1, sb.Standings[0].Rank)
+	assert.Equal(t, 300, sb.Standings[0].Score)
+	assert.Equal(t, "bob", sb.Standings[1].Team)
+	assert.Equal(t, 2, sb.Standings[1].Rank)
+
+	// the banned team still takes a blood order
+	assert.Equal(t, 1, sb.Standings[1].Solves["web"].Order)
+	assert.Equal(t, 3, sb.Standings[0].Solves["web"].Order)
+	assert.Equal(t, 1, sb.Standings[0].Solves["pwn"].Order)
+
+	frozen := board.Scoreboard(true)
+	assert.Equal(t, "alice", frozen.Standings[0].Team)
+	assert.Equal(t, 100, frozen.Standings[1].Score)
+	assert.NotContains(t, frozen.Standings[1].Solves, "pwn")
+
+	score, rank := board.Rank(bob, false)
+	assert.Equal(t, 300, score)
+	assert.Equal(t, 2, rank)
+
+	score, rank = board.Rank(carol, false)
+	assert.Equal(t, 100, score)
+	assert.Equal(t, 0, rank, "banned teams should not be ranked")
+
+	// pending teams are not ranked until approved
+	dave := newTeam(4, "dave")
+	dave.Status = store.TeamStatusPending
+	board.UpdateTeam(dave)
+	assert.Len(t, board.Scoreboard(false).Standings, 2)
+
+	dave.Status = store.TeamStatusApproved
+	board.UpdateTeam(dave)
+	_, rank = board.Rank(dave, false)
+	assert.Equal(t, 3, rank)
+
+	// pending teams play and are ranked if the game doesn't require approval
+	eve := newTeam(5, "eve")
+	eve.Status = store.TeamStatusPending
+	board = load(&store.Game{}, []*store.Team{eve}, nil)
+	assert.Len(t, board.Scoreboard(false).Standings, 1)
+}
+
+func TestUpdate(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web := newChallenge(1, "web")
+	web.ScoreFormula = "max(original_score - 10 * (solved_count - 1), 50)"
+
+	board := load(&store.Game{}, []*store.Team{alice, bob}, nil)
+
+	changed := board.Update(newFlag(alice, web, 100, -1))
+	assert.Len(t, changed, 1)
+	assert.Equal(t, 100, changed[0].Score)
+
+	// the second solve lowers the score of both solves
+	changed = board.Update(newFlag(bob, web, 200, -1))
+	assert.Len(t, changed, 2)
+
+	sb := board.Scoreboard(false)
+	assert.Equal(t, "alice", sb.Standings[0].Team)
+	assert.Equal(t, 90, sb.Standings[0].Score)
+	assert.Equal(t, 90, sb.Standings[1].Score)
+
+	// solving twice is ignored
+	assert.Empty(t, board.Update(newFlag(bob, web, 300, -1)))
+
+	// banning moves the team out of the ranking
+	bob.Banned = true
+	board.UpdateTeam(bob)
+	assert.Len(t, board.Scoreboard(false).Standings, 1)
+
+	bob.Banned = false
+	board.UpdateTeam(bob)
+	_, rank := board.Rank(bob, false)
+	assert.Equal(t, 2, rank)
+}
+
+func TestRescore(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web := newChallenge(1, "web")
+
+	board := load(&store.Game{}, []*store.Team{alice, bob}, nil)
+	board.Update(newFlag(alice, web, 100, -1))
+	board.Update(newFlag(bob, web, 200, -1))
+
+	// edited score
+	edited := *web
+	edited.Score = 200
+	assert.Len(t, board.Rescore(&edited), 2)
+	score, _ := board.Rank(alice, false)
+	assert.Equal(t, 200, score)
+
+	// edited formula
+	edited.ScoreFormula = "original_score - 50 * (order - 1)"
+	changed := board.Rescore(&edited)
+	assert.Len(t, changed, 1)
+	assert.Equal(t, 150, changed[0].Score)
+
+	// nothing changed
+	assert.Empty(t, board.Rescore(&edited))
+}
+
+func TestTeamCount(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web := newChallenge(1, "web")
+	web.ScoreFormula = "original_score * (1 - solved_count / team_count / 2)"
+
+	board := load(&store.Game{RequireApproval: true}, []*store.Team{alice, bob}, nil)
+	board.Update(newFlag(alice, web, 100, -1))
+	score, _ := board.Rank(alice, false)
+	assert.Equal(t, 75, score)
+
+	// pending registrations don't count as teams
+	carol := newTeam(3, "carol")
+	carol.Status = store.TeamStatusPending
+	assert.Empty(t, board.UpdateTeam(carol))
+
+	carol.Status = store.TeamStatusApproved
+	changed := board.UpdateTeam(carol)
+	assert.Len(t, changed, 1)
+	assert.Equal(t, 83, changed[0].Score)
+
+	// banned teams don't count either
+	bob.Banned = true
+	assert.Len(t, board.UpdateTeam(bob), 1)
+	score, _ = board.Rank(alice, false)
+	assert.Equal(t, 75, score)
+}
+
+func TestValidateFormula(t *testing.T) {
+	assert.NoError(t, ValidateFormula(""))
+	assert.NoError(t, ValidateFormula("max(original_score - 10 * (solved_count - 1), 50)"))
+	assert.NoError(t, ValidateFormula("exponential_score_with_top3_bonus(order, 1.1, 1.05, 1.0)"))
+	assert.Error(t, ValidateFormula("max(original_score"))
+	assert.Error(t, ValidateFormula("unknown(order)"))
+}
+
+func TestCheat(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web := newChallenge(1, "web")
+	web.ScoreFormula = "exponential_score_with_top3_bonus(order, 1.1, 1.05, 1.0)"
+
+	board := load(&store.Game{AutoBan: true}, []*store.Team{alice, bob}, nil)
+
+	cheated := newFlag(alice, web, 100, -1)
+	board.Update(cheated)
+	board.Update(newFlag(bob, web, 200, -1))
+
+	_, rank := board.Rank(bob, false)
+	assert.Equal(t, 2, rank)
+
+	// the cheated solve is not counted, bob takes the first blood
+	cheated.State = store.FlagCheated
+	board.Update(cheated)
+
+	sb := board.Scoreboard(false)
+	assert.Equal(t, "bob", sb.Standings[0].Team)
+	assert.Equal(t, 1, sb.Standings[0].Solves["web"].Order)
+	assert.Equal(t, 1, sb.Standings[0].Solves["web"].Blood)
+	assert.Empty(t, sb.Standings[1].Solves)
+	assert.Equal(t, 0, board.Order(cheated))
+}
+
+func TestWriteup(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web := newChallenge(1, "web")
+	flags := []*store.Flag{newFlag(alice, web, 100, 100), newFlag(bob, web, 200, 100)}
+
+	// not enforced before the deadline
+	game := &store.Game{RequireWriteup: true, WriteupDeadline: time.Now().Add(time.Hour).UnixMilli()}
+	assert.Len(t, load(game, []*store.Team{alice, bob}, flags).Scoreboard(false).Standings, 2)
+
+	game.WriteupDeadline = time.Now().Add(-time.Hour).UnixMilli()
+	board := load(game, []*store.Team{alice, bob}, flags)
+	assert.Empty(t, board.Scoreboard(false).Standings)
+
+	bob.WriteupApproved = true
+	board.UpdateTeam(bob)
+	sb := board.Scoreboard(false)
+	assert.Len(t, sb.Standings, 1)
+	assert.Equal(t, "bob", sb.Standings[0].Team)
+	assert.Equal(t, 2, sb.Standings[0].Solves["web"].Order)
+}
+
+func TestBloods(t *testing.T) {
+	teams := []*store.Team{}
+	for i := 1; i <= 5; i++ {
+		teams = append(teams, newTeam(uint(i), fmt.Sprintf("team%d", i)))
+	}
+	web := newChallenge(1, "web")
+
+	board := load(&store.Game{FreezeTime: 250}, teams, nil)
+
+	flags := []*store.Flag{}
+	for i, team := range teams {
+		flag := newFlag(team, web, int64((i+1)*100), -1)
+		board.Update(flag)
+		flags = append(flags, flag)
+	}
+
+	assert.Equal(t, 1, board.Order(flags[0]))
+	assert.Equal(t, 4, board.Order(flags[3]))
+
+	bloods := board.Bloods(web.ID, false)
+	assert.Len(t, bloods, 3)
+	assert.Equal(t, "team1", bloods[0].Team)
+	assert.Equal(t, 3, bloods[2].Blood)
+
+	// the third blood was after the freeze
+	assert.Len(t, board.Bloods(web.ID, true), 2)
+	assert.Equal(t, 5, board.SolvedCount(web.ID, false))
+	assert.Equal(t, 2, board.SolvedCount(web.ID, true))
+
+	sb := board.Scoreboard(false)
+	assert.Equal(t, 2, sb.Standings[1].Solves["web"].Blood)
+	assert.Equal(t, 0, sb.Standings[3].Solves["web"].Blood)
+}
+
+func TestOnChange(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web, pwn := newChallenge(1, "web"), newChallenge(2, "pwn")
+
+	board := load(&store.Game{FreezeTime: 150}, []*store.Team{alice, bob}, nil)
+
+	var live, frozen []*Delta
+	board.OnChange(func(l []*Delta, f []*Delta) {
+		live, frozen = l, f
+	})
+
+	board.Update(newFlag(alice, web, 100, -1))
+	assert.Equal(t, []*Delta{{Team: "alice", Name: "alice", Score: 100, Rank: 1}}, live)
+	assert.Equal(t, live, frozen)
+
+	// solves after the freeze only change the live view
+	board.Update(newFlag(bob, pwn, 200, -1))
+	assert.Equal(t, []*Delta{{Team: "bob", Name: "bob", Score: 100, Rank: 2}}, live)
+	assert.Empty(t, frozen)
+
+	// no frozen deltas once revealed
+	revealed := load(&store.Game{FreezeTime: 150, Revealed: true}, []*store.Team{alice}, nil)
+	revealed.OnChange(func(l []*Delta, f []*Delta) {
+		live, frozen = l, f
+	})
+	revealed.Update(newFlag(alice, web, 200, -1))
+	assert.Len(t, live, 1)
+	assert.Nil(t, frozen)
+}
+
+func TestFrozenScores(t *testing.T) {
+	alice, bob, carol := newTeam(1, "alice"), newTeam(2, "bob"), newTeam(3, "carol")
+	web := newChallenge(1, "web")
+	web.ScoreFormula = "max(original_score - 10 * (solved_count - 1), 50)"
+
+	board := load(&store.Game{FreezeTime: 150}, []*store.Team{alice, bob, carol}, nil)
+	board.Update(newFlag(alice, web, 100, -1))
+	frozen := board.Scoreboard(true)
+
+	var liveDeltas, frozenDeltas []*Delta
+	board.OnChange(func(l []*Delta, f []*Delta) {
+		liveDeltas, frozenDeltas = l, f
+	})
+
+	// the solves after the freeze lower the live score of alice only
+	board.Update(newFlag(bob, web, 200, -1))
+	board.Update(newFlag(carol, web, 300, -1))
+	assert.Len(t, liveDeltas, 3)
+	assert.Equal(t, &Delta{Team: "alice", Name: "alice", Score: 80, Rank: 1}, liveDeltas[0])
+	assert.Empty(t, frozenDeltas)
+
+	assert.Equal(t, frozen, board.Scoreboard(true))
+	assert.Equal(t, 100, frozen.Standings[0].Score)
+	assert.Equal(t, 100, frozen.Standings[0].Solves["web"].Score)
+
+	score, _ := board.Rank(alice, true)
+	assert.Equal(t, 100, score)
+	score, _ = board.Rank(alice, false)
+	assert.Equal(t, 80, score)
+}
+
+func TestUnlock(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web := newChallenge(1, "web")
+
+	newUnlock := func(team *store.Team, hintID uint, cost int, unlockedAt int64) *store.HintUnlock {
+		return &store.HintUnlock{HintID: hintID, TeamID: team.ID, Team: team, Cost: cost, UnlockedAt: unlockedAt}
+	}
+
+	board := NewBoard(&store.Game{FreezeTime: 250})
+	board.Load([]*store.Team{alice, bob}, []*store.Flag{
+		newFlag(alice, web, 100, 100),
+		newFlag(bob, web, 200, 100),
+	}, []*store.HintUnlock{newUnlock(alice, 1, 30, 50)}, nil)
+
+	sb := board.Scoreboard(false)
+	assert.Equal(t, "bob", sb.Standings[0].Team)
+	assert.Equal(t, 70, sb.Standings[1].Score)
+	assert.Equal(t, 30, sb.Standings[1].Penalty)
+
+	timeline := sb.Timeline(2)
+	assert.Equal(t, []Point{{Time: 50, Score: -30}, {Time: 100, Score: 70}}, timeline[1].Points)
+
+	// unlocks after the freeze only change the live view
+	board.Unlock(newUnlock(bob, 1, 50, 300))
+	score, rank := board.Rank(bob, false)
+	assert.Equal(t, 50, score)
+	assert.Equal(t, 2, rank)
+
+	score, _ = board.Rank(bob, true)
+	assert.Equal(t, 100, score)
+
+	// unlocking twice is ignored
+	board.Unlock(newUnlock(bob, 1, 50, 400))
+	score, _ = board.Rank(bob, false)
+	assert.Equal(t, 50, score)
+}
+
+func TestSolvePart(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web := newChallenge(1, "web")
+	web.ScoreFormula = "max(100 - 50 * (solved_count - 1), 10)"
+
+	newPart := func(team *store.Team, subFlagID uint, weight int, solvedAt int64) *store.SubFlagSolve {
+		return &store.SubFlagSolve{
+			SubFlagID:   subFlagID,
+			ChallengeID: web.ID,
+			Challenge:   web,
+			TeamID:      team.ID,
+			Team:        team,
+			Weight:      weight,
+			SolvedAt:    solvedAt,
+		}
+	}
+
+	board := NewBoard(&store.Game{FreezeTime: 250})
+	board.Load([]*store.Team{alice, bob}, nil, nil, []*store.SubFlagSolve{newPart(alice, 1, 30, 50)})
+
+	score, _ := board.Rank(alice, false)
+	assert.Equal(t, 30, score)
+
+	// found twice is ignored
+	board.SolvePart(newPart(alice, 1, 30, 60))
+	board.SolvePart(newPart(bob, 2, 70, 100))
+	score, rank := board.Rank(bob, false)
+	assert.Equal(t, 70, score)
+	assert.Equal(t, 1, rank)
+
+	sb := board.Scoreboard(false)
+	assert.Equal(t, 70, sb.Standings[0].Partial)
+	assert.Equal(t, []Point{{Time: 50, Score: 30}}, sb.Timeline(2)[1].Points)
+
+	// the solve replaces the found sub flags
+	board.SolvePart(newPart(alice, 2, 70, 200))
+	board.Update(newFlag(alice, web, 200, -1))
+	score, rank = board.Rank(alice, false)
+	assert.Equal(t, 100, score)
+	assert.Equal(t, 1, rank)
+
+	// the found sub flags are worth less as the challenge decays
+	carol := newTeam(3, "carol")
+	board.Update(newFlag(carol, web, 220, -1))
+	score, _ = board.Rank(alice, false)
+	assert.Equal(t, 50, score)
+	score, _ = board.Rank(bob, false)
+	assert.Equal(t, 35, score)
+
+	// sub flags found after the freeze only count in the live view
+	board.SolvePart(newPart(bob, 1, 30, 300))
+	score, _ = board.Rank(bob, false)
+	assert.Equal(t, 50, score)
+	score, _ = board.Rank(bob, true)
+	assert.Equal(t, 35, score)
+}
+
+func benchmarkBoard(teams int, challenges int) (*Board, []*store.Team, []*store.Challenge) {
+	ts := make([]*store.Team, teams)
+	for i := range ts {
+		ts[i] = newTeam(uint(i+1), fmt.Sprintf("team-%d", i))
+	}
+
+	cs := make([]*store.Challenge, challenges)
+	for i := range cs {
+		cs[i] = newChallenge(uint(i+1), fmt.Sprintf("challenge-%d", i))
+		cs[i].Score = 1000
+		cs[i].Difficulty = 1
+		cs[i].ScoreFormula = "max(exponential_score, original_score * 0.1)"
+	}
+
+	// every team solves a few challenges
+	flags := []*store.Flag{}
+	for i, team := range ts {
+		for j := 0; j < i%challenges; j++ {
+			flags = append(flags, newFlag(team, cs[j], int64(i*challenges+j), 100))
+		}
+	}
+
+	return load(&store.Game{FreezeTime: int64(teams * challenges / 2)}, ts, flags), ts, cs
+}
+
+func BenchmarkLoad(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchmarkBoard(5000, 30)
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	board, teams, challenges := benchmarkBoard(5000, 30)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		team := teams[i%len(teams)]
+		board.Update(newFlag(team, challenges[len(challenges)-1], int64(1e9+i), -1))
+	}
+}
+
+func BenchmarkRank(b *testing.B) {
+	board, teams, _ := benchmarkBoard(5000, 30)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		board.Rank(teams[i%len(teams)], i%2 == 0)
+	}
+}
+
+func BenchmarkScoreboard(b *testing.B) {
+	board, teams, challenges := benchmarkBoard(5000, 30)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		// invalidate the snapshot every time
+		board.Update(newFlag(teams[i%len(teams)], challenges[len(challenges)-1], int64(1e9+i), -1))
+		board.Scoreboard(false).Page(1, 50)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scoreboard
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"rina.icu/hoshino/store"
+)
+
+// Engine serves the scoreboards of the games from memory,
+// and keeps them updated incrementally
+type Engine struct {
+	store *store.Store
+
+	lock   sync.Mutex
+	boards map[uint]*Board
+
+	listeners []func(gameID uint, live []*Delta, frozen []*Delta)
+}
+
+func NewEngine(s *store.Store) *Engine {
+	return &Engine{
+		store:  s,
+		boards: make(map[uint]*Board),
+	}
+}
+
+// OnChange registers a listener called with the deltas of the game
+// after the standings of some teams have changed, frozen deltas are nil
+// if the game has no frozen scoreboard. Listeners should not block.
+func (e *Engine) OnChange(listener func(gameID uint, live []*Delta, frozen []*Delta)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.listeners = append(e.listeners, listener)
+}
+
+// Load loads the scoreboards of all the games
+func (e *Engine) Load() error {
+	games, err := e.store.GetGames()
+	if err != nil {
+		return err
+	}
+
+	for _, game := range games {
+		if err := e.Reload(game); err != nil {
+			return err
+		}
+	}
+
+	slog.Info(fmt.Sprintf("Loaded the scoreboards of %d games", len(games)))
+	return nil
+}
+
+// Reload rebuilds the scoreboard of the game from the database
+func (e *Engine) Reload(game *store.Game) error {
+	flags, err := e.store.GetSolvedFlagsByGame(game)
+	if err != nil {
+		return err
+	}
+
+	unlocks, err := e.store.GetHintUnlocksByGame(game)
+	if err != nil {
+		return err
+	}
+
+	parts, err := e.store.GetSubFlagSolvesByGame(game)
+	if err != nil {
+		return err
+	}
+
+	board := NewBoard(game)
+	board.Load(game.GetTeams(e.store), flags, unlocks, parts)
+	board.OnChange(func(live []*Delta, frozen []*Delta) {
+		e.lock.Lock()
+		listeners := e.listeners
+		e.lock.Unlock()
+
+		for _, listener := range listeners {
+			listener(game.ID, live, frozen)
+		}
+	})
+
+	e.lock.Lock()
+	e.boards[game.ID] = board
+	e.lock.Unlock()
+
+	return nil
+}
+
+// board returns the scoreboard of the game, it's rebuilt if the settings of the game
+// have changed, or the writeup deadline has passed
+func (e *Engine) board(game *store.Game) *Board {
+	e.lock.Lock()
+	board, ok := e.boards[game.ID]
+	e.lock.Unlock()
+
+	if ok && board.autoBan == game.AutoBan && board.freezeTime == game.FreezeTime && board.revealed == game.Revealed &&
+		board.requireApproval == game.RequireApproval && board.writeupEnforced == game.WriteupEnforced() {
+		return board
+	}
+
+	if err := e.Reload(game); err != nil {
+		slog.Error(fmt.Sprintf("Failed to load the scoreboard of game %s: %s", game.UUID, err.Error()))
+		return NewBoard(game)
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.boards[game.ID]
+}
+
+func (e *Engine) persist(flags []*store.Flag) {
+	for _, flag := range flags {
+		if err := e.store.UpdateFlagScore(flag); err != nil {
+			slog.Error(fmt.Sprintf("Failed to update the score of flag %d: %s", flag.ID, err.Error()))
+		}
+	}
+}
+
+// UpdateTeam adds the team to the scoreboard, or applies its name, ban and registration state,
+// and persists the recalculated scores if the number of the ranked teams has changed
+func (e *Engine) UpdateTeam(game *store.Game, team *store.Team) {
+	e.persist(e.board(game).UpdateTeam(team))
+}
+
+// Update applies a solved or cheated flag to the scoreboard,
+// and persists the recalculated scores of the challenge.
+// Returns the solve order of the flag, 0 if it's not counted.
+func (e *Engine) Update(game *store.Game, flag *store.Flag) int {
+	board := e.board(game)
+	e.persist(board.Update(flag))
+	return board.Order(flag)
+}
+
+// Unlock deducts the cost of the unlocked hint from the team
+func (e *Engine) Unlock(game *store.Game, unlock *store.HintUnlock) {
+	e.board(game).Unlock(unlock)
+}
+
+// SolvePart adds the value of the found sub flag to the team
+func (e *Engine) SolvePart(game *store.Game, solve *store.SubFlagSolve) {
+	e.board(game).SolvePart(solve)
+}
+
+// Rescore recalculates and persists the scores of the challenge
+func (e *Engine) Rescore(game *store.Game, challenge *store.Challenge) {
+	e.persist(e.board(game).Rescore(challenge))
+}
+
+// Scoreboard returns the live or frozen scoreboard of the game
+func (e *Engine) Scoreboard(game *store.Game, frozen bool) *Scoreboard {
+	return e.board(game).Scoreboard(frozen)
+}
+
+// Bloods returns the first three solves of the challenge in the live or frozen scoreboard
+func (e *Engine) Bloods(game *store.Game, challenge *store.Challenge, frozen bool) []*Blood {
+	return e.board(game).Bloods(challenge.ID, frozen)
+}
+
+// SolvedCount returns the number of the solves of the challenge in the live or frozen scoreboard
+func (e *Engine) SolvedCount(game *store.Game, challenge *store.Challenge, frozen bool) int {
+	return e.board(game).SolvedCount(challenge.ID, frozen)
+}
+
+// Rank returns the score and the rank of the team, rank is 0 if the team is not ranked
+func (e *Engine) Rank(game *store.Game, team *store.Team, frozen bool) (int, int) {
+	return e.board(game).Rank(team, frozen)
+}
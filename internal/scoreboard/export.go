@@ -0,0 +1,65 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scoreboard
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/spf13/cast"
+)
+
+// CTFtimeStanding is a standing in the CTFtime scoreboard format
+type CTFtimeStanding struct {
+	Pos   int    `json:"pos"`
+	Team  string `json:"team"`
+	Score int    `json:"score"`
+}
+
+// CTFtimeScoreboard is the scoreboard feed accepted by CTFtime
+type CTFtimeScoreboard struct {
+	Standings []CTFtimeStanding `json:"standings"`
+}
+
+// CTFtime converts the standings to the CTFtime scoreboard format
+func (sb *Scoreboard) CTFtime() *CTFtimeScoreboard {
+	result := &CTFtimeScoreboard{Standings: make([]CTFtimeStanding, 0, len(sb.Standings))}
+	for _, standing := range sb.Standings {
+		result.Standings = append(result.Standings, CTFtimeStanding{
+			Pos:   standing.Rank,
+			Team:  standing.Name,
+			Score: standing.Score,
+		})
+	}
+	return result
+}
+
+// WriteCSV writes the standings as CSV with the same columns as the CTFtime format
+func (sb *Scoreboard) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{"pos", "team", "score"}); err != nil {
+		return err
+	}
+
+	for _, standing := range sb.Standings {
+		if err := writer.Write([]string{cast.ToString(standing.Rank), standing.Name, cast.ToString(standing.Score)}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,38 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scoreboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	sb := &Scoreboard{Standings: []*Standing{
+		{Rank: 1, Name: "alice", Score: 300},
+		{Rank: 2, Name: "bob, the team", Score: 100},
+	}}
+
+	data, err := json.Marshal(sb.CTFtime())
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"standings":[{"pos":1,"team":"alice","score":300},{"pos":2,"team":"bob, the team","score":100}]}`, string(data))
+
+	var buf bytes.Buffer
+	assert.Nil(t, sb.WriteCSV(&buf))
+	assert.Equal(t, "pos,team,score\n1,alice,300\n2,\"bob, the team\",100\n", buf.String())
+}
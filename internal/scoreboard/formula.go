@@ -0,0 +1,126 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scoreboard
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/Knetic/govaluate"
+	"rina.icu/hoshino/store"
+)
+
+// formula is the compiled score formula of a challenge,
+// it's not safe for concurrent use
+type formula struct {
+	expression *govaluate.EvaluableExpression
+
+	// set before each evaluation, used by the bonus function
+	exponentialScore float64
+}
+
+// compile compiles the score formula of the challenge,
+// returns nil if the challenge uses a fixed score
+func compile(challenge *store.Challenge) *formula {
+	if challenge.ScoreFormula == "" {
+		return nil
+	}
+
+	f := &formula{}
+
+	expression, err := govaluate.NewEvaluableExpressionWithFunctions(challenge.ScoreFormula, f.functions())
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to compile the score formula of challenge %s: %s", challenge.UUID, err.Error()))
+		return nil
+	}
+
+	f.expression = expression
+	return f
+}
+
+// ValidateFormula checks that the score formula compiles, an empty formula means a fixed score
+func ValidateFormula(expression string) error {
+	if expression == "" {
+		return nil
+	}
+	_, err := govaluate.NewEvaluableExpressionWithFunctions(expression, (&formula{}).functions())
+	return err
+}
+
+func (f *formula) functions() map[string]govaluate.ExpressionFunction {
+	return map[string]govaluate.ExpressionFunction{
+		"max": func(args ...interface{}) (interface{}, error) {
+			return math.Max(args[0].(float64), args[1].(float64)), nil
+		},
+		"min": func(args ...interface{}) (interface{}, error) {
+			return math.Min(args[0].(float64), args[1].(float64)), nil
+		},
+		"exponential_score_with_top3_bonus": func(args ...interface{}) (interface{}, error) {
+			// numeric parameters are always passed as float64
+			order := args[0].(float64)
+			rate1 := args[1].(float64)
+			rate2 := args[2].(float64)
+			rate3 := args[3].(float64)
+			if order == 1 {
+				return f.exponentialScore * rate1, nil
+			} else if order == 2 {
+				return f.exponentialScore * rate2, nil
+			} else if order == 3 {
+				return f.exponentialScore * rate3, nil
+			}
+			return f.exponentialScore, nil
+		},
+	}
+}
+
+// evaluate calculates the score of a solve with the formula of the challenge
+func (f *formula) evaluate(challenge *store.Challenge, teamCount int, solvedCount int, order int) int {
+	if f == nil {
+		return challenge.Score
+	}
+
+	teamCount = max(teamCount, 1)
+
+	solvedRate := float64(solvedCount) / float64(teamCount)
+	lossRate := float64(solvedCount-1) / float64(teamCount)
+	exponentialScore := float64(challenge.Score) * math.Exp((float64(challenge.Difficulty)-2)*lossRate)
+
+	parameters := make(map[string]interface{})
+	parameters["original_score"] = challenge.Score
+	parameters["solved_count"] = solvedCount
+	parameters["team_count"] = teamCount
+	parameters["difficulty"] = challenge.Difficulty
+	parameters["loss_rate"] = lossRate
+	parameters["solved_rate"] = solvedRate
+	parameters["unsolved_rate"] = 1 - solvedRate
+	parameters["linear_score"] = float64(challenge.Score) * (1 - lossRate)
+	parameters["exponential_score"] = exponentialScore
+	parameters["order"] = order
+
+	f.exponentialScore = exponentialScore
+	result, err := f.expression.Evaluate(parameters)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to evaluate the score formula of challenge %s: %s", challenge.UUID, err.Error()))
+		return challenge.Score
+	}
+
+	score, ok := result.(float64)
+	if !ok {
+		return challenge.Score
+	}
+
+	return int(math.Round(score))
+}
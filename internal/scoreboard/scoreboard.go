@@ -0,0 +1,148 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scoreboard
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Solve of a challenge by a team
+type Solve struct {
+	SolvedAt int64 `json:"solved_at"`
+	Score    int   `json:"score"`
+
+	// Order of the solve in the challenge
+	Order int `json:"order"`
+
+	// Blood of the solve, 1~3 for the first three solves, 0 otherwise
+	Blood int `json:"blood"`
+}
+
+// Blood is one of the first three solves of a challenge
+type Blood struct {
+	Blood    int    `json:"blood"`
+	Team     string `json:"team"`
+	Name     string `json:"name"`
+	SolvedAt int64  `json:"solved_at"`
+}
+
+// blood returns the blood of the solve order
+func blood(order int) int {
+	if order >= 1 && order <= 3 {
+		return order
+	}
+	return 0
+}
+
+// Standing of a team in the scoreboard
+type Standing struct {
+	Rank      int    `json:"rank"`
+	TeamID    uint   `json:"-"`
+	Team      string `json:"team"`
+	Name      string `json:"name"`
+	Score     int    `json:"score"`
+	LastSolve int64  `json:"last_solve"`
+
+	// Solves of the team, keyed by the challenge UUID
+	Solves map[string]*Solve `json:"solves"`
+
+	// Total cost of the hints unlocked by the team, deducted from the score
+	Penalty   int        `json:"penalty"`
+	Penalties []*Penalty `json:"-"`
+
+	// Total score of the sub flags found in the unsolved challenges
+	Partial int     `json:"partial"`
+	Parts   []*Part `json:"-"`
+}
+
+// Penalty is the cost of a hint unlocked by a team
+type Penalty struct {
+	UnlockedAt int64
+	Cost       int
+}
+
+// Part is the score of a sub flag found by a team in an unsolved challenge
+type Part struct {
+	SolvedAt int64
+	Score    int
+}
+
+// Delta is the new score and rank of a team after a change of the scoreboard,
+// rank is 0 if the team is not ranked
+type Delta struct {
+	Team  string `json:"team"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	Rank  int    `json:"rank"`
+}
+
+// Point of the score timeline
+type Point struct {
+	Time  int64 `json:"time"`
+	Score int   `json:"score"`
+}
+
+// Series is the score-over-time of a team
+type Series struct {
+	Team   string  `json:"team"`
+	Name   string  `json:"name"`
+	Points []Point `json:"points"`
+}
+
+// Scoreboard is an immutable snapshot of the standings
+type Scoreboard struct {
+	// Standings of the ranked teams, sorted by rank
+	Standings []*Standing
+}
+
+// Page returns the standings of the page, page starts from 1
+func (sb *Scoreboard) Page(page int, size int) []*Standing {
+	start := (page - 1) * size
+	if start >= len(sb.Standings) || start < 0 {
+		return []*Standing{}
+	}
+	return sb.Standings[start:min(start+size, len(sb.Standings))]
+}
+
+// Timeline returns the score-over-time series of the top n teams
+func (sb *Scoreboard) Timeline(n int) []*Series {
+	timeline := make([]*Series, 0, n)
+	for _, standing := range sb.Standings[:min(n, len(sb.Standings))] {
+		// solves, unlocked hints and found sub flags as score changes
+		changes := make([]Point, 0, len(standing.Solves)+len(standing.Penalties)+len(standing.Parts))
+		for _, solve := range standing.Solves {
+			changes = append(changes, Point{Time: solve.SolvedAt, Score: solve.Score})
+		}
+		for _, penalty := range standing.Penalties {
+			changes = append(changes, Point{Time: penalty.UnlockedAt, Score: -penalty.Cost})
+		}
+		for _, part := range standing.Parts {
+			changes = append(changes, Point{Time: part.SolvedAt, Score: part.Score})
+		}
+		slices.SortStableFunc(changes, func(a, b Point) int {
+			return cmp.Compare(a.Time, b.Time)
+		})
+
+		series := &Series{Team: standing.Team, Name: standing.Name, Points: make([]Point, 0, len(changes))}
+		score := 0
+		for _, change := range changes {
+			score += change.Score
+			series.Points = append(series.Points, Point{Time: change.Time, Score: score})
+		}
+		timeline = append(timeline, series)
+	}
+	return timeline
+}
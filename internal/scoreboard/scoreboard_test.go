@@ -0,0 +1,77 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scoreboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/store"
+)
+
+func newTeam(id uint, name string) *store.Team {
+	team := &store.Team{Name: name, UUID: name}
+	team.ID = id
+	return team
+}
+
+func newChallenge(id uint, name string) *store.Challenge {
+	challenge := &store.Challenge{Name: name, UUID: name, Score: 100}
+	challenge.ID = id
+	return challenge
+}
+
+var flagID uint
+
+func newFlag(team *store.Team, challenge *store.Challenge, solvedAt int64, score int) *store.Flag {
+	flagID++
+	flag := &store.Flag{
+		State:       store.FlagSolved,
+		SolvedAt:    solvedAt,
+		Score:       score,
+		TeamID:      team.ID,
+		Team:        team,
+		ChallengeID: challenge.ID,
+		Challenge:   challenge,
+	}
+	flag.ID = flagID
+	return flag
+}
+
+func load(game *store.Game, teams []*store.Team, flags []*store.Flag) *Board {
+	board := NewBoard(game)
+	board.Load(teams, flags, nil, nil)
+	return board
+}
+
+func TestPageAndTimeline(t *testing.T) {
+	alice, bob := newTeam(1, "alice"), newTeam(2, "bob")
+	web, pwn := newChallenge(1, "web"), newChallenge(2, "pwn")
+
+	sb := load(&store.Game{}, []*store.Team{alice, bob}, []*store.Flag{
+		newFlag(alice, web, 100, 100),
+		newFlag(alice, pwn, 200, 200),
+		newFlag(bob, web, 300, 100),
+	}).Scoreboard(false)
+
+	assert.Len(t, sb.Page(1, 1), 1)
+	assert.Equal(t, "bob", sb.Page(2, 1)[0].Team)
+	assert.Empty(t, sb.Page(3, 1))
+
+	timeline := sb.Timeline(1)
+	assert.Len(t, timeline, 1)
+	assert.Equal(t, []Point{{Time: 100, Score: 100}, {Time: 200, Score: 300}}, timeline[0].Points)
+	assert.Len(t, sb.Timeline(10), 2)
+}
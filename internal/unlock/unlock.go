@@ -0,0 +1,145 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package unlock evaluates the prerequisites of the challenges, a challenge is unlocked
+// once all the challenges it requires are solved and the team reaches its score threshold.
+package unlock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"rina.icu/hoshino/store"
+)
+
+var (
+	ErrSelfRequired     = errors.New("challenge requires itself")
+	ErrUnknownChallenge = errors.New("required challenge does not exist in the game")
+	ErrNegativeScore    = errors.New("required score should not be negative")
+	ErrCycle            = errors.New("prerequisites contain a cycle")
+)
+
+// Progress of a team, a nil progress unlocks every challenge
+type Progress struct {
+	// UUIDs of the solved challenges
+	Solved map[string]bool
+
+	// Score of the team in the live scoreboard
+	Score int
+}
+
+func NewProgress(solved []string, score int) *Progress {
+	p := &Progress{Solved: make(map[string]bool, len(solved)), Score: score}
+	for _, uuid := range solved {
+		p.Solved[uuid] = true
+	}
+	return p
+}
+
+// Unlocked reports whether all the prerequisites of the challenge are met
+func (p *Progress) Unlocked(c *store.Challenge) bool {
+	if p == nil {
+		return true
+	}
+
+	if p.Score < c.RequiredScore {
+		return false
+	}
+
+	for _, uuid := range c.Requires {
+		if !p.Solved[uuid] {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the prerequisites of the challenges of a game only refer to
+// the challenges of the same game and contain no cycle
+func Validate(challenges []*store.Challenge) error {
+	nodes := make(map[string]*store.Challenge, len(challenges))
+	for _, c := range challenges {
+		nodes[c.UUID] = c
+	}
+
+	for _, c := range challenges {
+		if c.RequiredScore < 0 {
+			return fmt.Errorf("%w: %s", ErrNegativeScore, c.Name)
+		}
+
+		for _, uuid := range c.Requires {
+			if uuid == c.UUID {
+				return fmt.Errorf("%w: %s", ErrSelfRequired, c.Name)
+			}
+			if _, ok := nodes[uuid]; !ok {
+				return fmt.Errorf("%w: %s", ErrUnknownChallenge, uuid)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(challenges))
+	path := make([]*store.Challenge, 0)
+
+	var visit func(c *store.Challenge) error
+	visit = func(c *store.Challenge) error {
+		state[c.UUID] = visiting
+		path = append(path, c)
+
+		for _, uuid := range c.Requires {
+			next := nodes[uuid]
+			switch state[uuid] {
+			case visiting:
+				return fmt.Errorf("%w: %s", ErrCycle, cycle(path, next))
+			case unvisited:
+				if err := visit(next); err != nil {
+					return err
+				}
+			}
+		}
+
+		state[c.UUID] = visited
+		path = path[:len(path)-1]
+		return nil
+	}
+
+	for _, c := range challenges {
+		if state[c.UUID] == unvisited {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cycle formats the cycle in the path which ends at the given challenge
+func cycle(path []*store.Challenge, end *store.Challenge) string {
+	names := make([]string, 0, len(path)+1)
+	for i, c := range path {
+		if c.UUID == end.UUID {
+			for _, c := range path[i:] {
+				names = append(names, c.Name)
+			}
+			break
+		}
+	}
+	return strings.Join(append(names, end.Name), " -> ")
+}
@@ -0,0 +1,80 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unlock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/store"
+)
+
+func challenge(uuid string, requires ...string) *store.Challenge {
+	return &store.Challenge{UUID: uuid, Name: uuid, Requires: requires}
+}
+
+func TestUnlocked(t *testing.T) {
+	web2 := challenge("web-2", "web-1")
+	web3 := challenge("web-3", "web-1", "web-2")
+	web3.RequiredScore = 300
+
+	p := NewProgress(nil, 0)
+	assert.True(t, p.Unlocked(challenge("web-1")))
+	assert.False(t, p.Unlocked(web2))
+
+	p = NewProgress([]string{"web-1"}, 100)
+	assert.True(t, p.Unlocked(web2))
+	assert.False(t, p.Unlocked(web3))
+
+	p = NewProgress([]string{"web-1", "web-2"}, 200)
+	assert.False(t, p.Unlocked(web3))
+
+	p = NewProgress([]string{"web-1", "web-2"}, 300)
+	assert.True(t, p.Unlocked(web3))
+
+	// managers unlock everything
+	var none *Progress
+	assert.True(t, none.Unlocked(web3))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate([]*store.Challenge{
+		challenge("web-1"),
+		challenge("web-2", "web-1"),
+		challenge("web-3", "web-1", "web-2"),
+		challenge("pwn-1", "web-3"),
+	}))
+
+	assert.ErrorIs(t, Validate([]*store.Challenge{
+		challenge("web-1", "web-1"),
+	}), ErrSelfRequired)
+
+	assert.ErrorIs(t, Validate([]*store.Challenge{
+		challenge("web-1", "web-0"),
+	}), ErrUnknownChallenge)
+
+	negative := challenge("web-1")
+	negative.RequiredScore = -1
+	assert.ErrorIs(t, Validate([]*store.Challenge{negative}), ErrNegativeScore)
+
+	err := Validate([]*store.Challenge{
+		challenge("misc-1"),
+		challenge("web-1", "misc-1", "web-3"),
+		challenge("web-2", "web-1"),
+		challenge("web-3", "web-2"),
+	})
+	assert.ErrorIs(t, err, ErrCycle)
+	assert.Contains(t, err.Error(), "web-1 -> web-3 -> web-2 -> web-1")
+}
@@ -15,8 +15,16 @@
 package util
 
 import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"golang.org/x/exp/rand"
@@ -52,12 +60,13 @@ var leetMap = map[rune][]rune{
 }
 
 func Leetify(input string, seed uint64) string {
-	rand.Seed(seed)
+	// a generator per call, the global one is shared by the concurrent container creations
+	r := rand.New(rand.NewSource(seed))
 
 	var result strings.Builder
 	for _, char := range input {
 		if leetChars, ok := leetMap[unicode.ToUpper(char)]; ok {
-			result.WriteRune(leetChars[rand.Intn(len(leetChars))])
+			result.WriteRune(leetChars[r.Intn(len(leetChars))])
 		} else {
 			result.WriteRune(char)
 		}
@@ -66,21 +75,165 @@ func Leetify(input string, seed uint64) string {
 	return result.String()
 }
 
-func GenerateFlagContent(format string, seed string) string {
-	// Use [] to wrap the string to be leetified
-	// Use {hash} to insert the sha256ed seed
-	// Use {uuid} to insert a random uuid
+// FlagParams are filled into the placeholders of a flag template
+type FlagParams struct {
+	// Seed of {hash}, {hex:N}, {hmac:N} and the leetified parts, the same seed gives the same flag
+	Seed string
+
+	// Name of the team, {team}
+	Team string
+
+	// Key of {hmac:N}, the flag secret of the challenge
+	Key string
+
+	// Time of {time}
+	Time time.Time
+}
+
+// maxFlagPlaceholderLength is the maximum N of {rand:N}, {hex:N} and {hmac:N}, the length of a hex SHA-256
+const maxFlagPlaceholderLength = 64
+
+const flagRandLetters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+var (
+	flagPlaceholderRe = regexp.MustCompile(`\{([a-z]+)(?::([^{}]*))?\}`)
+	flagLeetRe        = regexp.MustCompile(`\[([^\[\]]+)\]`)
+	flagTeamRe        = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+)
+
+// flagPlaceholderLength parses N of the placeholders with a length
+func flagPlaceholderLength(name string, arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > maxFlagPlaceholderLength {
+		return 0, fmt.Errorf("length of {%s:N} should be between 1 and %d", name, maxFlagPlaceholderLength)
+	}
+	return n, nil
+}
 
-	leetRe := regexp.MustCompile(`\[([^\[\]]+)\]`)
+// randomFlagText returns n cryptographically random letters and digits
+func randomFlagText(n int) string {
+	// the bytes beyond the last full round of the letters are dropped to keep it uniform
+	limit := byte(256 - 256%len(flagRandLetters))
 
-	result := format
-	result = strings.ReplaceAll(result, "{hash}", SHA256(seed)[:8])
-	result = strings.ReplaceAll(result, "{uuid}", UUID())
+	b := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(b) < n {
+		crand.Read(buf)
+		for _, c := range buf {
+			if c < limit && len(b) < n {
+				b = append(b, flagRandLetters[int(c)%len(flagRandLetters)])
+			}
+		}
+	}
+	return string(b)
+}
 
-	leet := leetRe.FindAllString(result, -1)
-	for _, l := range leet {
-		result = strings.ReplaceAll(result, l, Leetify(l[1:len(l)-1], SHA256Uint64(seed)))
+// renderFlagPlaceholder returns the content of the placeholder, false if it's not a placeholder
+func renderFlagPlaceholder(name string, arg string, params *FlagParams) (string, bool, error) {
+	switch name {
+	case "hash", "uuid", "team", "time":
+		if arg != "" {
+			return "", true, fmt.Errorf("{%s} takes no argument", name)
+		}
+	case "rand", "hex", "hmac":
+	default:
+		return "", false, nil
 	}
 
-	return result
+	switch name {
+	case "hash":
+		return SHA256(params.Seed)[:8], true, nil
+	case "uuid":
+		return UUID(), true, nil
+	case "team":
+		return flagTeamRe.ReplaceAllString(params.Team, "_"), true, nil
+	case "time":
+		return strconv.FormatInt(params.Time.Unix(), 10), true, nil
+	}
+
+	n, err := flagPlaceholderLength(name, arg)
+	if err != nil {
+		return "", true, err
+	}
+
+	switch name {
+	case "rand":
+		return randomFlagText(n), true, nil
+	case "hex":
+		return SHA256(params.Seed)[:n], true, nil
+	default:
+		mac := hmac.New(sha256.New, []byte(params.Key))
+		mac.Write([]byte(params.Seed))
+		return hex.EncodeToString(mac.Sum(nil))[:n], true, nil
+	}
+}
+
+// RenderFlag fills the flag template, use [] to wrap the text to be leetified and the placeholders:
+//
+//	{hash}    the first 8 hex digits of the SHA-256 of the seed
+//	{uuid}    a random UUID
+//	{team}    the team name, characters other than letters, digits, _ and - are replaced by _
+//	{rand:N}  N random letters and digits
+//	{hex:N}   the first N hex digits of the SHA-256 of the seed
+//	{hmac:N}  the first N hex digits of the HMAC-SHA256 of the seed with the key
+//	{time}    the unix time in seconds
+//
+// Unknown placeholders are kept as they are, see ValidateFlagTemplate
+func RenderFlag(format string, params FlagParams) string {
+	result := flagPlaceholderRe.ReplaceAllStringFunc(format, func(placeholder string) string {
+		m := flagPlaceholderRe.FindStringSubmatch(placeholder)
+		content, ok, err := renderFlagPlaceholder(m[1], m[2], &params)
+		if !ok || err != nil {
+			return placeholder
+		}
+		return content
+	})
+
+	seed := SHA256Uint64(params.Seed)
+	return flagLeetRe.ReplaceAllStringFunc(result, func(leet string) string {
+		return Leetify(leet[1:len(leet)-1], seed)
+	})
+}
+
+// ValidateFlagTemplate checks the placeholders of the flag template
+func ValidateFlagTemplate(format string) error {
+	params := FlagParams{}
+	for _, m := range flagPlaceholderRe.FindAllStringSubmatch(format, -1) {
+		_, ok, err := renderFlagPlaceholder(m[1], m[2], &params)
+		if !ok {
+			return fmt.Errorf("unknown placeholder %s", m[0])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateStatelessFlagTemplate checks that the flag template is keyed by {hmac:N} and renders
+// the same flag for the same seed and key, so that the flags can be derived again when submitted
+func ValidateStatelessFlagTemplate(format string) error {
+	if err := ValidateFlagTemplate(format); err != nil {
+		return err
+	}
+
+	keyed := false
+	for _, m := range flagPlaceholderRe.FindAllStringSubmatch(format, -1) {
+		switch m[1] {
+		case "hmac":
+			keyed = true
+		case "rand", "uuid", "time", "team":
+			return fmt.Errorf("%s may change between the renders", m[0])
+		}
+	}
+
+	if !keyed {
+		return errors.New("{hmac:N} is required")
+	}
+	return nil
+}
+
+// GenerateFlagContent fills the flag template with the seed only, see RenderFlag
+func GenerateFlagContent(format string, seed string) string {
+	return RenderFlag(format, FlagParams{Seed: seed, Time: time.Now()})
 }
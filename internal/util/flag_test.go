@@ -15,7 +15,10 @@
 package util
 
 import (
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -40,3 +43,67 @@ func TestFlag(t *testing.T) {
 	assert.NotEqual(t, flag4, flag5, "they should not be equal")
 
 }
+
+func TestRenderFlag(t *testing.T) {
+	params := FlagParams{Seed: "seed", Team: "Team Rocket!", Key: "key", Time: time.Unix(1700000000, 0)}
+
+	assert.Equal(t, "Team_Rocket__1700000000", RenderFlag("{team}_{time}", params))
+	assert.Equal(t, SHA256("seed")[:8], RenderFlag("{hash}", params))
+	assert.Equal(t, SHA256("seed")[:12], RenderFlag("{hex:12}", params))
+	assert.Regexp(t, regexp.MustCompile(`^[A-Za-z0-9]{20}$`), RenderFlag("{rand:20}", params))
+	assert.NotEqual(t, RenderFlag("{rand:20}", params), RenderFlag("{rand:20}", params))
+
+	// the hmac depends on the key
+	hmac := RenderFlag("{hmac:16}", params)
+	assert.Len(t, hmac, 16)
+	assert.Equal(t, hmac, RenderFlag("{hmac:16}", params))
+	params.Key = "another"
+	assert.NotEqual(t, hmac, RenderFlag("{hmac:16}", params))
+
+	// unknown or invalid placeholders are kept
+	assert.Equal(t, "{seed}_{hex:0}", RenderFlag("{seed}_{hex:0}", params))
+}
+
+func TestRenderFlagConcurrently(t *testing.T) {
+	format := "[takanashi_hoshino_is_super_kawaii]_{hash}"
+	expected := GenerateFlagContent(format, "random_seed")
+
+	var wg sync.WaitGroup
+	results := make([]string, 64)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seed := "random_seed"
+			if i%2 == 1 {
+				seed = "another_seed"
+			}
+			results[i] = GenerateFlagContent(format, seed)
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < len(results); i += 2 {
+		assert.Equal(t, expected, results[i])
+	}
+}
+
+func TestValidateFlagTemplate(t *testing.T) {
+	assert.NoError(t, ValidateFlagTemplate("[leet]_{team}_{rand:8}_{hex:64}_{hmac:16}_{time}_{uuid}"))
+	assert.NoError(t, ValidateFlagTemplate("static_flag"))
+
+	assert.Error(t, ValidateFlagTemplate("{seed}"))
+	assert.Error(t, ValidateFlagTemplate("{rand}"))
+	assert.Error(t, ValidateFlagTemplate("{hex:65}"))
+	assert.Error(t, ValidateFlagTemplate("{hmac:abc}"))
+	assert.Error(t, ValidateFlagTemplate("{team:1}"))
+}
+
+func TestValidateStatelessFlagTemplate(t *testing.T) {
+	assert.NoError(t, ValidateStatelessFlagTemplate("[leet]_{hash}_{hmac:32}"))
+
+	assert.Error(t, ValidateStatelessFlagTemplate("[leet]_{hash}"))
+	assert.Error(t, ValidateStatelessFlagTemplate("{hmac:32}_{rand:4}"))
+	assert.Error(t, ValidateStatelessFlagTemplate("{hmac:32}_{team}"))
+	assert.Error(t, ValidateStatelessFlagTemplate("{hmac:99}"))
+}
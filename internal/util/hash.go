@@ -15,7 +15,9 @@
 package util
 
 import (
+	crand "crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strconv"
 	"time"
@@ -44,6 +46,13 @@ func SHA256WithSalt(secret string, salt string) string {
 	return SHA256(secret + salt)
 }
 
+// RandomHex returns n cryptographically random bytes in hex, used for secrets
+func RandomHex(n int) string {
+	b := make([]byte, n)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func GenerateRandomText(length int32) string {
 	const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	b := make([]byte, length)
@@ -0,0 +1,91 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// AllowPrivateAddresses allows the outgoing requests to the URLs set by the game managers
+// to reach the loopback and private addresses, for the receivers in tests
+var AllowPrivateAddresses = false
+
+var ErrPrivateAddress = errors.New("private address is not allowed")
+
+// publicAddr reports whether the address is reachable from the internet
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsUnspecified() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsMulticast()
+}
+
+// checkPublic refuses the connections to the non-public addresses, after the host has been resolved
+func checkPublic(network string, address string, _ syscall.RawConn) error {
+	if AllowPrivateAddresses {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !publicAddr(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// PublicClient returns a HTTP client which only connects to the public addresses,
+// used for the URLs set by the game managers. Redirects are checked by the same dialer.
+func PublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: checkPublic}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// CheckPublicURL checks that the URL is http(s) and is not of a loopback or private host,
+// hosts resolved to such addresses are refused by PublicClient when connecting
+func CheckPublicURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("invalid URL")
+	}
+
+	if AllowPrivateAddresses {
+		return nil
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !publicAddr(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
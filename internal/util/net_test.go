@@ -0,0 +1,61 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPublicURL(t *testing.T) {
+	assert.NoError(t, CheckPublicURL("https://example.com/hook"))
+	assert.NoError(t, CheckPublicURL("http://93.184.216.34:8080"))
+
+	assert.Error(t, CheckPublicURL("ftp://example.com"))
+	assert.Error(t, CheckPublicURL("https://"))
+
+	for _, url := range []string{
+		"http://localhost:8080",
+		"http://127.0.0.1",
+		"http://[::1]/",
+		"http://10.0.0.1",
+		"http://192.168.1.1",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0",
+		"http://[::ffff:127.0.0.1]",
+	} {
+		assert.ErrorIs(t, CheckPublicURL(url), ErrPrivateAddress, url)
+	}
+}
+
+func TestPublicClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := PublicClient(time.Second)
+	_, err := client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrPrivateAddress)
+
+	AllowPrivateAddresses = true
+	defer func() { AllowPrivateAddresses = false }()
+
+	res, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	res.Body.Close()
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"rina.icu/hoshino/internal/k8s"
+	"rina.icu/hoshino/store"
+)
+
+// InitChallengeCron releases the hidden challenges at their start time and closes them
+// at their expire time. Only the times set ahead of the creation of the challenges are applied,
+// so that the existing challenges are left as they are.
+func InitChallengeCron(s *store.Store, cm *k8s.ContainerManager) {
+	slog.Info("Initializing challenge cron job")
+
+	c := cron.New(cron.WithSeconds(), cron.WithChain(cron.SkipIfStillRunning(cron.DefaultLogger)))
+
+	c.AddFunc("@every 10s", func() {
+		now := time.Now().UnixMilli()
+		releaseChallenges(s, now)
+		closeChallenges(s, cm, now)
+	})
+
+	c.Start()
+}
+
+func releaseChallenges(s *store.Store, now int64) {
+	challenges, err := s.GetChallengesToRelease(now)
+	if err != nil {
+		slog.Error("Failed to get challenges to release: " + err.Error())
+		return
+	}
+
+	for _, challenge := range challenges {
+		state := challenge.State
+		staged := challenge.Staged(challenge.StartTime)
+		if staged {
+			state = store.ChallengeStateVisible
+		}
+
+		released, err := s.ReleaseChallenge(challenge, state, now)
+		if err != nil {
+			slog.Error("Failed to release challenge: " + err.Error())
+			continue
+		}
+
+		if !released || !staged {
+			continue
+		}
+
+		if err := s.CreateGameEvent(&store.GameEvent{
+			Content:    fmt.Sprintf("Challenge `%s` has been released", challenge.Name),
+			Game:       challenge.Game,
+			Challenge:  challenge,
+			Visibility: true,
+			Type:       store.GameEventTypeAnnouncement,
+		}); err != nil {
+			slog.Error("Failed to create release event: " + err.Error())
+		}
+	}
+}
+
+// closeChallenges hides the challenges allowing nothing after the expire time,
+// and disposes their containers if creating containers is not allowed
+func closeChallenges(s *store.Store, cm *k8s.ContainerManager, now int64) {
+	challenges, err := s.GetChallengesToClose(now)
+	if err != nil {
+		slog.Error("Failed to get challenges to close: " + err.Error())
+		return
+	}
+
+	for _, challenge := range challenges {
+		state := challenge.State
+		staged := challenge.Staged(challenge.ExpireTime)
+		if staged && challenge.AfterExpiredOperations == store.AfterExpireDisableAll &&
+			challenge.State == store.ChallengeStateVisible {
+			state = store.ChallengeStateHidden
+		}
+
+		closed, err := s.CloseChallenge(challenge, state, now)
+		if err != nil {
+			slog.Error("Failed to close challenge: " + err.Error())
+			continue
+		}
+
+		if !closed || !staged || challenge.NoContainer || challenge.AfterExpiredOperations&store.AfterExpireCreateContainer != 0 {
+			continue
+		}
+
+		containers, err := s.GetRunningContainersByChallenge(challenge)
+		if err != nil {
+			slog.Error("Failed to get containers: " + err.Error())
+			continue
+		}
+
+		for _, container := range containers {
+			if err := cm.DisposeContainer(container.Identifier); err != nil {
+				slog.Error("Failed to delete container: " + err.Error())
+			}
+
+			container.Status = store.ContainerStatusStopped
+			if err := s.UpdateContainer(container); err != nil {
+				slog.Error("Failed to update container: " + err.Error())
+			}
+		}
+	}
+}
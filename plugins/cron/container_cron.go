@@ -15,6 +15,7 @@
 package cron
 
 import (
+	"fmt"
 	"log/slog"
 
 	"github.com/robfig/cron/v3"
@@ -43,6 +44,21 @@ func InitContainerCron(s *store.Store, cm *k8s.ContainerManager) {
 			if err := s.UpdateContainer(container); err != nil {
 				slog.Error("Failed to delete container: " + err.Error())
 			}
+
+			if container.Creator == nil || container.Challenge == nil || container.Challenge.Game == nil {
+				// test containers don't belong to any challenge
+				continue
+			}
+
+			if err := s.NotifyUsers([]*store.User{container.Creator}, store.Notification{
+				GameID:   container.Challenge.GameID,
+				GameUUID: container.Challenge.Game.UUID,
+				Type:     store.NotificationTypeContainerExpired,
+				Title:    "Container expired",
+				Content:  fmt.Sprintf("Your container of challenge `%s` has expired and been destroyed.", container.Challenge.Name),
+			}); err != nil {
+				slog.Error("Failed to notify the container creator: " + err.Error())
+			}
 		}
 	})
 
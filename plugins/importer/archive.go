@@ -0,0 +1,230 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxExtractSize limits the total size of the files extracted from an archive
+const MaxExtractSize = 512 << 20
+
+var ErrArchiveTooLarge = errors.New("archive is too large")
+
+// Extract extracts the zip, tar or gzipped tar archive into the directory,
+// only the regular files and the directories are extracted
+func Extract(archive string, dir string) error {
+	name := strings.ToLower(archive)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return extractZip(archive, dir)
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		f, err := os.Open(archive)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		return extractTar(gz, dir)
+	case strings.HasSuffix(name, ".tar"):
+		f, err := os.Open(archive)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return extractTar(f, dir)
+	default:
+		return fmt.Errorf("unsupported archive %s", filepath.Base(archive))
+	}
+}
+
+// target returns the path of the entry in the directory, entries escaping the directory are rejected
+func target(dir string, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(name) {
+		return "", fmt.Errorf("invalid entry %s in the archive", name)
+	}
+	return path, nil
+}
+
+// write copies at most the remaining quota of bytes into the file
+func write(path string, r io.Reader, quota *int64) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(r, *quota+1))
+	if err != nil {
+		return err
+	}
+	*quota -= n
+	if *quota < 0 {
+		return ErrArchiveTooLarge
+	}
+	return nil
+}
+
+func extractZip(archive string, dir string) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	quota := int64(MaxExtractSize)
+	for _, file := range reader.File {
+		path, err := target(dir, file.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := file.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				return err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = write(path, rc, &quota)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTar(r io.Reader, dir string) error {
+	reader := tar.NewReader(r)
+
+	quota := int64(MaxExtractSize)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path, err := target(dir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := write(path, reader, &quota); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// archiveWriter writes the files into an archive
+type archiveWriter interface {
+	add(name string, r io.Reader, size int64) error
+	Close() error
+}
+
+// newArchiveWriter returns a writer of the format, zip or tar.gz
+func newArchiveWriter(w io.Writer, format string) (archiveWriter, error) {
+	switch format {
+	case "zip":
+		return &zipWriter{zip.NewWriter(w)}, nil
+	case "tar.gz", "tgz":
+		gz := gzip.NewWriter(w)
+		return &tarWriter{gz: gz, tw: tar.NewWriter(gz)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported archive format %s", format)
+	}
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) add(name string, r io.Reader, _ int64) error {
+	f, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+type tarWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (w *tarWriter) add(name string, r io.Reader, size int64) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		Typeflag: tar.TypeReg,
+		ModTime:  time.Now(),
+	}
+	if err := w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+func (w *tarWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
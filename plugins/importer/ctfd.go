@@ -0,0 +1,625 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/store"
+)
+
+// tables of a CTFd export, stored as db/<table>.json
+type ctfdTable[T any] struct {
+	Results []T `json:"results"`
+}
+
+type ctfdChallenge struct {
+	ID             int             `json:"id"`
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	ConnectionInfo string          `json:"connection_info"`
+	MaxAttempts    int             `json:"max_attempts"`
+	Value          int             `json:"value"`
+	Category       string          `json:"category"`
+	Type           string          `json:"type"`
+	State          string          `json:"state"`
+	Requirements   json.RawMessage `json:"requirements"`
+}
+
+type ctfdDynamicChallenge struct {
+	ID       int    `json:"id"`
+	Initial  int    `json:"initial"`
+	Minimum  int    `json:"minimum"`
+	Decay    int    `json:"decay"`
+	Function string `json:"function"`
+}
+
+type ctfdFlag struct {
+	ChallengeID int    `json:"challenge_id"`
+	Type        string `json:"type"`
+	Content     string `json:"content"`
+	Data        string `json:"data"`
+}
+
+type ctfdHint struct {
+	ChallengeID  int             `json:"challenge_id"`
+	Content      string          `json:"content"`
+	Cost         int             `json:"cost"`
+	Requirements json.RawMessage `json:"requirements"`
+}
+
+type ctfdFile struct {
+	Type        string `json:"type"`
+	Location    string `json:"location"`
+	ChallengeID int    `json:"challenge_id"`
+}
+
+type ctfdTag struct {
+	ChallengeID int    `json:"challenge_id"`
+	Value       string `json:"value"`
+}
+
+type ctfdUser struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Type   string `json:"type"`
+	Hidden bool   `json:"hidden"`
+	Banned bool   `json:"banned"`
+	TeamID int    `json:"team_id"`
+}
+
+type ctfdTeam struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Hidden    bool   `json:"hidden"`
+	Banned    bool   `json:"banned"`
+	CaptainID int    `json:"captain_id"`
+}
+
+type ctfdSubmission struct {
+	ID          int    `json:"id"`
+	ChallengeID int    `json:"challenge_id"`
+	UserID      int    `json:"user_id"`
+	TeamID      int    `json:"team_id"`
+	Type        string `json:"type"`
+	Date        string `json:"date"`
+}
+
+type ctfdConfig struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ctfdExport is the content of an extracted CTFd export
+type ctfdExport struct {
+	challenges  []ctfdChallenge
+	dynamic     []ctfdDynamicChallenge
+	flags       []ctfdFlag
+	hints       []ctfdHint
+	files       []ctfdFile
+	tags        []ctfdTag
+	users       []ctfdUser
+	teams       []ctfdTeam
+	solves      []ctfdSubmission
+	submissions []ctfdSubmission
+	awards      []json.RawMessage
+	unlocks     []json.RawMessage
+	config      map[string]string
+}
+
+// readCTFdTable reads the table of the export, the missing tables are empty
+func readCTFdTable[T any](dir string, name string, results *[]T) error {
+	data, err := os.ReadFile(filepath.Join(dir, "db", name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var table ctfdTable[T]
+	if err := json.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("invalid table %s: %w", name, err)
+	}
+	*results = table.Results
+	return nil
+}
+
+func loadCTFd(dir string) (*ctfdExport, error) {
+	if _, err := os.Stat(filepath.Join(dir, "db", "challenges.json")); err != nil {
+		return nil, fmt.Errorf("not a CTFd export: %w", err)
+	}
+
+	e := &ctfdExport{config: map[string]string{}}
+	var config []ctfdConfig
+	for _, err := range []error{
+		readCTFdTable(dir, "challenges", &e.challenges),
+		readCTFdTable(dir, "dynamic_challenge", &e.dynamic),
+		readCTFdTable(dir, "flags", &e.flags),
+		readCTFdTable(dir, "hints", &e.hints),
+		readCTFdTable(dir, "files", &e.files),
+		readCTFdTable(dir, "tags", &e.tags),
+		readCTFdTable(dir, "users", &e.users),
+		readCTFdTable(dir, "teams", &e.teams),
+		readCTFdTable(dir, "solves", &e.solves),
+		readCTFdTable(dir, "submissions", &e.submissions),
+		readCTFdTable(dir, "awards", &e.awards),
+		readCTFdTable(dir, "unlocks", &e.unlocks),
+		readCTFdTable(dir, "config", &config),
+	} {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config {
+		e.config[c.Key] = c.Value
+	}
+
+	// the solves only keep the IDs of the submissions in the newer exports
+	submissions := make(map[int]ctfdSubmission, len(e.submissions))
+	for _, submission := range e.submissions {
+		submissions[submission.ID] = submission
+	}
+	for i, solve := range e.solves {
+		if submission, ok := submissions[solve.ID]; ok {
+			if solve.Date == "" {
+				e.solves[i].Date = submission.Date
+			}
+			if solve.ChallengeID == 0 {
+				e.solves[i].ChallengeID = submission.ChallengeID
+			}
+			if solve.UserID == 0 {
+				e.solves[i].UserID = submission.UserID
+			}
+			if solve.TeamID == 0 {
+				e.solves[i].TeamID = submission.TeamID
+			}
+		}
+	}
+
+	return e, nil
+}
+
+// ctfdPrerequisites returns the IDs of the challenges required by the requirements,
+// which may be stored as an object or a JSON string
+func ctfdPrerequisites(raw json.RawMessage) []int {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		raw = json.RawMessage(s)
+	}
+
+	var requirements struct {
+		Prerequisites []int `json:"prerequisites"`
+	}
+	json.Unmarshal(raw, &requirements)
+	return requirements.Prerequisites
+}
+
+// ctfdTime parses the dates of CTFd in UTC into milliseconds, 0 if it's invalid
+func ctfdTime(date string) int64 {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999", "2006-01-02 15:04:05.999999"} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t.UnixMilli()
+		}
+	}
+	return 0
+}
+
+// linearFormula is the score formula of the CTFd dynamic challenges with the linear decay function
+func linearFormula(initial int, decay int, minimum int) string {
+	return fmt.Sprintf("max(%d - %d * (solved_count - 1), %d)", initial, decay, minimum)
+}
+
+// CTFdResult is the result of importing a CTFd export
+type CTFdResult struct {
+	UUID       string `json:"uuid"`
+	Name       string `json:"name"`
+	Challenges int    `json:"challenges"`
+	Teams      int    `json:"teams"`
+	Solves     int    `json:"solves"`
+
+	// the records of the export which could not be mapped
+	Unmapped []string `json:"unmapped"`
+}
+
+func (r *CTFdResult) unmapped(format string, args ...any) {
+	r.Unmapped = append(r.Unmapped, fmt.Sprintf(format, args...))
+}
+
+// ImportCTFd creates a new game from an extracted CTFd export. The users are matched by email or name
+// and never created, the solves of the unmatched users are kept for their teams. The game is created
+// as a hidden draft, named after the CTF unless the name is given, user is the creator of the game if set.
+// The solves are imported without scores, they should be rescored afterwards
+func ImportCTFd(s *store.Store, dir string, dataDir string, user *store.User, name string) (*CTFdResult, error) {
+	e, err := loadCTFd(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CTFdResult{Unmapped: []string{}}
+
+	game := &store.Game{
+		UUID:        util.UUID(),
+		Name:        e.config["ctf_name"],
+		Description: e.config["ctf_description"],
+		Status:      store.GameStatusInactive,
+		Visibility:  false,
+		StartTime:   cast.ToInt64(e.config["start"]) * 1000,
+		EndTime:     cast.ToInt64(e.config["end"]) * 1000,
+		FreezeTime:  cast.ToInt64(e.config["freeze"]) * 1000,
+		MaxTeamSize: cast.ToInt(e.config["team_size"]),
+		Managers:    []*store.User{},
+	}
+	if name != "" {
+		game.Name = name
+	}
+	if game.Name == "" {
+		return nil, errors.New("the name of the game is required")
+	}
+	if user != nil {
+		game.CreatorID = user.ID
+		game.Managers = append(game.Managers, user)
+	}
+
+	userMode := e.config["user_mode"] == "users"
+	if userMode {
+		game.MaxTeamSize = 1
+	}
+
+	records := &store.GameRecords{Attachments: map[string][]*store.Attachment{}}
+	cleanup := func() {
+		for _, attachments := range records.Attachments {
+			for _, attachment := range attachments {
+				os.Remove(attachment.SavePath)
+			}
+		}
+	}
+
+	challenges, err := importCTFdChallenges(e, dir, dataDir, user, records, result)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	teams := importCTFdTeams(s, e, userMode, game, records, result)
+	importCTFdSolves(e, userMode, challenges, teams, records, result)
+
+	if err := s.ImportGame(game, records); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("unable to create game %s: %w", game.Name, err)
+	}
+
+	result.UUID = game.UUID
+	result.Name = game.Name
+	result.Challenges = len(records.Challenges)
+	result.Teams = len(records.Teams)
+	result.Solves = len(records.Flags)
+	return result, nil
+}
+
+// importCTFdChallenges maps the challenges with their flags, hints, tags and files, returns the challenges by the IDs
+func importCTFdChallenges(e *ctfdExport, dir string, dataDir string, user *store.User, records *store.GameRecords, result *CTFdResult) (map[int]*store.Challenge, error) {
+	slices.SortFunc(e.challenges, func(a, b ctfdChallenge) int {
+		return a.ID - b.ID
+	})
+
+	challenges := make(map[int]*store.Challenge, len(e.challenges))
+	for i, ch := range e.challenges {
+		c := &store.Challenge{
+			UUID:        util.UUID(),
+			Name:        ch.Name,
+			Description: ch.Description,
+			Category:    ch.Category,
+			Score:       ch.Value,
+			Position:    i,
+			NoContainer: true,
+			State:       store.ChallengeStateHidden,
+			Tags:        []string{},
+			Hints:       []*store.Hint{},
+			Requires:    []string{},
+		}
+		if user != nil {
+			c.CreatorID = user.ID
+		}
+		if ch.State == "visible" {
+			c.State = store.ChallengeStateVisible
+		}
+		if ch.ConnectionInfo != "" {
+			c.Description = strings.TrimSpace(c.Description + "\n\nConnection: `" + ch.ConnectionInfo + "`")
+		}
+		if ch.MaxAttempts > 0 {
+			result.unmapped("challenge `%s`: the limit of %d attempts is dropped", ch.Name, ch.MaxAttempts)
+		}
+
+		switch ch.Type {
+		case "standard", "":
+		case "dynamic":
+			i := slices.IndexFunc(e.dynamic, func(d ctfdDynamicChallenge) bool { return d.ID == ch.ID })
+			if i < 0 {
+				result.unmapped("challenge `%s`: the dynamic scoring is missing, imported as a static challenge", ch.Name)
+				break
+			}
+
+			d := e.dynamic[i]
+			c.Score = d.Initial
+			if d.Function == "linear" {
+				c.ScoreFormula = linearFormula(d.Initial, d.Decay, d.Minimum)
+			} else {
+				c.ScoreFormula = dynamicFormula(d.Initial, max(d.Decay, 1), d.Minimum)
+			}
+		default:
+			result.unmapped("challenge `%s`: type %s is not supported, imported as a static challenge", ch.Name, ch.Type)
+		}
+
+		challenges[ch.ID] = c
+		records.Challenges = append(records.Challenges, c)
+	}
+
+	for _, ch := range e.challenges {
+		for _, id := range ctfdPrerequisites(ch.Requirements) {
+			if required, ok := challenges[id]; ok {
+				challenges[ch.ID].Requires = append(challenges[ch.ID].Requires, required.UUID)
+			} else {
+				result.unmapped("challenge `%s`: the required challenge %d is not found", ch.Name, id)
+			}
+		}
+	}
+
+	for _, f := range e.flags {
+		c, ok := challenges[f.ChallengeID]
+		switch {
+		case !ok:
+			result.unmapped("flag `%s`: the challenge %d is not found", f.Content, f.ChallengeID)
+		case c.FlagFormat != "":
+			result.unmapped("challenge `%s`: only the first flag is imported, flag `%s` is dropped", c.Name, f.Content)
+		default:
+			spec := FlagSpec{Type: f.Type, Content: f.Content, Data: f.Data}
+			match, flag, err := spec.Match()
+			if err != nil {
+				result.unmapped("challenge `%s`: flag `%s` is not imported: %s", c.Name, f.Content, err.Error())
+				continue
+			}
+			c.FlagFormat, c.FlagMatch = flag, match
+		}
+	}
+	for _, c := range records.Challenges {
+		if c.FlagFormat == "" {
+			result.unmapped("challenge `%s`: no flag is imported", c.Name)
+		}
+	}
+
+	for _, h := range e.hints {
+		c, ok := challenges[h.ChallengeID]
+		if !ok {
+			result.unmapped("hint `%s`: the challenge %d is not found", brief(h.Content), h.ChallengeID)
+			continue
+		}
+		if len(ctfdPrerequisites(h.Requirements)) > 0 {
+			result.unmapped("challenge `%s`: the requirements of hint `%s` are dropped", c.Name, brief(h.Content))
+		}
+		c.Hints = append(c.Hints, &store.Hint{UUID: util.UUID(), Content: h.Content, Cost: h.Cost})
+	}
+
+	for _, t := range e.tags {
+		if c, ok := challenges[t.ChallengeID]; ok {
+			c.Tags = append(c.Tags, t.Value)
+		}
+	}
+
+	attachmentDir := filepath.Join(dataDir, "attachments")
+	if err := os.MkdirAll(attachmentDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	for _, f := range e.files {
+		c, ok := challenges[f.ChallengeID]
+		if f.Type != "challenge" || !ok {
+			result.unmapped("file `%s`: only the files of the challenges are imported", f.Location)
+			continue
+		}
+
+		src, err := target(dir, path.Join("uploads", f.Location))
+		if err == nil {
+			err = regularFile(src)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("file %s of challenge %s: %w", f.Location, c.Name, err)
+		}
+
+		name := path.Base(f.Location)
+		attachment := &store.Attachment{
+			UUID:         util.UUID(),
+			Name:         name,
+			DownloadName: name,
+		}
+		if user != nil {
+			attachment.UploaderID = user.ID
+		}
+		attachment.SavePath = filepath.Join(attachmentDir, attachment.UUID)
+
+		if err := copyFile(src, attachment.SavePath); err != nil {
+			return nil, err
+		}
+		records.Attachments[c.UUID] = append(records.Attachments[c.UUID], attachment)
+	}
+
+	return challenges, nil
+}
+
+// importCTFdTeams maps the teams, or the users in the user mode, returns the teams by the IDs.
+// The users are matched by email or name, the unmatched ones are left out of their teams
+func importCTFdTeams(s *store.Store, e *ctfdExport, userMode bool, game *store.Game, records *store.GameRecords, result *CTFdResult) map[int]*store.Team {
+	matched := map[int]*store.User{}
+	admins := 0
+	for _, u := range e.users {
+		if u.Type == "admin" {
+			admins++
+			continue
+		}
+		if found := findUser(s, &UserEntry{Username: u.Name, Email: u.Email}); found != nil {
+			matched[u.ID] = found
+		} else {
+			result.unmapped("user `%s`: no user with the email or name is found", u.Name)
+		}
+	}
+	if admins > 0 {
+		result.unmapped("%d admin users and their solves are skipped", admins)
+	}
+
+	newTeam := func(name string, banned bool) *store.Team {
+		return &store.Team{
+			Name:     name,
+			UUID:     util.UUID(),
+			Banned:   banned,
+			Status:   store.TeamStatusApproved,
+			Members:  []*store.User{},
+			Managers: []*store.User{},
+		}
+	}
+
+	teams := map[int]*store.Team{}
+	if userMode {
+		for _, u := range e.users {
+			if u.Type == "admin" {
+				continue
+			}
+			if u.Hidden {
+				result.unmapped("user `%s`: hidden from the scoreboard in CTFd, imported as a ranked team", u.Name)
+			}
+
+			team := newTeam(u.Name, u.Banned)
+			if member, ok := matched[u.ID]; ok {
+				team.CreatorID = member.ID
+				team.Members = append(team.Members, member)
+				team.Managers = append(team.Managers, member)
+			}
+			teams[u.ID] = team
+			records.Teams = append(records.Teams, team)
+		}
+		return teams
+	}
+
+	for _, t := range e.teams {
+		if t.Hidden {
+			result.unmapped("team `%s`: hidden from the scoreboard in CTFd, imported as a ranked team", t.Name)
+		}
+
+		team := newTeam(t.Name, t.Banned)
+		if captain, ok := matched[t.CaptainID]; ok {
+			team.CreatorID = captain.ID
+			team.Managers = append(team.Managers, captain)
+		}
+		teams[t.ID] = team
+		records.Teams = append(records.Teams, team)
+	}
+
+	for _, u := range e.users {
+		member, ok := matched[u.ID]
+		if !ok {
+			continue
+		}
+		if team, ok := teams[u.TeamID]; ok {
+			team.Members = append(team.Members, member)
+		}
+	}
+
+	// the team size of CTFd is unlimited by default
+	if game.MaxTeamSize == 0 {
+		for _, team := range records.Teams {
+			game.MaxTeamSize = max(game.MaxTeamSize, len(team.Members), 1)
+		}
+	}
+
+	return teams
+}
+
+// importCTFdSolves maps the solves to the solved flags of the teams
+func importCTFdSolves(e *ctfdExport, userMode bool, challenges map[int]*store.Challenge, teams map[int]*store.Team, records *store.GameRecords, result *CTFdResult) {
+	users := make(map[int]ctfdUser, len(e.users))
+	for _, u := range e.users {
+		users[u.ID] = u
+	}
+
+	slices.SortFunc(e.solves, func(a, b ctfdSubmission) int {
+		return a.ID - b.ID
+	})
+
+	solved := map[*store.Team]map[*store.Challenge]bool{}
+	skipped := 0
+	for _, solve := range e.solves {
+		id := solve.TeamID
+		if userMode {
+			id = solve.UserID
+		}
+
+		challenge, team := challenges[solve.ChallengeID], teams[id]
+		if challenge == nil || team == nil || solved[team][challenge] {
+			if users[solve.UserID].Type != "admin" {
+				skipped++
+			}
+			continue
+		}
+
+		if solved[team] == nil {
+			solved[team] = map[*store.Challenge]bool{}
+		}
+		solved[team][challenge] = true
+
+		flag := &store.Flag{
+			Flag:      challenge.FlagFormat,
+			State:     store.FlagSolved,
+			SolvedAt:  ctfdTime(solve.Date),
+			Score:     -1,
+			Challenge: challenge,
+			Team:      team,
+		}
+		for _, member := range team.Members {
+			if u, ok := users[solve.UserID]; ok && (member.Email == u.Email || member.Username == u.Name) {
+				flag.SubmitterID = member.ID
+			}
+		}
+		records.Flags = append(records.Flags, flag)
+	}
+	if skipped > 0 {
+		result.unmapped("%d solves of unknown teams or challenges are skipped", skipped)
+	}
+
+	incorrect := 0
+	for _, submission := range e.submissions {
+		if submission.Type != "correct" {
+			incorrect++
+		}
+	}
+	if incorrect > 0 {
+		result.unmapped("%d incorrect submissions are not imported", incorrect)
+	}
+	if len(e.awards) > 0 {
+		result.unmapped("%d awards are not imported", len(e.awards))
+	}
+	if len(e.unlocks) > 0 {
+		result.unmapped("%d hint unlocks are not imported", len(e.unlocks))
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/server/config"
+	"rina.icu/hoshino/store"
+)
+
+var ctfdTables = map[string]string{
+	"config": `{"count": 5, "results": [
+		{"id": 1, "key": "ctf_name", "value": "Old CTF"},
+		{"id": 2, "key": "user_mode", "value": "teams"},
+		{"id": 3, "key": "start", "value": "1700000000"},
+		{"id": 4, "key": "end", "value": null},
+		{"id": 5, "key": "team_size", "value": "4"}
+	]}`,
+	"challenges": `{"count": 3, "results": [
+		{"id": 2, "name": "web-2", "description": "second", "value": 0, "category": "web", "type": "dynamic", "state": "visible",
+			"requirements": "{\"prerequisites\": [1]}", "connection_info": "nc example.com 1337"},
+		{"id": 1, "name": "web-1", "description": "first", "value": 100, "category": "web", "type": "standard", "state": "visible",
+			"requirements": null, "max_attempts": 5},
+		{"id": 3, "name": "misc", "description": "", "value": 50, "category": "misc", "type": "standard", "state": "hidden",
+			"requirements": {"prerequisites": [9]}}
+	]}`,
+	"dynamic_challenge": `{"count": 1, "results": [{"id": 2, "initial": 500, "minimum": 100, "decay": 10, "function": "logarithmic"}]}`,
+	"flags": `{"count": 4, "results": [
+		{"id": 1, "challenge_id": 1, "type": "static", "content": "flag{web-1}", "data": ""},
+		{"id": 2, "challenge_id": 2, "type": "static", "content": "flag{web-2}", "data": "case_insensitive"},
+		{"id": 3, "challenge_id": 2, "type": "static", "content": "flag{another}", "data": ""},
+		{"id": 4, "challenge_id": 3, "type": "regex", "content": "flag{.*}", "data": ""}
+	]}`,
+	"hints": `{"count": 1, "results": [{"id": 1, "type": "standard", "challenge_id": 2, "content": "look closer", "cost": 10, "requirements": null}]}`,
+	"tags":  `{"count": 1, "results": [{"id": 1, "challenge_id": 1, "value": "easy"}]}`,
+	"files": `{"count": 2, "results": [
+		{"id": 1, "type": "challenge", "location": "abc/source.zip", "challenge_id": 1, "page_id": null},
+		{"id": 2, "type": "page", "location": "def/logo.png", "challenge_id": null, "page_id": 1}
+	]}`,
+	"users": `{"count": 4, "results": [
+		{"id": 1, "name": "admin", "email": "admin@example.com", "type": "admin", "team_id": null},
+		{"id": 2, "name": "alice", "email": "alice@example.com", "type": "user", "team_id": 1},
+		{"id": 3, "name": "bob", "email": "bob@old.example.com", "type": "user", "team_id": 1},
+		{"id": 4, "name": "carol", "email": "carol@example.com", "type": "user", "team_id": 2}
+	]}`,
+	"teams": `{"count": 2, "results": [
+		{"id": 1, "name": "team-1", "captain_id": 2, "hidden": false, "banned": false},
+		{"id": 2, "name": "team-2", "captain_id": 4, "hidden": true, "banned": false}
+	]}`,
+	"submissions": `{"count": 5, "results": [
+		{"id": 1, "challenge_id": 1, "user_id": 2, "team_id": 1, "type": "correct", "date": "2023-11-14T22:20:00"},
+		{"id": 2, "challenge_id": 2, "user_id": 3, "team_id": 1, "type": "correct", "date": "2023-11-14T22:30:00.123456"},
+		{"id": 3, "challenge_id": 1, "user_id": 4, "team_id": 2, "type": "correct", "date": "2023-11-14T22:40:00+00:00"},
+		{"id": 4, "challenge_id": 2, "user_id": 4, "team_id": 2, "type": "incorrect", "date": "2023-11-14T22:50:00"},
+		{"id": 5, "challenge_id": 2, "user_id": 4, "team_id": 2, "type": "correct", "date": "2023-11-14T23:00:00"}
+	]}`,
+	"solves": `{"count": 4, "results": [{"id": 1}, {"id": 2}, {"id": 3}, {"id": 5}]}`,
+	"awards": `{"count": 1, "results": [{"id": 1, "user_id": 2, "team_id": 1, "name": "bonus", "value": 10}]}`,
+}
+
+func TestImportCTFd(t *testing.T) {
+	data := t.TempDir()
+	s, err := store.GetStore(&config.Config{Driver: "sqlite", DataDir: data})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.CreateUser(store.User{UUID: "admin", Username: "root", Email: "root@example.com"}))
+	assert.NoError(t, s.CreateUser(store.User{UUID: "alice", Username: "alice2", Email: "alice@example.com"}))
+	assert.NoError(t, s.CreateUser(store.User{UUID: "bob", Username: "bob", Email: "bob@example.com"}))
+	admin, _ := s.GetUserByUsername("root")
+	alice, _ := s.GetUserByUsername("alice2")
+	bob, _ := s.GetUserByUsername("bob")
+
+	dir := t.TempDir()
+	for name, table := range ctfdTables {
+		writeFile(t, filepath.Join(dir, "db", name+".json"), table)
+	}
+	writeFile(t, filepath.Join(dir, "uploads", "abc", "source.zip"), "source")
+
+	result, err := ImportCTFd(s, dir, data, admin, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Old CTF", result.Name)
+	assert.Equal(t, 3, result.Challenges)
+	assert.Equal(t, 2, result.Teams)
+	assert.Equal(t, 4, result.Solves)
+	assert.ElementsMatch(t, []string{
+		"challenge `web-1`: the limit of 5 attempts is dropped",
+		"challenge `misc`: the required challenge 9 is not found",
+		"challenge `web-2`: only the first flag is imported, flag `flag{another}` is dropped",
+		"file `def/logo.png`: only the files of the challenges are imported",
+		"user `carol`: no user with the email or name is found",
+		"1 admin users and their solves are skipped",
+		"team `team-2`: hidden from the scoreboard in CTFd, imported as a ranked team",
+		"1 incorrect submissions are not imported",
+		"1 awards are not imported",
+	}, result.Unmapped)
+
+	game, err := s.GetGameByUUID(result.UUID)
+	assert.NoError(t, err)
+	assert.False(t, game.Visibility)
+	assert.Equal(t, int64(1700000000000), game.StartTime)
+	assert.Equal(t, 4, game.MaxTeamSize)
+	assert.True(t, game.IsManager(admin))
+
+	web1, web2, misc := game.Challenges[0], game.Challenges[1], game.Challenges[2]
+	assert.Equal(t, "web-1", web1.Name)
+	assert.Equal(t, "flag{web-1}", web1.FlagFormat)
+	assert.Equal(t, []string{"easy"}, []string(web1.Tags))
+	assert.Equal(t, "web-2", web2.Name)
+	assert.Equal(t, []string{web1.UUID}, []string(web2.Requires))
+	assert.Equal(t, "second\n\nConnection: `nc example.com 1337`", web2.Description)
+	assert.NoError(t, scoreboard.ValidateFormula(web2.ScoreFormula))
+	assert.Equal(t, store.FlagMatchCaseInsensitive, web2.FlagMatch)
+	assert.Equal(t, store.ChallengeStateHidden, misc.State)
+	assert.Equal(t, "flag{.*}", misc.FlagFormat)
+	assert.Equal(t, store.FlagMatchRegex, misc.FlagMatch)
+
+	hints, _ := s.GetHintsByChallenge(web2)
+	assert.Len(t, hints, 1)
+	attachments, _ := s.GetAttachmentsByChallenge(web1)
+	assert.Len(t, attachments, 1)
+	content, _ := os.ReadFile(attachments[0].SavePath)
+	assert.Equal(t, "source", string(content))
+
+	team1 := game.GetTeamByUser(s, alice)
+	assert.NotNil(t, team1)
+	assert.Equal(t, "team-1", team1.Name)
+	assert.True(t, team1.HasMember(alice))
+	assert.True(t, team1.HasMember(bob))
+	assert.Equal(t, alice.ID, team1.CreatorID)
+
+	// the solves are scored by the scoreboard
+	engine := scoreboard.NewEngine(s)
+	for _, challenge := range game.Challenges {
+		engine.Rescore(game, challenge)
+	}
+
+	sb := scoreboard.NewEngine(s).Scoreboard(game, false)
+	assert.Len(t, sb.Standings, 2)
+	assert.Equal(t, "team-1", sb.Standings[0].Name)
+	assert.Equal(t, 100+496, sb.Standings[0].Score)
+	assert.Equal(t, int64(1700000400000), sb.Standings[0].Solves[web1.UUID].SolvedAt)
+	assert.Equal(t, "team-2", sb.Standings[1].Name)
+	assert.Equal(t, 100+496, sb.Standings[1].Score)
+}
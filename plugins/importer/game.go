@@ -0,0 +1,436 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/server/version"
+	"rina.icu/hoshino/store"
+	"rina.icu/hoshino/store/types"
+)
+
+const (
+	// ManifestVersion is the version of the game archive format,
+	// archives of newer versions are rejected
+	ManifestVersion = 1
+
+	// ManifestFile is the name of the manifest in a game archive
+	ManifestFile = "manifest.json"
+)
+
+// Manifest describes the game in a game archive, the attachment files are stored next to it
+type Manifest struct {
+	Version int `json:"version"`
+
+	// version of the Hoshino which exported the game
+	Hoshino    string `json:"hoshino"`
+	ExportedAt int64  `json:"exported_at"`
+
+	// settings of the game, the creator, managers and challenges are stored separately
+	Game     *store.Game `json:"game"`
+	Creator  string      `json:"creator"`
+	Managers []string    `json:"managers"`
+
+	Challenges []*ChallengeEntry `json:"challenges"`
+
+	// users referred to by the UUIDs in the manifest
+	Users []*UserEntry `json:"users"`
+}
+
+// ChallengeEntry is a challenge with its image, hints and sub flags in a game archive
+type ChallengeEntry struct {
+	Challenge   *store.Challenge   `json:"challenge"`
+	Creator     string             `json:"creator"`
+	Attachments []*AttachmentEntry `json:"attachments"`
+}
+
+// AttachmentEntry is an attachment in a game archive
+type AttachmentEntry struct {
+	Name         string `json:"name"`
+	DownloadName string `json:"download_name"`
+	Multiple     bool   `json:"multiple"`
+	Flag         string `json:"flag"`
+
+	// path of the file in the archive
+	File string `json:"file"`
+}
+
+// UserEntry identifies a user in a game archive, the users are matched by UUID, username or email on import
+type UserEntry struct {
+	UUID     string `json:"uuid"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// ExportGame writes the game with its challenges, images, hints and attachment files
+// into a zip or tar.gz archive, the teams and solves are not exported
+func ExportGame(s *store.Store, game *store.Game, w io.Writer, format string) error {
+	aw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	manifest, files, err := buildManifest(s, game)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := aw.add(ManifestFile, bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := addFile(aw, file[0], file[1]); err != nil {
+			return fmt.Errorf("unable to export attachment %s: %w", file[0], err)
+		}
+	}
+
+	return aw.Close()
+}
+
+// buildManifest returns the manifest of the game and the files to be archived, as pairs of the
+// names in the archive and the paths on the disk
+func buildManifest(s *store.Store, game *store.Game) (*Manifest, [][2]string, error) {
+	users := map[string]*UserEntry{}
+	ref := func(user *store.User) string {
+		if user == nil || user.UUID == "" {
+			return ""
+		}
+		users[user.UUID] = &UserEntry{UUID: user.UUID, Username: user.Username, Email: user.Email}
+		return user.UUID
+	}
+
+	g := *game
+	g.Creator = nil
+	g.Managers = nil
+	g.Challenges = nil
+
+	manifest := &Manifest{
+		Version:    ManifestVersion,
+		Hoshino:    version.Version,
+		ExportedAt: time.Now().UnixMilli(),
+		Game:       &g,
+		Creator:    ref(game.Creator),
+		Managers:   []string{},
+		Challenges: []*ChallengeEntry{},
+	}
+	for _, manager := range game.Managers {
+		manifest.Managers = append(manifest.Managers, ref(manager))
+	}
+
+	challenges, err := s.GetChallengesByGame(game)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := [][2]string{}
+	for _, challenge := range challenges {
+		attachments, err := s.GetAttachmentsByChallenge(challenge)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the key of the dynamic flags is not exported, a new one is generated on import
+		c := *challenge
+		c.Game = nil
+		c.Creator = nil
+		c.FlagSecret = ""
+
+		entry := &ChallengeEntry{
+			Challenge:   &c,
+			Creator:     ref(challenge.Creator),
+			Attachments: []*AttachmentEntry{},
+		}
+		for _, attachment := range attachments {
+			file := path.Join("attachments", attachment.UUID)
+			entry.Attachments = append(entry.Attachments, &AttachmentEntry{
+				Name:         attachment.Name,
+				DownloadName: attachment.DownloadName,
+				Multiple:     attachment.Multiple,
+				Flag:         attachment.Flag,
+				File:         file,
+			})
+			files = append(files, [2]string{file, attachment.SavePath})
+		}
+		manifest.Challenges = append(manifest.Challenges, entry)
+	}
+
+	manifest.Users = make([]*UserEntry, 0, len(users))
+	for _, user := range users {
+		manifest.Users = append(manifest.Users, user)
+	}
+	slices.SortFunc(manifest.Users, func(a, b *UserEntry) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+
+	return manifest, files, nil
+}
+
+func addFile(aw archiveWriter, name string, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return aw.add(name, f, info.Size())
+}
+
+// GameResult is the result of importing a game archive
+type GameResult struct {
+	UUID       string `json:"uuid"`
+	Name       string `json:"name"`
+	Challenges int    `json:"challenges"`
+
+	// usernames of the users not found in this instance, replaced by the importing user
+	Unmapped []string `json:"unmapped"`
+	Warnings []string `json:"warnings"`
+}
+
+// LoadManifest reads the manifest of an extracted game archive
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("not a game archive: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestFile, err)
+	}
+	if manifest.Version < 1 || manifest.Version > ManifestVersion {
+		return nil, fmt.Errorf("unsupported archive version %d, the latest supported is %d", manifest.Version, ManifestVersion)
+	}
+	if manifest.Game == nil {
+		return nil, fmt.Errorf("invalid %s: game is missing", ManifestFile)
+	}
+	return &manifest, nil
+}
+
+// ImportGame creates a new game from an extracted game archive. All the UUIDs are regenerated and
+// the users are matched by UUID, username or email, the unmatched ones are replaced by the user.
+// The game is created as a hidden draft, named after the archive unless the name is given
+func ImportGame(s *store.Store, dir string, dataDir string, user *store.User, name string) (*GameResult, error) {
+	manifest, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GameResult{Unmapped: []string{}, Warnings: []string{}}
+
+	mapped := map[string]*store.User{}
+	for _, entry := range manifest.Users {
+		if u := findUser(s, entry); u != nil {
+			mapped[entry.UUID] = u
+		} else {
+			result.Unmapped = append(result.Unmapped, entry.Username)
+		}
+	}
+	resolve := func(uuid string) *store.User {
+		if u, ok := mapped[uuid]; ok {
+			return u
+		}
+		return user
+	}
+
+	game := *manifest.Game
+	game.Model = gorm.Model{}
+	game.UUID = util.UUID()
+	game.Status = store.GameStatusInactive
+	game.Visibility = false
+	if name != "" {
+		game.Name = name
+	}
+
+	game.Creator = nil
+	game.CreatorID = 0
+	if creator := resolve(manifest.Creator); creator != nil {
+		game.CreatorID = creator.ID
+	}
+
+	game.Managers = []*store.User{}
+	for _, uuid := range append(manifest.Managers, manifest.Creator) {
+		if u := resolve(uuid); u != nil && !game.IsManager(u) {
+			game.Managers = append(game.Managers, u)
+		}
+	}
+
+	uuids := make(map[string]string, len(manifest.Challenges))
+	for _, entry := range manifest.Challenges {
+		if entry.Challenge == nil {
+			return nil, fmt.Errorf("invalid %s: challenge is missing", ManifestFile)
+		}
+		uuids[entry.Challenge.UUID] = util.UUID()
+	}
+
+	attachmentDir := filepath.Join(dataDir, "attachments")
+	if err := os.MkdirAll(attachmentDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	copied := []string{}
+	cleanup := func() {
+		for _, path := range copied {
+			os.Remove(path)
+		}
+	}
+
+	challenges := make([]*store.Challenge, 0, len(manifest.Challenges))
+	attachments := map[string][]*store.Attachment{}
+	for _, entry := range manifest.Challenges {
+		c := *entry.Challenge
+		c.Model = gorm.Model{}
+		c.UUID = uuids[entry.Challenge.UUID]
+		c.Game = nil
+		c.Creator = nil
+		c.CreatorID = 0
+		// a new secret is generated for the draft
+		c.FlagSecret = ""
+		if creator := resolve(entry.Creator); creator != nil {
+			c.CreatorID = creator.ID
+		}
+
+		c.Requires = make(types.StringArray, 0, len(entry.Challenge.Requires))
+		for _, required := range entry.Challenge.Requires {
+			if u, ok := uuids[required]; ok {
+				c.Requires = append(c.Requires, u)
+			} else {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("challenge `%s` requires an unknown challenge %s, ignored", c.Name, required))
+			}
+		}
+
+		c.Hints = make([]*store.Hint, 0, len(entry.Challenge.Hints))
+		for _, hint := range entry.Challenge.Hints {
+			h := *hint
+			h.Model = gorm.Model{}
+			h.UUID = util.UUID()
+			h.ChallengeID = 0
+			c.Hints = append(c.Hints, &h)
+		}
+
+		c.SubFlags = make([]*store.SubFlag, 0, len(entry.Challenge.SubFlags))
+		for _, flag := range entry.Challenge.SubFlags {
+			f := *flag
+			f.Model = gorm.Model{}
+			f.UUID = util.UUID()
+			f.ChallengeID = 0
+			c.SubFlags = append(c.SubFlags, &f)
+		}
+
+		c.ImageID = 0
+		if entry.Challenge.Image != nil {
+			image := *entry.Challenge.Image
+			image.Model = gorm.Model{}
+			c.Image = &image
+
+			if image.RegistryAccessTokenUUID != "" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("image of challenge `%s` uses a registry access token, check it exists in this instance", c.Name))
+			}
+		}
+
+		for _, a := range entry.Attachments {
+			src, err := target(dir, a.File)
+			if err == nil {
+				err = regularFile(src)
+			}
+			if err != nil {
+				cleanup()
+				return nil, fmt.Errorf("attachment %s of challenge %s: %w", a.Name, c.Name, err)
+			}
+
+			attachment := &store.Attachment{
+				UUID:         util.UUID(),
+				Name:         a.Name,
+				DownloadName: a.DownloadName,
+				Multiple:     a.Multiple,
+				Flag:         a.Flag,
+			}
+			if user != nil {
+				attachment.UploaderID = user.ID
+			}
+			attachment.SavePath = filepath.Join(attachmentDir, attachment.UUID)
+
+			if err := copyFile(src, attachment.SavePath); err != nil {
+				cleanup()
+				return nil, err
+			}
+			copied = append(copied, attachment.SavePath)
+			attachments[c.UUID] = append(attachments[c.UUID], attachment)
+		}
+
+		challenges = append(challenges, &c)
+	}
+
+	if err := s.ImportGame(&game, &store.GameRecords{Challenges: challenges, Attachments: attachments}); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("unable to create game %s: %w", game.Name, err)
+	}
+
+	result.UUID = game.UUID
+	result.Name = game.Name
+	result.Challenges = len(challenges)
+	return result, nil
+}
+
+// findUser returns the user of this instance matching the entry, nil if there's none
+func findUser(s *store.Store, entry *UserEntry) *store.User {
+	if entry.UUID != "" {
+		if u, err := s.GetUserByUUID(entry.UUID); err == nil {
+			return u
+		}
+	}
+	if entry.Username != "" {
+		if u, err := s.GetUserByUsername(entry.Username); err == nil {
+			return u
+		}
+	}
+	if entry.Email != "" {
+		if u, err := s.GetUserByEmail(entry.Email); err == nil {
+			return u
+		}
+	}
+	return nil
+}
+
+func regularFile(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", filepath.Base(path))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/server/config"
+	"rina.icu/hoshino/store"
+)
+
+func TestExportGame(t *testing.T) {
+	for _, format := range []string{"zip", "tar.gz"} {
+		t.Run(format, func(t *testing.T) {
+			testExportGame(t, format)
+		})
+	}
+}
+
+func testExportGame(t *testing.T, format string) {
+	src := t.TempDir()
+	s, err := store.GetStore(&config.Config{Driver: "sqlite", DataDir: src})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.CreateUser(store.User{UUID: "alice", Username: "alice", Email: "alice@example.com"}))
+	assert.NoError(t, s.CreateUser(store.User{UUID: "bob", Username: "bob", Email: "bob@example.com"}))
+	alice, _ := s.GetUserByUsername("alice")
+	bob, _ := s.GetUserByUsername("bob")
+
+	game := &store.Game{UUID: "game", Name: "game", Visibility: true, FlagPrefix: "hoshino", Creator: alice, Managers: []*store.User{alice, bob}}
+	assert.NoError(t, s.CreateGame(game))
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "web-2", SpecFile), web2)
+	writeFile(t, filepath.Join(root, "web-1", SpecFile), web1)
+	writeFile(t, filepath.Join(root, "web-1", "dist", "source.zip"), "source")
+
+	i := NewImporter(s, game, src)
+	i.User = bob
+	_, err = i.Import(root)
+	assert.NoError(t, err)
+
+	game, _ = s.GetGameByUUID("game")
+	archive := filepath.Join(t.TempDir(), "game."+format)
+	f, err := os.Create(archive)
+	assert.NoError(t, err)
+	assert.NoError(t, ExportGame(s, game, f, format))
+	f.Close()
+
+	// import into another instance which only knows bob by the email
+	dst := t.TempDir()
+	d, err := store.GetStore(&config.Config{Driver: "sqlite", DataDir: dst})
+	assert.NoError(t, err)
+	assert.NoError(t, d.CreateUser(store.User{UUID: "carol", Username: "carol", Email: "bob@example.com"}))
+	assert.NoError(t, d.CreateUser(store.User{UUID: "admin", Username: "admin", Email: "admin@example.com"}))
+	carol, _ := d.GetUserByUsername("carol")
+	admin, _ := d.GetUserByUsername("admin")
+
+	dir := filepath.Join(t.TempDir(), "content")
+	assert.NoError(t, Extract(archive, dir))
+
+	// the keys of the dynamic flags are not exported
+	manifest, err := LoadManifest(dir)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, game.Challenges[0].FlagSecret)
+	for _, entry := range manifest.Challenges {
+		assert.Empty(t, entry.Challenge.FlagSecret)
+	}
+
+	result, err := ImportGame(d, dir, dst, admin, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "game", result.Name)
+	assert.Equal(t, 2, result.Challenges)
+	assert.Equal(t, []string{"alice"}, result.Unmapped)
+
+	imported, err := d.GetGameByUUID(result.UUID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, game.UUID, imported.UUID)
+	assert.False(t, imported.Visibility)
+	assert.Equal(t, "hoshino", imported.FlagPrefix)
+	assert.Equal(t, admin.ID, imported.CreatorID)
+	assert.True(t, imported.IsManager(admin))
+	assert.True(t, imported.IsManager(carol))
+	assert.Len(t, imported.Challenges, 2)
+
+	w1, w2 := imported.Challenges[0], imported.Challenges[1]
+	assert.Equal(t, "web-1", w1.Name)
+	assert.NotEqual(t, game.Challenges[0].UUID, w1.UUID)
+	assert.Equal(t, carol.ID, w1.CreatorID)
+	assert.Equal(t, []string{w1.UUID}, []string(w2.Requires))
+
+	hints, _ := d.GetHintsByChallenge(w1)
+	assert.Len(t, hints, 2)
+
+	w2, _ = d.GetChallengeByUUID(w2.UUID)
+	assert.Equal(t, "registry.example.com/web-2:latest", w2.Image.Name)
+
+	attachments, _ := d.GetAttachmentsByChallenge(w1)
+	assert.Len(t, attachments, 1)
+	assert.Equal(t, admin.ID, attachments[0].UploaderID)
+	content, _ := os.ReadFile(attachments[0].SavePath)
+	assert.Equal(t, "source", string(content))
+	assert.Equal(t, filepath.Join(dst, "attachments"), filepath.Dir(attachments[0].SavePath))
+
+	// the names of the games are unique
+	_, err = ImportGame(d, dir, dst, admin, "")
+	assert.Error(t, err)
+	result, err = ImportGame(d, dir, dst, admin, "game 2")
+	assert.NoError(t, err)
+	assert.Equal(t, "game 2", result.Name)
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadManifest(dir)
+	assert.Error(t, err)
+
+	writeFile(t, filepath.Join(dir, ManifestFile), `{"version": 2, "game": {}}`)
+	_, err = LoadManifest(dir)
+	assert.ErrorContains(t, err, "unsupported archive version 2")
+
+	writeFile(t, filepath.Join(dir, ManifestFile), `{"version": 1, "game": {"name": "game"}}`)
+	manifest, err := LoadManifest(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "game", manifest.Game.Name)
+}
@@ -0,0 +1,539 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"rina.icu/hoshino/internal/flagcheck"
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/internal/unlock"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/store"
+)
+
+// Change is a difference between the challenge.yml and the stored challenge
+type Change struct {
+	// + for created, - for deleted, ~ for updated
+	Op     string `json:"op"`
+	Target string `json:"target"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	if c.Op == "~" {
+		return fmt.Sprintf("~ %s: %q -> %q", c.Target, c.Old, c.New)
+	}
+	return fmt.Sprintf("%s %s", c.Op, c.Target)
+}
+
+// Result of importing a challenge
+type Result struct {
+	Name     string    `json:"name"`
+	UUID     string    `json:"uuid"`
+	Created  bool      `json:"created"`
+	Changes  []*Change `json:"changes"`
+	Warnings []string  `json:"warnings"`
+
+	// the score of the challenge has changed, the scoreboard should rescore it
+	Rescore bool `json:"-"`
+
+	// unlocked hints were deleted, the scoreboard should be reloaded to refund the teams
+	Refund bool `json:"-"`
+}
+
+func (r *Result) add(op string, target string) {
+	r.Changes = append(r.Changes, &Change{Op: op, Target: target})
+}
+
+// Importer creates or updates the challenges of a game from challenge.yml files,
+// importing the same files again changes nothing
+type Importer struct {
+	store *store.Store
+	game  *store.Game
+
+	// directory of the attachment files
+	attachmentDir string
+
+	// report the changes without applying them
+	DryRun bool
+
+	// uploader of the attachments and creator of the new challenges, optional
+	User *store.User
+}
+
+func NewImporter(s *store.Store, game *store.Game, dataDir string) *Importer {
+	return &Importer{
+		store:         s,
+		game:          game,
+		attachmentDir: filepath.Join(dataDir, "attachments"),
+	}
+}
+
+// Import imports all the challenges found under the root directory
+func (i *Importer) Import(root string) ([]*Result, error) {
+	dirs, err := FindChallengeSpecs(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no %s found in %s", SpecFile, root)
+	}
+
+	specs := make([]*ChallengeSpec, 0, len(dirs))
+	for _, dir := range dirs {
+		spec, err := LoadChallengeSpec(dir)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+
+	specs, err = sortSpecs(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Result, 0, len(specs))
+	for _, spec := range specs {
+		result, err := i.ImportChallenge(spec)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", spec.Name, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// find returns the challenge of the game by the name
+func (i *Importer) find(name string) *store.Challenge {
+	for _, challenge := range i.game.Challenges {
+		if challenge.Name == name {
+			return challenge
+		}
+	}
+	return nil
+}
+
+// ImportChallenge creates or updates the challenge, the challenges in its requirements should exist
+func (i *Importer) ImportChallenge(spec *ChallengeSpec) (*Result, error) {
+	result := &Result{Name: spec.Name, Changes: []*Change{}, Warnings: slices.Clone(spec.Warnings)}
+
+	var challenge *store.Challenge
+	if existing := i.find(spec.Name); existing != nil {
+		var err error
+		if challenge, err = i.store.GetChallengeByUUID(existing.UUID); err != nil {
+			return nil, err
+		}
+	} else {
+		challenge = &store.Challenge{
+			UUID:     util.UUID(),
+			Name:     spec.Name,
+			GameID:   i.game.ID,
+			Creator:  i.User,
+			State:    store.ChallengeStateVisible,
+			Position: len(i.game.Challenges),
+		}
+		result.Created = true
+		result.add("+", fmt.Sprintf("challenge `%s`", spec.Name))
+	}
+	if challenge.Image == nil {
+		challenge.Image = &store.Image{}
+	}
+	result.UUID = challenge.UUID
+
+	rescore := i.apply(spec, challenge, result)
+	if err := i.resolveRequirements(spec, challenge, result); err != nil {
+		return nil, err
+	}
+
+	if err := scoreboard.ValidateFormula(challenge.ScoreFormula); err != nil {
+		return nil, fmt.Errorf("invalid score formula: %w", err)
+	}
+	if err := flagcheck.Validate(challenge); err != nil {
+		return nil, fmt.Errorf("invalid flag: %w", err)
+	}
+
+	challenges := slices.Clone(i.game.Challenges)
+	if idx := slices.IndexFunc(challenges, func(c *store.Challenge) bool { return c.UUID == challenge.UUID }); idx >= 0 {
+		challenges[idx] = challenge
+	} else {
+		challenges = append(challenges, challenge)
+	}
+	if err := unlock.Validate(challenges); err != nil {
+		return nil, err
+	}
+
+	if !i.DryRun {
+		var err error
+		if result.Created {
+			err = i.store.CreateGameChallenge(i.game, challenge)
+		} else {
+			err = i.store.UpdateChallenge(challenge)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if result.Created {
+		// later challenges of the same import may require it
+		i.game.Challenges = append(i.game.Challenges, challenge)
+	} else {
+		result.Rescore = rescore
+	}
+
+	if err := i.importHints(spec, challenge, result); err != nil {
+		return nil, err
+	}
+	if err := i.importFiles(spec, challenge, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// set updates the field and records the change
+func set[T comparable](r *Result, field string, dst *T, value T) bool {
+	if *dst == value {
+		return false
+	}
+	if !r.Created {
+		r.Changes = append(r.Changes, &Change{Op: "~", Target: field, Old: brief(fmt.Sprint(*dst)), New: brief(fmt.Sprint(value))})
+	}
+	*dst = value
+	return true
+}
+
+func setStrings(r *Result, field string, dst *[]string, value []string) {
+	old, joined := strings.Join(*dst, ", "), strings.Join(value, ", ")
+	if old == joined {
+		return
+	}
+	if !r.Created {
+		r.Changes = append(r.Changes, &Change{Op: "~", Target: field, Old: brief(old), New: brief(joined)})
+	}
+	*dst = value
+}
+
+// apply copies the fields of the spec into the challenge, returns whether the score has changed
+func (i *Importer) apply(spec *ChallengeSpec, challenge *store.Challenge, r *Result) bool {
+	set(r, "category", &challenge.Category, spec.Category)
+	set(r, "description", &challenge.Description, spec.Description)
+
+	tags := spec.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	setStrings(r, "tags", (*[]string)(&challenge.Tags), tags)
+
+	score, formula := spec.Value, ""
+	if spec.Type == "dynamic" {
+		if spec.Extra.Decay > 0 {
+			score = spec.Extra.Initial
+			formula = dynamicFormula(spec.Extra.Initial, spec.Extra.Decay, spec.Extra.Minimum)
+		} else {
+			r.Warnings = append(r.Warnings, "dynamic score without a positive decay is imported as a static score")
+		}
+	}
+	rescore := set(r, "score", &challenge.Score, score)
+	rescore = set(r, "score_formula", &challenge.ScoreFormula, formula) || rescore
+
+	switch spec.State {
+	case "hidden":
+		set(r, "state", &challenge.State, store.ChallengeStateHidden)
+	case "visible":
+		set(r, "state", &challenge.State, store.ChallengeStateVisible)
+	case "":
+	default:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("unknown state `%s`", spec.State))
+	}
+
+	set(r, "no_container", &challenge.NoContainer, spec.Image == "")
+	if spec.Image != "" {
+		if strings.HasPrefix(spec.Image, ".") || strings.HasPrefix(spec.Image, "/") {
+			r.Warnings = append(r.Warnings, "building images from a directory is not supported, push the image and use its name")
+		}
+		set(r, "image", &challenge.Image.Name, spec.Image)
+	}
+	if spec.Limits.Memory != "" {
+		set(r, "memory_limit", &challenge.Image.MemoryLimit, spec.Limits.Memory)
+	}
+	if spec.Limits.CPU != "" {
+		set(r, "cpu_limit", &challenge.Image.CPULimit, spec.Limits.CPU)
+	}
+	if spec.Limits.Storage != "" {
+		set(r, "storage_limit", &challenge.Image.StorageLimit, spec.Limits.Storage)
+	}
+	if spec.Port != 0 {
+		set(r, "exposed_port", &challenge.Image.ExposedPort, spec.Port)
+	}
+
+	flag, match := "", store.FlagMatchExact
+	for n, f := range spec.Flags {
+		if n == 0 {
+			m, content, err := f.Match()
+			if err == nil && spec.DynamicFlag && m == store.FlagMatchRegex {
+				err = errors.New("dynamic flags can't be matched by regex")
+			}
+			if err != nil {
+				flag = f.Content
+				r.Warnings = append(r.Warnings, fmt.Sprintf("flag `%s` is imported as an exact flag: %s", f.Content, err.Error()))
+			} else {
+				flag, match = content, m
+			}
+			continue
+		}
+		r.Warnings = append(r.Warnings, fmt.Sprintf("only the first flag is imported, flag `%s` is ignored", f.Content))
+	}
+	set(r, "flag", &challenge.FlagFormat, flag)
+	set(r, "flag_match", &challenge.FlagMatch, match)
+	set(r, "dynamic_flag", &challenge.DynamicFlag, spec.DynamicFlag)
+
+	return rescore
+}
+
+func (i *Importer) resolveRequirements(spec *ChallengeSpec, challenge *store.Challenge, r *Result) error {
+	requires := make([]string, 0, len(spec.Requirements))
+	for _, name := range spec.Requirements {
+		required := i.find(name)
+		if required == nil {
+			return fmt.Errorf("required challenge %s does not exist", name)
+		}
+		requires = append(requires, required.UUID)
+	}
+
+	names := func(uuids []string) string {
+		result := make([]string, 0, len(uuids))
+		for _, uuid := range uuids {
+			name := uuid
+			for _, c := range i.game.Challenges {
+				if c.UUID == uuid {
+					name = c.Name
+				}
+			}
+			result = append(result, name)
+		}
+		return strings.Join(result, ", ")
+	}
+
+	if old, value := names(challenge.Requires), names(requires); old != value && !r.Created {
+		r.Changes = append(r.Changes, &Change{Op: "~", Target: "requirements", Old: old, New: value})
+	}
+	challenge.Requires = requires
+	return nil
+}
+
+// importHints matches the hints by the content, the hints not in the spec are deleted
+func (i *Importer) importHints(spec *ChallengeSpec, challenge *store.Challenge, r *Result) error {
+	existing := []*store.Hint{}
+	if !r.Created {
+		var err error
+		if existing, err = i.store.GetHintsByChallenge(challenge); err != nil {
+			return err
+		}
+	}
+
+	kept := make(map[uint]bool)
+	for _, h := range spec.Hints {
+		idx := slices.IndexFunc(existing, func(hint *store.Hint) bool { return hint.Content == h.Content && !kept[hint.ID] })
+		if idx < 0 {
+			r.add("+", fmt.Sprintf("hint `%s`", brief(h.Content)))
+			if !i.DryRun {
+				hint := &store.Hint{UUID: util.UUID(), ChallengeID: challenge.ID, Content: h.Content, Cost: h.Cost}
+				if err := i.store.CreateHint(hint); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		hint := existing[idx]
+		kept[hint.ID] = true
+		if set(r, fmt.Sprintf("hint `%s` cost", brief(h.Content)), &hint.Cost, h.Cost) && !i.DryRun {
+			if err := i.store.UpdateHint(hint); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, hint := range existing {
+		if kept[hint.ID] {
+			continue
+		}
+		r.add("-", fmt.Sprintf("hint `%s`", brief(hint.Content)))
+		r.Refund = r.Refund || hint.Cost > 0
+		if !i.DryRun {
+			if err := i.store.DeleteHint(hint); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// importFiles matches the attachments by the file name and the content,
+// the attachments not in the spec are deleted except the multiple ones
+func (i *Importer) importFiles(spec *ChallengeSpec, challenge *store.Challenge, r *Result) error {
+	existing := []store.Attachment{}
+	if !r.Created {
+		var err error
+		if existing, err = i.store.GetAttachmentsByChallenge(challenge); err != nil {
+			return err
+		}
+	}
+
+	kept := make(map[uint]bool)
+	for _, file := range spec.Files {
+		path, err := spec.localFile(file)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Base(file)
+		idx := slices.IndexFunc(existing, func(a store.Attachment) bool { return a.Name == name && !a.Multiple })
+		if idx >= 0 {
+			kept[existing[idx].ID] = true
+			if same, err := sameContent(existing[idx].SavePath, path); err == nil && same {
+				continue
+			}
+			r.add("~", fmt.Sprintf("attachment `%s`", name))
+			if !i.DryRun {
+				if err := i.deleteAttachment(&existing[idx]); err != nil {
+					return err
+				}
+			}
+		} else {
+			r.add("+", fmt.Sprintf("attachment `%s`", name))
+		}
+
+		if !i.DryRun {
+			if err := i.createAttachment(challenge, name, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, attachment := range existing {
+		if kept[attachment.ID] || attachment.Multiple {
+			continue
+		}
+		r.add("-", fmt.Sprintf("attachment `%s`", attachment.Name))
+		if !i.DryRun {
+			if err := i.deleteAttachment(&attachment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (i *Importer) createAttachment(challenge *store.Challenge, name string, path string) error {
+	uuid := util.UUID()
+	dst := filepath.Join(i.attachmentDir, uuid)
+
+	if err := os.MkdirAll(i.attachmentDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := copyFile(path, dst); err != nil {
+		return err
+	}
+
+	attachment := &store.Attachment{
+		UUID:         uuid,
+		Name:         name,
+		SavePath:     dst,
+		DownloadName: name,
+		ChallengeID:  challenge.ID,
+		Uploader:     i.User,
+	}
+	if err := i.store.CreateAttachment(attachment); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+func (i *Importer) deleteAttachment(attachment *store.Attachment) error {
+	if err := i.store.DeleteAttachment(attachment); err != nil {
+		return err
+	}
+	os.Remove(attachment.SavePath)
+	return nil
+}
+
+func sameContent(a string, b string) (bool, error) {
+	hash := func(path string) ([]byte, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return nil, err
+		}
+		return h.Sum(nil), nil
+	}
+
+	ha, err := hash(a)
+	if err != nil {
+		return false, err
+	}
+	hb, err := hash(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ha, hb), nil
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// brief shortens the content for the changes
+func brief(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if r := []rune(content); len(r) > 40 {
+		return string(r[:40]) + "..."
+	}
+	return content
+}
@@ -0,0 +1,272 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package importer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/server/config"
+	"rina.icu/hoshino/store"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+const web1 = `
+name: web-1
+category: web
+description: warm up
+value: 100
+flags:
+  - flag{web-1}
+hints:
+  - free hint
+  - content: paid hint
+    cost: 10
+files:
+  - dist/source.zip
+`
+
+const web2 = `
+name: web-2
+category: web
+description: second step
+type: dynamic
+extra:
+  initial: 500
+  decay: 10
+  minimum: 100
+image: registry.example.com/web-2:latest
+limits:
+  memory: 256Mi
+port: 8080
+flags:
+  - {type: static, content: "flag{web-2}", data: case_insensitive}
+  - flag{another}
+requirements:
+  - web-1
+state: hidden
+connection_info: http://example.com
+`
+
+func TestImport(t *testing.T) {
+	data := t.TempDir()
+	s, err := store.GetStore(&config.Config{Driver: "sqlite", DataDir: data})
+	assert.NoError(t, err)
+
+	game := &store.Game{UUID: "game", Name: "game"}
+	assert.NoError(t, s.CreateGame(game))
+
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "web-2", SpecFile), web2)
+	writeFile(t, filepath.Join(root, "web-1", SpecFile), web1)
+	writeFile(t, filepath.Join(root, "web-1", "dist", "source.zip"), "source")
+
+	// the required challenge is imported first
+	results, err := NewImporter(s, game, data).Import(root)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "web-1", results[0].Name)
+	assert.True(t, results[1].Created)
+	assert.Contains(t, results[1].Warnings, "field `connection_info` is not supported")
+	assert.Contains(t, results[1].Warnings, "only the first flag is imported, flag `flag{another}` is ignored")
+
+	game, _ = s.GetGameByUUID("game")
+	assert.Len(t, game.Challenges, 2)
+
+	w1, _ := s.GetChallengeByUUID(results[0].UUID)
+	assert.Equal(t, "flag{web-1}", w1.FlagFormat)
+	assert.True(t, w1.NoContainer)
+	hints, _ := s.GetHintsByChallenge(w1)
+	assert.Len(t, hints, 2)
+	attachments, _ := s.GetAttachmentsByChallenge(w1)
+	assert.Len(t, attachments, 1)
+	assert.Equal(t, "source.zip", attachments[0].Name)
+
+	w2, _ := s.GetChallengeByUUID(results[1].UUID)
+	assert.Equal(t, store.ChallengeStateHidden, w2.State)
+	assert.Equal(t, []string{w1.UUID}, []string(w2.Requires))
+	assert.Equal(t, store.FlagMatchCaseInsensitive, w2.FlagMatch)
+	assert.Equal(t, 500, w2.Score)
+	assert.NoError(t, scoreboard.ValidateFormula(w2.ScoreFormula))
+	assert.Equal(t, "registry.example.com/web-2:latest", w2.Image.Name)
+	assert.Equal(t, "256Mi", w2.Image.MemoryLimit)
+	assert.Equal(t, 8080, w2.Image.ExposedPort)
+
+	// importing again changes nothing
+	results, err = NewImporter(s, game, data).Import(root)
+	assert.NoError(t, err)
+	for _, result := range results {
+		assert.False(t, result.Created)
+		assert.Empty(t, result.Changes, result.Name)
+	}
+
+	// dry run reports the changes only
+	writeFile(t, filepath.Join(root, "web-1", SpecFile), `
+name: web-1
+category: web
+description: warm up
+value: 200
+flags:
+  - flag{web-1}
+hints:
+  - free hint
+files:
+  - dist/source.zip
+`)
+	writeFile(t, filepath.Join(root, "web-1", "dist", "source.zip"), "new source")
+
+	i := NewImporter(s, game, data)
+	i.DryRun = true
+	results, err = i.Import(root)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Change{
+		{Op: "~", Target: "score", Old: "100", New: "200"},
+		{Op: "-", Target: "hint `paid hint`"},
+		{Op: "~", Target: "attachment `source.zip`"},
+	}, results[0].Changes)
+
+	w1, _ = s.GetChallengeByUUID(w1.UUID)
+	assert.Equal(t, 100, w1.Score)
+
+	results, err = NewImporter(s, game, data).Import(root)
+	assert.NoError(t, err)
+	assert.True(t, results[0].Rescore)
+	assert.True(t, results[0].Refund)
+
+	w1, _ = s.GetChallengeByUUID(w1.UUID)
+	assert.Equal(t, 200, w1.Score)
+	attachments, _ = s.GetAttachmentsByChallenge(w1)
+	content, _ := os.ReadFile(attachments[0].SavePath)
+	assert.Equal(t, "new source", string(content))
+}
+
+func TestImportInvalid(t *testing.T) {
+	data := t.TempDir()
+	s, err := store.GetStore(&config.Config{Driver: "sqlite", DataDir: data})
+	assert.NoError(t, err)
+
+	game := &store.Game{UUID: "game", Name: "game"}
+	assert.NoError(t, s.CreateGame(game))
+
+	// files outside of the challenge directory
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "secret"), "secret")
+	writeFile(t, filepath.Join(root, "web", SpecFile), "name: web\nfiles:\n  - ../secret\n")
+	_, err = NewImporter(s, game, data).Import(root)
+	assert.Error(t, err)
+
+	// unknown requirements
+	root = t.TempDir()
+	writeFile(t, filepath.Join(root, "web", SpecFile), "name: web\nrequirements: [missing]\n")
+	_, err = NewImporter(s, game, data).Import(root)
+	assert.Error(t, err)
+
+	// cyclic requirements
+	root = t.TempDir()
+	writeFile(t, filepath.Join(root, "a", SpecFile), "name: a\nrequirements: [b]\n")
+	writeFile(t, filepath.Join(root, "b", SpecFile), "name: b\nrequirements: [a]\n")
+	_, err = NewImporter(s, game, data).Import(root)
+	assert.Error(t, err)
+
+	// unknown placeholders of the dynamic flags
+	root = t.TempDir()
+	writeFile(t, filepath.Join(root, "dyn", SpecFile), "name: dyn\ndynamic_flag: true\nflags:\n  - flag{unknown}\n")
+	_, err = NewImporter(s, game, data).Import(root)
+	assert.Error(t, err)
+
+	// the stateless flags of an existing challenge should stay dynamic
+	root = t.TempDir()
+	writeFile(t, filepath.Join(root, "dyn", SpecFile), "name: dyn\ndynamic_flag: true\nflags:\n  - flag{hmac:16}\n")
+	_, err = NewImporter(s, game, data).Import(root)
+	assert.NoError(t, err)
+
+	game, _ = s.GetGameByUUID("game")
+	dyn, _ := s.GetChallengeByUUID(NewImporter(s, game, data).find("dyn").UUID)
+	dyn.StatelessFlag = true
+	assert.NoError(t, s.UpdateChallenge(dyn))
+
+	writeFile(t, filepath.Join(root, "dyn", SpecFile), "name: dyn\nflags:\n  - flag{hmac:16}\n")
+	_, err = NewImporter(s, game, data).Import(root)
+	assert.Error(t, err)
+}
+
+func TestFlagSpecMatch(t *testing.T) {
+	spec := FlagSpec{Type: "static", Content: " flag{web} ", Data: "case_insensitive"}
+	match, flag, err := spec.Match()
+	assert.NoError(t, err)
+	assert.Equal(t, store.FlagMatchCaseInsensitive, match)
+	assert.True(t, match.Match(flag, " FLAG{Web} "))
+	assert.False(t, match.Match(flag, "flag{web}"))
+
+	spec = FlagSpec{Type: "regex", Content: `flag\{[a-z]+\}`, Data: "case_insensitive"}
+	match, flag, err = spec.Match()
+	assert.NoError(t, err)
+	assert.Equal(t, store.FlagMatchRegex, match)
+	assert.True(t, match.Match(flag, "FLAG{abc}"))
+	// the whole flag should match
+	assert.False(t, match.Match(flag, "flag{abc}x"))
+	assert.False(t, match.Match(flag, "flag{abc}\n"))
+
+	_, _, err = (&FlagSpec{Type: "regex", Content: "flag{("}).Match()
+	assert.Error(t, err)
+	_, _, err = (&FlagSpec{Type: "script", Content: "flag"}).Match()
+	assert.Error(t, err)
+
+	assert.True(t, store.FlagMatchTrimmed.Match("flag{web}", " flag{web}\n"))
+
+	// a catastrophic pattern times out as a mismatch
+	start := time.Now()
+	assert.False(t, store.FlagMatchRegex.Match(`(a+)+b`, strings.Repeat("a", 64)))
+	assert.Less(t, time.Since(start), 10*store.FlagRegexTimeout)
+}
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "challenges.zip")
+
+	f, err := os.Create(archive)
+	assert.NoError(t, err)
+	w := zip.NewWriter(f)
+	entry, _ := w.Create("web/challenge.yml")
+	entry.Write([]byte("name: web\n"))
+	assert.NoError(t, w.Close())
+	f.Close()
+
+	assert.NoError(t, Extract(archive, filepath.Join(dir, "out")))
+	dirs, err := FindChallengeSpecs(filepath.Join(dir, "out"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "out", "web")}, dirs)
+
+	// entries escaping the directory are rejected
+	f, _ = os.Create(archive)
+	w = zip.NewWriter(f)
+	entry, _ = w.Create("../evil")
+	entry.Write([]byte("evil"))
+	w.Close()
+	f.Close()
+
+	assert.Error(t, Extract(archive, filepath.Join(dir, "out")))
+	assert.NoFileExists(t, filepath.Join(dir, "evil"))
+}
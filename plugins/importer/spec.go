@@ -0,0 +1,280 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package importer imports the challenges described by ctfcli-style challenge.yml files
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"rina.icu/hoshino/store"
+)
+
+// SpecFile is the name of the challenge description file, challenge.yaml is accepted as well
+const SpecFile = "challenge.yml"
+
+// FlagSpec is a flag of the challenge, written as a string or a mapping
+type FlagSpec struct {
+	Type    string `yaml:"type"`
+	Content string `yaml:"content"`
+	Data    string `yaml:"data"`
+}
+
+func (f *FlagSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.Type = "static"
+		return node.Decode(&f.Content)
+	}
+
+	type plain FlagSpec
+	return node.Decode((*plain)(f))
+}
+
+// Match returns the match mode and the flag of the spec, a case insensitive regex
+// becomes a pattern with the (?i) option
+func (f *FlagSpec) Match() (store.FlagMatch, string, error) {
+	match, flag := store.FlagMatchExact, f.Content
+	switch f.Type {
+	case "", "static":
+		if f.Data == "case_insensitive" {
+			match = store.FlagMatchCaseInsensitive
+		}
+	case "regex":
+		match = store.FlagMatchRegex
+		if f.Data == "case_insensitive" {
+			flag = "(?i)" + flag
+		}
+	default:
+		return match, flag, fmt.Errorf("%s flags are not supported", f.Type)
+	}
+
+	return match, flag, match.Validate(flag)
+}
+
+// HintSpec is a hint of the challenge, written as a string or a mapping
+type HintSpec struct {
+	Content string `yaml:"content"`
+	Cost    int    `yaml:"cost"`
+}
+
+func (h *HintSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&h.Content)
+	}
+
+	type plain HintSpec
+	return node.Decode((*plain)(h))
+}
+
+// Requirements are the names of the challenges to solve first, written as a list
+// or a mapping with the prerequisites
+type Requirements []string
+
+func (r *Requirements) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		var requirements struct {
+			Prerequisites []string `yaml:"prerequisites"`
+		}
+		if err := node.Decode(&requirements); err != nil {
+			return err
+		}
+		*r = requirements.Prerequisites
+		return nil
+	}
+
+	var names []string
+	if err := node.Decode(&names); err != nil {
+		return err
+	}
+	*r = names
+	return nil
+}
+
+// ChallengeSpec is a challenge described by a challenge.yml
+type ChallengeSpec struct {
+	Name        string   `yaml:"name"`
+	Author      string   `yaml:"author"`
+	Category    string   `yaml:"category"`
+	Description string   `yaml:"description"`
+	Tags        []string `yaml:"tags"`
+
+	// standard or dynamic
+	Type  string `yaml:"type"`
+	Value int    `yaml:"value"`
+	Extra struct {
+		Initial int `yaml:"initial"`
+		Decay   int `yaml:"decay"`
+		Minimum int `yaml:"minimum"`
+	} `yaml:"extra"`
+
+	// name of the container image, the challenge needs no container if empty
+	Image string `yaml:"image"`
+
+	// resource limits of the container, an extension of Hoshino
+	Limits struct {
+		Memory  string `yaml:"memory"`
+		CPU     string `yaml:"cpu"`
+		Storage string `yaml:"storage"`
+	} `yaml:"limits"`
+
+	// exposed port of the container, an extension of Hoshino
+	Port int `yaml:"port"`
+
+	// the first flag is used as the template of the container flags, an extension of Hoshino
+	DynamicFlag bool `yaml:"dynamic_flag"`
+
+	Flags        []FlagSpec   `yaml:"flags"`
+	Hints        []HintSpec   `yaml:"hints"`
+	Files        []string     `yaml:"files"`
+	Requirements Requirements `yaml:"requirements"`
+
+	// hidden or visible
+	State string `yaml:"state"`
+
+	// directory of the challenge.yml
+	Dir string `yaml:"-"`
+
+	// fields which are not imported
+	Warnings []string `yaml:"-"`
+}
+
+// keys of challenge.yml known by the importer
+var specKeys = []string{
+	"name", "author", "category", "description", "tags", "type", "value", "extra", "image", "limits", "port",
+	"dynamic_flag", "flags", "hints", "files", "requirements", "state", "version",
+}
+
+// LoadChallengeSpec reads the challenge.yml in the directory
+func LoadChallengeSpec(dir string) (*ChallengeSpec, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SpecFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = os.ReadFile(filepath.Join(dir, "challenge.yaml"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &ChallengeSpec{Dir: dir}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+
+	if spec.Name == "" {
+		return nil, fmt.Errorf("%s: name is required", dir)
+	}
+
+	var keys map[string]any
+	if err := yaml.Unmarshal(data, &keys); err == nil {
+		for key := range keys {
+			if !slices.Contains(specKeys, key) {
+				spec.Warnings = append(spec.Warnings, fmt.Sprintf("field `%s` is not supported", key))
+			}
+		}
+		slices.Sort(spec.Warnings)
+	}
+
+	return spec, nil
+}
+
+// FindChallengeSpecs returns the directories containing a challenge.yml under the root
+func FindChallengeSpecs(root string) ([]string, error) {
+	dirs := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && (d.Name() == SpecFile || d.Name() == "challenge.yaml") {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// sortSpecs orders the challenges so that the required challenges in the same import come first
+func sortSpecs(specs []*ChallengeSpec) ([]*ChallengeSpec, error) {
+	byName := make(map[string]*ChallengeSpec, len(specs))
+	for _, spec := range specs {
+		if _, ok := byName[spec.Name]; ok {
+			return nil, fmt.Errorf("duplicated challenge %s", spec.Name)
+		}
+		byName[spec.Name] = spec
+	}
+
+	sorted := make([]*ChallengeSpec, 0, len(specs))
+	state := make(map[string]int)
+
+	var visit func(spec *ChallengeSpec) error
+	visit = func(spec *ChallengeSpec) error {
+		switch state[spec.Name] {
+		case 1:
+			return fmt.Errorf("requirements of %s contain a cycle", spec.Name)
+		case 2:
+			return nil
+		}
+
+		state[spec.Name] = 1
+		for _, name := range spec.Requirements {
+			if required, ok := byName[name]; ok {
+				if err := visit(required); err != nil {
+					return err
+				}
+			}
+		}
+		state[spec.Name] = 2
+		sorted = append(sorted, spec)
+		return nil
+	}
+
+	for _, spec := range specs {
+		if err := visit(spec); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// dynamicFormula is the score formula of the CTFd dynamic challenges,
+// the score decays quadratically to the minimum
+func dynamicFormula(initial int, decay int, minimum int) string {
+	return fmt.Sprintf("max(%d - %d * ((solved_count - 1) ** 2) / %d, %d)", initial, initial-minimum, decay*decay, minimum)
+}
+
+// localFile resolves the file of the challenge, files outside of the challenge directory are rejected
+func (spec *ChallengeSpec) localFile(file string) (string, error) {
+	path := filepath.Join(spec.Dir, file)
+	rel, err := filepath.Rel(spec.Dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file %s is outside of the challenge directory", file)
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("file %s is not a regular file", file)
+	}
+	return path, nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/store"
+)
+
+// Data of the event available in the message templates
+type Data struct {
+	Game      string
+	Event     string
+	Content   string
+	Challenge string
+	Category  string
+
+	// first related team
+	Team  string
+	Teams []string
+	Time  string
+}
+
+var titles = map[string]string{
+	"normal":              "Event",
+	"challenge_solved":    "Challenge solved",
+	"cheat_detected":      "Cheat detected",
+	"scoreboard_revealed": "Scoreboard revealed",
+	"announcement":        "Announcement",
+	"first_blood":         "First blood",
+	"second_blood":        "Second blood",
+	"third_blood":         "Third blood",
+}
+
+// DefaultTemplates are used for the events without a custom template
+var DefaultTemplates = map[string]string{
+	"normal":              "{{.Content}}",
+	"challenge_solved":    "Team {{.Team}} solved {{.Challenge}}",
+	"cheat_detected":      "{{.Content}}",
+	"scoreboard_revealed": "{{.Content}}",
+	"announcement":        "{{.Content}}",
+	"first_blood":         "Team {{.Team}} got the first blood of {{.Challenge}}",
+	"second_blood":        "Team {{.Team}} got the second blood of {{.Challenge}}",
+	"third_blood":         "Team {{.Team}} got the third blood of {{.Challenge}}",
+}
+
+// Events returns the names of the events the notifiers can post
+func Events() []string {
+	return store.EventNames()
+}
+
+// ValidateTemplates checks that the templates are of known events and can be parsed
+func ValidateTemplates(templates map[string]string) error {
+	events := Events()
+	for event, text := range templates {
+		if !slices.Contains(events, event) {
+			return fmt.Errorf("unknown event %s", event)
+		}
+		if _, err := template.New(event).Parse(text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render renders the message of the event with the template of the notifier,
+// the custom template of a blood falls back to the one of the solved events
+func Render(n *store.Notifier, event *store.GameEvent, data *Data) (string, error) {
+	name := event.Name()
+
+	text, ok := n.Templates[name]
+	if !ok {
+		text, ok = n.Templates[event.Type.Name()]
+	}
+	if !ok {
+		text = DefaultTemplates[name]
+	}
+
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func eventData(game *store.Game, event *store.GameEvent) *Data {
+	data := &Data{
+		Game:    game.Name,
+		Event:   event.Name(),
+		Content: event.Content,
+		Teams:   []string{},
+		Time:    event.CreatedAt.Format(time.DateTime),
+	}
+
+	if event.Challenge != nil {
+		data.Challenge = event.Challenge.Name
+		data.Category = event.Challenge.Category
+	}
+
+	for _, team := range event.RelatedTeams {
+		data.Teams = append(data.Teams, team.Name)
+	}
+	if len(data.Teams) > 0 {
+		data.Team = data.Teams[0]
+	}
+
+	return data
+}
+
+// Dispatcher posts the visible game events to the notifiers of the games
+type Dispatcher struct {
+	store  *store.Store
+	client *http.Client
+}
+
+func NewDispatcher(s *store.Store) *Dispatcher {
+	return &Dispatcher{
+		store:  s,
+		client: util.PublicClient(10 * time.Second),
+	}
+}
+
+// Send posts the message to the chat of the notifier
+func (d *Dispatcher) Send(n *store.Notifier, title string, text string) error {
+	req, err := buildRequest(n, title, text)
+	if err != nil {
+		return err
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(n, res)
+}
+
+// SendTest posts a test message to the chat of the notifier
+func (d *Dispatcher) SendTest(n *store.Notifier, game *store.Game) error {
+	return d.Send(n, fmt.Sprintf("[%s] Test", game.Name), "This is a test message from Hoshino")
+}
+
+// HandleGameEvent posts the event to the enabled notifiers of its game, hidden events are not posted
+func (d *Dispatcher) HandleGameEvent(event *store.GameEvent) {
+	if !event.Visibility {
+		return
+	}
+
+	go func() {
+		notifiers, err := d.store.GetEnabledNotifiersByGameID(event.GameID)
+		if err != nil {
+			slog.Error("Failed to get notifiers: " + err.Error())
+			return
+		}
+
+		name := event.Name()
+		for _, n := range notifiers {
+			if n.Game == nil || !n.Accepts(name, event.Type.Name()) {
+				continue
+			}
+
+			text, err := Render(n, event, eventData(n.Game, event))
+			if err == nil {
+				err = d.Send(n, fmt.Sprintf("[%s] %s", n.Game.Name, titles[name]), text)
+			}
+			if err != nil {
+				slog.Error(fmt.Sprintf("Failed to post event %d to notifier %s: %s", event.ID, n.UUID, err.Error()))
+			}
+		}
+	}()
+}
+
+// Validate checks the settings of the notifier
+func Validate(n *store.Notifier) error {
+	switch n.Platform {
+	case store.NotifierPlatformDiscord, store.NotifierPlatformSlack, store.NotifierPlatformLark:
+		// the URLs are set by the game managers, internal addresses are refused
+		if err := util.CheckPublicURL(n.URL); err != nil {
+			return fmt.Errorf("invalid webhook URL: %w", err)
+		}
+	case store.NotifierPlatformTelegram:
+		if n.Token == "" || n.ChatID == "" {
+			return errors.New("bot token and chat ID are required")
+		}
+	default:
+		return errors.New("unknown platform")
+	}
+
+	for _, event := range n.Events {
+		if !slices.Contains(Events(), event) {
+			return fmt.Errorf("unknown event %s", event)
+		}
+	}
+
+	return ValidateTemplates(n.Templates)
+}
@@ -0,0 +1,121 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/store"
+)
+
+func TestRender(t *testing.T) {
+	data := &Data{Game: "hoshino", Challenge: "web", Team: "alice", Teams: []string{"alice"}}
+	solved := &store.GameEvent{Type: store.GameEventTypeChallengeSolved}
+	blood := &store.GameEvent{Type: store.GameEventTypeChallengeSolved, Blood: 1}
+
+	text, err := Render(&store.Notifier{}, solved, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Team alice solved web", text)
+
+	text, err = Render(&store.Notifier{}, blood, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Team alice got the first blood of web", text)
+
+	custom := &store.Notifier{Templates: map[string]string{
+		"challenge_solved": "{{.Team}} pwned {{.Challenge}} in {{.Game}}",
+	}}
+	text, err = Render(custom, solved, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice pwned web in hoshino", text)
+
+	// the bloods fall back to the custom template of the solved events
+	text, err = Render(custom, blood, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice pwned web in hoshino", text)
+}
+
+func TestValidate(t *testing.T) {
+	n := &store.Notifier{Platform: store.NotifierPlatformDiscord, URL: "https://discord.com/api/webhooks/1/a"}
+	assert.NoError(t, Validate(n))
+
+	n.Templates = map[string]string{"announcement": "{{.Content"}
+	assert.Error(t, Validate(n), "templates should be parsed")
+
+	n.Templates = map[string]string{"unknown": "{{.Content}}"}
+	assert.Error(t, Validate(n))
+
+	n.Templates = nil
+	n.Events = []string{"unknown"}
+	assert.Error(t, Validate(n))
+
+	assert.Error(t, Validate(&store.Notifier{Platform: store.NotifierPlatformSlack, URL: "http://127.0.0.1:8080/hook"}))
+	assert.Error(t, Validate(&store.Notifier{Platform: store.NotifierPlatformLark, URL: "http://169.254.169.254/"}))
+	assert.Error(t, Validate(&store.Notifier{Platform: store.NotifierPlatformTelegram, Token: "token"}))
+	assert.Error(t, Validate(&store.Notifier{Platform: "irc"}))
+}
+
+func receiver(t *testing.T, response string) (*httptest.Server, chan map[string]any) {
+	bodies := make(chan map[string]any, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		bodies <- body
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, bodies
+}
+
+func TestPlatforms(t *testing.T) {
+	d := NewDispatcher(nil)
+
+	server, bodies := receiver(t, "")
+	assert.ErrorIs(t, d.Send(&store.Notifier{Platform: store.NotifierPlatformDiscord, URL: server.URL}, "title", "text"),
+		util.ErrPrivateAddress, "the receivers on the internal addresses should be refused")
+
+	util.AllowPrivateAddresses = true
+	defer func() { util.AllowPrivateAddresses = false }()
+
+	assert.NoError(t, d.Send(&store.Notifier{Platform: store.NotifierPlatformDiscord, URL: server.URL}, "title", "text"))
+	embed := (<-bodies)["embeds"].([]any)[0].(map[string]any)
+	assert.Equal(t, "title", embed["title"])
+	assert.Equal(t, "text", embed["description"])
+
+	server, bodies = receiver(t, "ok")
+	assert.NoError(t, d.Send(&store.Notifier{Platform: store.NotifierPlatformSlack, URL: server.URL}, "title", "text"))
+	assert.Equal(t, "*title*\ntext", (<-bodies)["text"])
+
+	server, bodies = receiver(t, `{"code":0}`)
+	assert.NoError(t, d.Send(&store.Notifier{Platform: store.NotifierPlatformLark, URL: server.URL, Secret: "secret"}, "title", "text"))
+	body := <-bodies
+	assert.Equal(t, "text", body["msg_type"])
+	assert.Equal(t, larkSign(body["timestamp"].(string), "secret"), body["sign"])
+
+	server, bodies = receiver(t, `{"code":19021,"msg":"sign match fail"}`)
+	assert.Error(t, d.Send(&store.Notifier{Platform: store.NotifierPlatformLark, URL: server.URL}, "title", "text"))
+	<-bodies
+
+	server, bodies = receiver(t, `{"ok":true}`)
+	TelegramAPI = server.URL
+	assert.NoError(t, d.Send(&store.Notifier{Platform: store.NotifierPlatformTelegram, Token: "token", ChatID: "42"}, "title", "text"))
+	body = <-bodies
+	assert.Equal(t, "42", body["chat_id"])
+	assert.Equal(t, "title\n\ntext", body["text"])
+}
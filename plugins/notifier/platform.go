@@ -0,0 +1,117 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rina.icu/hoshino/store"
+)
+
+// TelegramAPI is the base URL of the Telegram Bot API
+var TelegramAPI = "https://api.telegram.org"
+
+func postJSON(url string, body any) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// buildRequest formats the message into the native message of the platform
+func buildRequest(n *store.Notifier, title string, text string) (*http.Request, error) {
+	switch n.Platform {
+	case store.NotifierPlatformDiscord:
+		return postJSON(n.URL, map[string]any{
+			"embeds": []map[string]any{{
+				"title":       title,
+				"description": text,
+			}},
+		})
+	case store.NotifierPlatformSlack:
+		return postJSON(n.URL, map[string]any{
+			"text": fmt.Sprintf("*%s*\n%s", title, text),
+		})
+	case store.NotifierPlatformTelegram:
+		return postJSON(fmt.Sprintf("%s/bot%s/sendMessage", TelegramAPI, n.Token), map[string]any{
+			"chat_id": n.ChatID,
+			"text":    fmt.Sprintf("%s\n\n%s", title, text),
+		})
+	case store.NotifierPlatformLark:
+		body := map[string]any{
+			"msg_type": "text",
+			"content": map[string]any{
+				"text": fmt.Sprintf("%s\n%s", title, text),
+			},
+		}
+		if n.Secret != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			body["timestamp"] = timestamp
+			body["sign"] = larkSign(timestamp, n.Secret)
+		}
+		return postJSON(n.URL, body)
+	default:
+		return nil, fmt.Errorf("unknown platform %s", n.Platform)
+	}
+}
+
+// larkSign signs the request of a Lark custom bot
+func larkSign(timestamp string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkResponse reports the error of the platform,
+// Telegram and Lark report some errors with a successful status
+func checkResponse(n *store.Notifier, res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d: %s", res.StatusCode, body)
+	}
+
+	switch n.Platform {
+	case store.NotifierPlatformTelegram:
+		var result struct {
+			OK bool `json:"ok"`
+		}
+		if json.Unmarshal(body, &result) != nil || !result.OK {
+			return fmt.Errorf("telegram error: %s", body)
+		}
+	case store.NotifierPlatformLark:
+		var result struct {
+			Code int `json:"code"`
+		}
+		if json.Unmarshal(body, &result) != nil || result.Code != 0 {
+			return fmt.Errorf("lark error: %s", body)
+		}
+	}
+	return nil
+}
@@ -14,4 +14,306 @@
 
 package webhook
 
-var ()
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/store"
+)
+
+const (
+	HeaderEvent     = "X-Hoshino-Event"
+	HeaderDelivery  = "X-Hoshino-Delivery"
+	HeaderSignature = "X-Hoshino-Signature"
+
+	// name of the test event, delivered regardless of the event filter
+	EventTest = "test"
+
+	// a delivery fails after this many attempts
+	MaxAttempts = 8
+
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+
+	pollInterval = 10 * time.Second
+	batchSize    = 50
+	workers      = 8
+
+	// size limit of the response body kept in the delivery log
+	responseLimit = 1024
+)
+
+// Payload is the JSON body sent to the webhooks
+type Payload struct {
+	// UUID of the delivery
+	ID    string `json:"id"`
+	Event string `json:"event"`
+
+	// UUID of the game
+	Game string `json:"game"`
+	Time int64  `json:"time"`
+	Data any    `json:"data"`
+}
+
+// Sign returns the signature header value of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature matches the body, for the receivers
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Backoff returns the delay before the next attempt after the given count of attempts
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+
+	backoff := baseBackoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
+
+// Send posts the signed body to the URL, returns the response status and the truncated response body
+func Send(client *http.Client, url string, secret string, event string, id string, body []byte) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Hoshino-Webhook")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, id)
+	req.Header.Set(HeaderSignature, Sign(secret, body))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(res.Body, responseLimit))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, string(response), fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, string(response), nil
+}
+
+// Dispatcher queues the deliveries in the database and delivers them in the background,
+// failed deliveries are retried with exponential backoff
+type Dispatcher struct {
+	store  *store.Store
+	client *http.Client
+	wake   chan struct{}
+}
+
+func NewDispatcher(s *store.Store) *Dispatcher {
+	return &Dispatcher{
+		store:  s,
+		client: util.PublicClient(10 * time.Second),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Start starts delivering the queue, including the deliveries left from the previous runs
+func (d *Dispatcher) Start() {
+	slog.Info("Starting webhook dispatcher")
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			d.flush()
+
+			select {
+			case <-ticker.C:
+			case <-d.wake:
+			}
+		}
+	}()
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// flush attempts all the due deliveries
+func (d *Dispatcher) flush() {
+	for {
+		deliveries, err := d.store.GetDueWebhookDeliveries(batchSize)
+		if err != nil {
+			slog.Error("Failed to get webhook deliveries: " + err.Error())
+			return
+		}
+
+		var wg sync.WaitGroup
+		queue := make(chan *store.WebhookDelivery)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for delivery := range queue {
+					d.Attempt(delivery)
+				}
+			}()
+		}
+		for _, delivery := range deliveries {
+			queue <- delivery
+		}
+		close(queue)
+		wg.Wait()
+
+		if len(deliveries) < batchSize {
+			return
+		}
+	}
+}
+
+// Enqueue creates a pending delivery of the event to the webhook
+func (d *Dispatcher) Enqueue(webhook *store.Webhook, game string, event string, data any) (*store.WebhookDelivery, error) {
+	delivery, err := d.create(webhook, game, event, data, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	d.notify()
+	return delivery, nil
+}
+
+func (d *Dispatcher) create(webhook *store.Webhook, game string, event string, data any, next time.Time) (*store.WebhookDelivery, error) {
+	now := time.Now()
+	id := util.UUID()
+
+	body, err := json.Marshal(&Payload{
+		ID:    id,
+		Event: event,
+		Game:  game,
+		Time:  now.UnixMilli(),
+		Data:  data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &store.WebhookDelivery{
+		UUID:        id,
+		WebhookID:   webhook.ID,
+		Webhook:     webhook,
+		Event:       event,
+		Payload:     string(body),
+		Status:      store.WebhookDeliveryPending,
+		NextAttempt: next.UnixMilli(),
+		CreatedTime: now.UnixMilli(),
+	}
+
+	if err := d.store.CreateWebhookDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// SendTest delivers a test event to the webhook right away,
+// it's retried in the background like any other delivery if failed
+func (d *Dispatcher) SendTest(webhook *store.Webhook, game string) (*store.WebhookDelivery, error) {
+	// scheduled later, so the background worker won't pick it up meanwhile
+	delivery, err := d.create(webhook, game, EventTest, map[string]any{
+		"message": "This is a test event from Hoshino",
+	}, time.Now().Add(Backoff(1)))
+	if err != nil {
+		return nil, err
+	}
+
+	d.Attempt(delivery)
+	return delivery, nil
+}
+
+// Attempt makes an attempt of the delivery and saves the result,
+// the delivery should be preloaded with the webhook
+func (d *Dispatcher) Attempt(delivery *store.WebhookDelivery) {
+	webhook := delivery.Webhook
+	if webhook == nil || !webhook.Enabled {
+		delivery.Status = store.WebhookDeliveryFailed
+		delivery.Response = "webhook disabled"
+	} else {
+		status, response, err := Send(d.client, webhook.URL, webhook.Secret, delivery.Event, delivery.UUID, []byte(delivery.Payload))
+
+		delivery.Attempts++
+		delivery.ResponseStatus = status
+		delivery.Response = response
+
+		if err == nil {
+			delivery.Status = store.WebhookDeliverySucceeded
+			delivery.DeliveredTime = time.Now().UnixMilli()
+		} else {
+			if response == "" {
+				delivery.Response = err.Error()
+			}
+
+			if delivery.Attempts >= MaxAttempts {
+				delivery.Status = store.WebhookDeliveryFailed
+			} else {
+				delivery.NextAttempt = time.Now().Add(Backoff(delivery.Attempts)).UnixMilli()
+			}
+		}
+	}
+
+	if err := d.store.UpdateWebhookDelivery(delivery); err != nil {
+		slog.Error(fmt.Sprintf("Failed to update webhook delivery %s: %s", delivery.UUID, err.Error()))
+	}
+}
+
+// HandleGameEvent queues the event for the enabled webhooks of its game, hidden events are not queued
+func (d *Dispatcher) HandleGameEvent(event *store.GameEvent) {
+	if !event.Visibility {
+		return
+	}
+
+	brief := event.Brief()
+
+	go func() {
+		webhooks, err := d.store.GetEnabledWebhooksByGameID(event.GameID)
+		if err != nil {
+			slog.Error("Failed to get webhooks: " + err.Error())
+			return
+		}
+
+		name := event.Name()
+		for _, webhook := range webhooks {
+			if webhook.Game == nil || !webhook.Accepts(name, event.Type.Name()) {
+				continue
+			}
+
+			if _, err := d.create(webhook, webhook.Game.UUID, name, brief, time.Now()); err != nil {
+				slog.Error(fmt.Sprintf("Failed to queue webhook delivery for %s: %s", webhook.UUID, err.Error()))
+			}
+		}
+
+		if len(webhooks) > 0 {
+			d.notify()
+		}
+	}()
+}
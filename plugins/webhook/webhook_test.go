@@ -0,0 +1,202 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/server/config"
+	"rina.icu/hoshino/store"
+)
+
+func TestSend(t *testing.T) {
+	var received *http.Request
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	payload := []byte(`{"event":"test"}`)
+	status, response, err := Send(server.Client(), server.URL, "secret", EventTest, "id", payload)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", response)
+
+	assert.Equal(t, payload, body)
+	assert.Equal(t, EventTest, received.Header.Get(HeaderEvent))
+	assert.Equal(t, "id", received.Header.Get(HeaderDelivery))
+	assert.True(t, Verify("secret", body, received.Header.Get(HeaderSignature)))
+	assert.False(t, Verify("another", body, received.Header.Get(HeaderSignature)))
+}
+
+func TestSendFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	status, response, err := Send(server.Client(), server.URL, "secret", EventTest, "id", []byte("{}"))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable\n", response)
+}
+
+func TestSendPrivate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	// the dispatcher doesn't reach the internal addresses
+	_, response, err := Send(NewDispatcher(nil).client, server.URL, "secret", EventTest, "id", []byte("{}"))
+	assert.ErrorIs(t, err, util.ErrPrivateAddress)
+	assert.Empty(t, response)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, 60*time.Second, Backoff(2))
+	assert.Equal(t, 4*time.Minute, Backoff(4))
+	assert.Equal(t, maxBackoff, Backoff(20))
+}
+
+func TestDispatcher(t *testing.T) {
+	s, err := store.GetStore(&config.Config{Driver: "sqlite", DataDir: t.TempDir()})
+	assert.NoError(t, err)
+
+	var fail atomic.Bool
+	fail.Store(true)
+	payloads := make(chan *Payload, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var payload Payload
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- &payload
+	}))
+	defer server.Close()
+
+	game := &store.Game{UUID: "game", Name: "game"}
+	webhook := &store.Webhook{UUID: "webhook", Game: game, URL: server.URL, Secret: "secret", Enabled: true}
+	assert.NoError(t, s.CreateWebhook(webhook))
+
+	util.AllowPrivateAddresses = true
+	defer func() { util.AllowPrivateAddresses = false }()
+
+	d := NewDispatcher(s)
+
+	delivery, err := d.SendTest(webhook, game.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, store.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseStatus)
+
+	// not due yet
+	deliveries, err := s.GetDueWebhookDeliveries(batchSize)
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	// the retry is delivered once the receiver recovers
+	fail.Store(false)
+	delivery.NextAttempt = 0
+	assert.NoError(t, s.UpdateWebhookDelivery(delivery))
+	d.flush()
+
+	payload := <-payloads
+	assert.Equal(t, delivery.UUID, payload.ID)
+	assert.Equal(t, EventTest, payload.Event)
+	assert.Equal(t, "game", payload.Game)
+
+	log, total, err := s.GetWebhookDeliveries(webhook, 0, 10)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, store.WebhookDeliverySucceeded, log[0].Status)
+	assert.Equal(t, 2, log[0].Attempts)
+}
+
+func TestHandleGameEvent(t *testing.T) {
+	s, err := store.GetStore(&config.Config{Driver: "sqlite", DataDir: t.TempDir()})
+	assert.NoError(t, err)
+
+	game := &store.Game{UUID: "game", Name: "game"}
+	webhook := &store.Webhook{UUID: "webhook", Game: game, URL: "http://127.0.0.1:0", Enabled: true}
+	assert.NoError(t, s.CreateWebhook(webhook))
+
+	d := NewDispatcher(s)
+
+	// silent cheat records and the solves after the freeze are hidden
+	d.HandleGameEvent(&store.GameEvent{GameID: game.ID, Type: store.GameEventTypeCheatDetected})
+	d.HandleGameEvent(&store.GameEvent{GameID: game.ID, Type: store.GameEventTypeNormal, Visibility: true})
+
+	assert.Eventually(t, func() bool {
+		_, total, _ := s.GetWebhookDeliveries(webhook, 0, 10)
+		return total > 0
+	}, time.Second, 10*time.Millisecond)
+
+	deliveries, total, err := s.GetWebhookDeliveries(webhook, 0, 10)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, "normal", deliveries[0].Event)
+}
+
+func TestHandlePublishedGameEvent(t *testing.T) {
+	s, err := store.GetStore(&config.Config{Driver: "sqlite", DataDir: t.TempDir()})
+	assert.NoError(t, err)
+
+	game := &store.Game{UUID: "game", Name: "game"}
+	webhook := &store.Webhook{UUID: "webhook", Game: game, URL: "http://127.0.0.1:0", Enabled: true}
+	assert.NoError(t, s.CreateWebhook(webhook))
+
+	d := NewDispatcher(s)
+	s.OnGameEvent(d.HandleGameEvent)
+
+	event := &store.GameEvent{GameID: game.ID, Type: store.GameEventTypeCheatDetected}
+	assert.NoError(t, s.CreateGameEvent(event))
+
+	// the hidden event is delivered once it is published
+	assert.NoError(t, s.PublishGameEvent(event))
+	assert.True(t, event.Visibility)
+
+	assert.Eventually(t, func() bool {
+		_, total, _ := s.GetWebhookDeliveries(webhook, 0, 10)
+		return total > 0
+	}, time.Second, 10*time.Millisecond)
+
+	deliveries, total, err := s.GetWebhookDeliveries(webhook, 0, 10)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, store.GameEventTypeCheatDetected.Name(), deliveries[0].Event)
+
+	// publishing a visible event again does nothing
+	assert.NoError(t, s.PublishGameEvent(event))
+	_, total, err = s.GetWebhookDeliveries(webhook, 0, 10)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+}
@@ -16,7 +16,11 @@ package context
 
 import (
 	"github.com/labstack/echo/v4"
+	"rina.icu/hoshino/internal/hub"
 	"rina.icu/hoshino/internal/k8s"
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/plugins/notifier"
+	"rina.icu/hoshino/plugins/webhook"
 	"rina.icu/hoshino/server/config"
 	"rina.icu/hoshino/store"
 )
@@ -27,4 +31,8 @@ type CustomContext struct {
 	Config           *config.Config
 	Store            *store.Store
 	ContainerManager *k8s.ContainerManager
+	Scoreboard       *scoreboard.Engine
+	Hub              *hub.Hub
+	Webhook          *webhook.Dispatcher
+	Notifier         *notifier.Dispatcher
 }
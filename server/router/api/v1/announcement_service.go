@@ -0,0 +1,119 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/server/context"
+	"rina.icu/hoshino/store"
+)
+
+type CreateAnnouncementPayload struct {
+	Title   string `json:"title" validate:"required"`
+	Content string `json:"content" validate:"required"`
+}
+
+func GetAnnouncements(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil || !(game.Visibility || user.HasPrivilege(store.UserPrivilegeAdministrator) || game.IsManager(user)) {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	announcements, err := ctx.Store.GetAnnouncementsByGame(game)
+	if err != nil {
+		return Failed(&c, "Unable to fetch announcements")
+	}
+
+	return OKWithData(&c, announcements)
+}
+
+func CreateAnnouncement(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	var payload CreateAnnouncementPayload
+	if err := c.Bind(&payload); err != nil || payload.Title == "" {
+		return Failed(&c, "Invalid payload")
+	}
+
+	announcement := &store.Announcement{
+		UUID:     util.UUID(),
+		Game:     game,
+		Title:    payload.Title,
+		Content:  payload.Content,
+		Author:   user,
+		PostedAt: time.Now().UnixMilli(),
+	}
+
+	if err := ctx.Store.CreateAnnouncement(announcement); err != nil {
+		return Failed(&c, "Unable to create announcement")
+	}
+
+	ctx.Store.CreateGameEvent(&store.GameEvent{
+		Content:    fmt.Sprintf("**%s**\n\n%s", announcement.Title, announcement.Content),
+		Game:       game,
+		Visibility: true,
+		Type:       store.GameEventTypeAnnouncement,
+	})
+
+	ctx.Store.NotifyGame(game, store.Notification{
+		Type:    store.NotificationTypeAnnouncement,
+		Title:   announcement.Title,
+		Content: announcement.Content,
+	})
+
+	return OKWithData(&c, map[string]any{"uuid": announcement.UUID})
+}
+
+func DeleteAnnouncement(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	announcement, err := ctx.Store.GetAnnouncementByUUID(c.Param("announcement_uuid"))
+	if err != nil || announcement.GameID != game.ID {
+		return Failed(&c, "Unable to fetch announcement")
+	}
+
+	if err := ctx.Store.DeleteAnnouncement(announcement); err != nil {
+		return Failed(&c, "Unable to delete announcement")
+	}
+
+	return OK(&c)
+}
@@ -15,7 +15,10 @@
 package v1
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -27,6 +30,41 @@ import (
 	"rina.icu/hoshino/store"
 )
 
+// saveUpload saves the uploaded file into the attachment storage,
+// returns the UUID and the path of the saved file
+func saveUpload(ctx *context.CustomContext, file *multipart.FileHeader) (string, string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", "", err
+	}
+	defer src.Close()
+
+	uuid := util.UUID()
+	dir := filepath.Join(ctx.Config.DataDir, "attachments")
+	dstPath := filepath.Join(dir, filepath.Clean(filepath.Base(uuid)))
+
+	if !strings.HasPrefix(dstPath, dir) {
+		return "", "", errors.New("invalid file path")
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", "", err
+	}
+
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer dst.Close()
+
+	if _, err = io.Copy(dst, src); err != nil {
+		os.Remove(dstPath)
+		return "", "", err
+	}
+
+	return uuid, dstPath, nil
+}
+
 func UploadAttachment(c echo.Context) error {
 	ctx := c.(*context.CustomContext)
 
@@ -55,25 +93,9 @@ func UploadAttachment(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to get file from request"})
 	}
 
-	src, err := file.Open()
+	uuid, dstPath, err := saveUpload(ctx, file)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open the file"})
-	}
-	defer src.Close()
-
-	uuid := util.UUID()
-	filename := filepath.Clean(filepath.Base(uuid))
-	dstPath := filepath.Join(ctx.Config.DataDir, "attachments", filename)
-
-	if !strings.HasPrefix(dstPath, filepath.Join(ctx.Config.DataDir, "attachments")) {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid file path"})
-	}
-
-	dst, err := os.Create(dstPath)
-	defer dst.Close()
-
-	if _, err = io.Copy(dst, src); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to copy the file"})
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save the file"})
 	}
 
 	downloadName := c.FormValue("download_name")
@@ -148,6 +170,10 @@ func GetAttachment(c echo.Context) error {
 		return PermissionDenied(&c)
 	}
 
+	if !canPlay(ctx.Store, game, user) {
+		return PermissionDenied(&c)
+	}
+
 	challenge, err := ctx.Store.GetChallengeByUUID(c.Param("challenge_uuid"))
 	if err != nil {
 		return Failed(&c, "Unable to fetch challenge")
@@ -157,6 +183,10 @@ func GetAttachment(c echo.Context) error {
 		return PermissionDenied(&c)
 	}
 
+	if !challengeProgress(ctx, game, user).Unlocked(challenge) {
+		return PermissionDenied(&c)
+	}
+
 	attachment, err := ctx.Store.GetAttachmentByUUID(c.Param("attachment_uuid"))
 	if err != nil {
 		return Failed(&c, "Unable to fetch attachment")
@@ -165,6 +195,63 @@ func GetAttachment(c echo.Context) error {
 	return c.Attachment(attachment.SavePath, attachment.DownloadName)
 }
 
+// flagAttachmentName is the name of the generated attachment of the team flag, see store.TeamFlagAttachment
+const flagAttachmentName = "flag.txt"
+
+// GetFlagAttachment downloads the flag of the team as a generated attachment
+func GetFlagAttachment(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	if user.Privilege < store.UserPrivilegeNormal {
+		return PermissionDenied(&c)
+	}
+
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.Visibility && !game.IsManager(user) {
+		return PermissionDenied(&c)
+	}
+
+	if !canPlay(ctx.Store, game, user) {
+		return PermissionDenied(&c)
+	}
+
+	team := game.GetTeamByUser(ctx.Store, user)
+	if team == nil || !team.CanPlay(game) {
+		return Failed(&c, "Unable to fetch team")
+	}
+
+	challenge, err := ctx.Store.GetChallengeByUUID(c.Param("challenge_uuid"))
+	if err != nil || challenge.GameID != game.ID || challenge.TeamFlag != store.TeamFlagAttachment {
+		return Failed(&c, "Unable to fetch challenge")
+	}
+
+	if !challenge.Game.Visibility && !game.IsManager(user) && !challenge.Ongoing() {
+		return PermissionDenied(&c)
+	}
+
+	if challenge.State != store.ChallengeStateVisible &&
+		!game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	if !challengeProgress(ctx, game, user).Unlocked(challenge) {
+		return PermissionDenied(&c)
+	}
+
+	flag, err := teamFlag(ctx.Store, game, challenge, team)
+	if err != nil {
+		return Failed(&c, "Unable to generate the flag")
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", flagAttachmentName))
+	return c.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, []byte(flag))
+}
+
 func GetAttachmentList(c echo.Context) error {
 	ctx := c.(*context.CustomContext)
 
@@ -187,6 +274,10 @@ func GetAttachmentList(c echo.Context) error {
 		return PermissionDenied(&c)
 	}
 
+	if !canPlay(ctx.Store, game, user) {
+		return PermissionDenied(&c)
+	}
+
 	challenge, err := ctx.Store.GetChallengeByUUID(c.Param("challenge_uuid"))
 	if err != nil {
 		return Failed(&c, "Unable to fetch challenge")
@@ -196,6 +287,10 @@ func GetAttachmentList(c echo.Context) error {
 		return PermissionDenied(&c)
 	}
 
+	if !challengeProgress(ctx, game, user).Unlocked(challenge) {
+		return PermissionDenied(&c)
+	}
+
 	attachments, err := ctx.Store.GetAttachmentsByChallenge(challenge)
 
 	if err != nil {
@@ -210,7 +305,7 @@ func GetAttachmentList(c echo.Context) error {
 		}
 
 		if attachment.Multiple {
-			ma, _ := ctx.Store.GetAttachmentsByName(attachment.Name)
+			ma, _ := ctx.Store.GetAttachmentsByChallengeAndName(challenge, attachment.Name)
 			index := util.SHA256Uint64(team.UUID+challenge.UUID+attachment.Name) % uint64(len(ma))
 			result = append(result, map[string]interface{}{
 				"uuid": ma[index].UUID,
@@ -228,5 +323,14 @@ func GetAttachmentList(c echo.Context) error {
 		}
 	}
 
+	if challenge.TeamFlag == store.TeamFlagAttachment {
+		// downloaded from GetFlagAttachment
+		attachments = append(attachments, store.Attachment{
+			UUID:         "flag",
+			Name:         flagAttachmentName,
+			DownloadName: flagAttachmentName,
+		})
+	}
+
 	return OKWithData(&c, attachments)
 }
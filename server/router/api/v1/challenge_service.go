@@ -15,7 +15,14 @@
 package v1
 
 import (
+	"fmt"
+	"log/slog"
+	"slices"
+
 	"github.com/labstack/echo/v4"
+	"rina.icu/hoshino/internal/flagcheck"
+	"rina.icu/hoshino/internal/scoreboard"
+	"rina.icu/hoshino/internal/unlock"
 	"rina.icu/hoshino/internal/util"
 	"rina.icu/hoshino/server/context"
 	"rina.icu/hoshino/store"
@@ -37,15 +44,121 @@ type CreateChallengePayload struct {
 	ExposedPort             int    `json:"exposed_port"`
 	RegistryAccessTokenUUID string `json:"registry_access_token"`
 
-	NoContainer  bool     `json:"no_container"`
-	Score        int      `json:"score"`
-	Difficulty   float32  `json:"difficulty"`
-	ScoreFormula string   `json:"score_formula"`
-	Hints        []string `json:"hints"`
+	NoContainer  bool          `json:"no_container"`
+	Score        int           `json:"score"`
+	Difficulty   float32       `json:"difficulty"`
+	ScoreFormula string        `json:"score_formula"`
+	Hints        []HintPayload `json:"hints"`
+
+	DynamicFlag   bool             `json:"dynamic_flag"`
+	StatelessFlag bool             `json:"stateless_flag"`
+	TeamFlag      int              `json:"team_flag"`
+	FlagFormat    string           `json:"flag_format"`
+	FlagMatch     int              `json:"flag_match"`
+	FakeFlag      []string         `json:"fake_flag"`
+	SubFlags      []SubFlagPayload `json:"sub_flags"`
+
+	Requires      []string `json:"requires"`
+	RequiredScore int      `json:"required_score"`
+	HideLocked    bool     `json:"hide_locked"`
+}
+
+type UpdateChallengePayload struct {
+	Name                *string   `json:"name"`
+	Description         *string   `json:"description"`
+	Category            *string   `json:"category"`
+	Tags                *[]string `json:"tags"`
+	StartTime           *int64    `json:"start_time"`
+	ExpireTime          *int64    `json:"expire_time"`
+	AfterExpiredOptions *int32    `json:"after_expired_options"`
+
+	Image                   *string `json:"image"`
+	MemoryLimit             *string `json:"memory_limit"`
+	CPULimit                *string `json:"cpu_limit"`
+	StorageLimit            *string `json:"storage_limit"`
+	ExposedPort             *int    `json:"exposed_port"`
+	RegistryAccessTokenUUID *string `json:"registry_access_token"`
+
+	NoContainer  *bool    `json:"no_container"`
+	Score        *int     `json:"score"`
+	Difficulty   *float32 `json:"difficulty"`
+	ScoreFormula *string  `json:"score_formula"`
+
+	DynamicFlag   *bool     `json:"dynamic_flag"`
+	StatelessFlag *bool     `json:"stateless_flag"`
+	TeamFlag      *int      `json:"team_flag"`
+	FlagFormat    *string   `json:"flag_format"`
+	FlagMatch     *int      `json:"flag_match"`
+	FakeFlag      *[]string `json:"fake_flag"`
+
+	Requires      *[]string `json:"requires"`
+	RequiredScore *int      `json:"required_score"`
+	HideLocked    *bool     `json:"hide_locked"`
+}
+
+// hasImage reports whether the payload sets any field of the image
+func (p *UpdateChallengePayload) hasImage() bool {
+	return p.Image != nil || p.MemoryLimit != nil || p.CPULimit != nil ||
+		p.StorageLimit != nil || p.ExposedPort != nil || p.RegistryAccessTokenUUID != nil
+}
+
+type ChallengeStatePayload struct {
+	State int `json:"state"`
+}
+
+type ReorderChallengesPayload struct {
+	// UUIDs of the challenges in the new order
+	Challenges []string `json:"challenges" validate:"required"`
+}
+
+// getGameChallenge returns the challenge of the request if it belongs to the game
+func getGameChallenge(c echo.Context, game *store.Game) (*store.Challenge, error) {
+	ctx := c.(*context.CustomContext)
+
+	challenge, err := ctx.Store.GetChallengeByUUID(c.Param("challenge_uuid"))
+	if err != nil || challenge.GameID != game.ID {
+		return nil, Failed(&c, "Unable to fetch challenge")
+	}
+	return challenge, nil
+}
+
+// challengeProgress returns the progress of the user's team for the prerequisites of the challenges,
+// managers get nil which unlocks every challenge
+func challengeProgress(ctx *context.CustomContext, game *store.Game, user *store.User) *unlock.Progress {
+	if game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return nil
+	}
+
+	team := game.GetTeamByUser(ctx.Store, user)
+	if team == nil {
+		return unlock.NewProgress(nil, 0)
+	}
+
+	solved, err := ctx.Store.GetSolvedChallengeUUIDs(team)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to get solved challenges: %s", err.Error()))
+	}
 
-	DynamicFlag bool     `json:"dynamic_flag"`
-	FlagFormat  string   `json:"flag_format"`
-	FakeFlag    []string `json:"fake_flag"`
+	score, _ := ctx.Scoreboard.Rank(game, team, false)
+	return unlock.NewProgress(solved, score)
+}
+
+// lockedChallenge strips the content of a locked challenge, leaving what is needed to show it as locked
+func lockedChallenge(challenge *store.Challenge) *store.Challenge {
+	return &store.Challenge{
+		Name:          challenge.Name,
+		UUID:          challenge.UUID,
+		State:         challenge.State,
+		Tags:          challenge.Tags,
+		Category:      challenge.Category,
+		StartTime:     challenge.StartTime,
+		ExpireTime:    challenge.ExpireTime,
+		Score:         challenge.Score,
+		Difficulty:    challenge.Difficulty,
+		Requires:      challenge.Requires,
+		RequiredScore: challenge.RequiredScore,
+		Locked:        true,
+	}
 }
 
 func CreateChallenge(c echo.Context) error {
@@ -67,6 +180,19 @@ func CreateChallenge(c echo.Context) error {
 		return Failed(&c, "Invalid payload")
 	}
 
+	hints, ok := newHints(payload.Hints)
+	if !ok {
+		return Failed(&c, "Invalid hints")
+	}
+
+	subFlags, err := newSubFlags(payload.SubFlags)
+	if err != nil {
+		return Failed(&c, "Invalid sub flags: "+err.Error())
+	}
+	if len(subFlags) > 0 && payload.DynamicFlag {
+		return Failed(&c, "Sub flags can't be used with dynamic flags")
+	}
+
 	uuid := util.UUID()
 	challenge := &store.Challenge{
 		Name:                   payload.Name,
@@ -84,6 +210,7 @@ func CreateChallenge(c echo.Context) error {
 			Name:                    payload.Image,
 			MemoryLimit:             payload.MemoryLimit,
 			CPULimit:                payload.CPULimit,
+			StorageLimit:            payload.StorageLimit,
 			RegistryAccessTokenUUID: payload.RegistryAccessTokenUUID,
 			ExposedPort:             payload.ExposedPort,
 		},
@@ -92,11 +219,33 @@ func CreateChallenge(c echo.Context) error {
 		Score:        payload.Score,
 		Difficulty:   payload.Difficulty,
 		ScoreFormula: payload.ScoreFormula,
-		Hints:        payload.Hints,
+		Hints:        hints,
+
+		DynamicFlag:   payload.DynamicFlag,
+		StatelessFlag: payload.StatelessFlag,
+		TeamFlag:      store.TeamFlagDelivery(payload.TeamFlag),
+		FlagFormat:    payload.FlagFormat,
+		FlagMatch:     store.FlagMatch(payload.FlagMatch),
+		FakeFlag:      payload.FakeFlag,
+		SubFlags:      subFlags,
+
+		Requires:      payload.Requires,
+		RequiredScore: payload.RequiredScore,
+		HideLocked:    payload.HideLocked,
+
+		// appended to the end of the game
+		Position: len(game.Challenges),
+	}
+
+	if err := scoreboard.ValidateFormula(challenge.ScoreFormula); err != nil {
+		return Failed(&c, "Invalid score formula: "+err.Error())
+	}
+	if err := flagcheck.Validate(challenge); err != nil {
+		return Failed(&c, "Invalid flag: "+err.Error())
+	}
 
-		DynamicFlag: payload.DynamicFlag,
-		FlagFormat:  payload.FlagFormat,
-		FakeFlag:    payload.FakeFlag,
+	if err := unlock.Validate(append(slices.Clone(game.Challenges), challenge)); err != nil {
+		return Failed(&c, "Invalid prerequisites: "+err.Error())
 	}
 
 	ctx.Store.CreateChallenge(challenge)
@@ -120,6 +269,36 @@ func GetFullChallenge(c echo.Context) error {
 		return PermissionDenied(&c)
 	}
 
+	if !canPlay(ctx.Store, challenge.Game, user) {
+		return PermissionDenied(&c)
+	}
+
+	if challenge.State != store.ChallengeStateVisible &&
+		!challenge.Game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	if !challengeProgress(ctx, challenge.Game, user).Unlocked(challenge) {
+		if challenge.HideLocked {
+			return PermissionDenied(&c)
+		}
+		return OKWithData(&c, lockedChallenge(challenge))
+	}
+
+	hints, err := ctx.Store.GetHintsByChallenge(challenge)
+	if err != nil {
+		return Failed(&c, "Unable to fetch hints")
+	}
+	challenge.Hints = visibleHints(ctx, challenge.Game, user, hints)
+
+	subFlags, err := ctx.Store.GetSubFlagsByChallenge(challenge)
+	if err != nil {
+		return Failed(&c, "Unable to fetch sub flags")
+	}
+	manager := challenge.Game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator)
+	challenge.SubFlags = withFound(subFlags, foundSubFlags(ctx, challenge.Game, user), manager)
+	withTeamFlag(ctx.Store, challenge.Game, challenge, challenge.Game.GetTeamByUser(ctx.Store, user))
+
 	return OKWithData(&c, challenge)
 }
 
@@ -137,16 +316,49 @@ func GetFullChallenges(c echo.Context) error {
 		return PermissionDenied(&c)
 	}
 
-	challenges := game.Challenges
-	filtered := make([]*store.Challenge, 0)
+	if !canPlay(ctx.Store, game, user) {
+		return PermissionDenied(&c)
+	}
+
+	// hidden and disabled challenges are only listed to the managers
+	manager := game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator)
+	challenges := game.GetChallenges(manager)
+
+	hints, err := ctx.Store.GetHintsByGame(game)
+	if err != nil {
+		return Failed(&c, "Unable to fetch hints")
+	}
+
+	byChallenge := make(map[uint][]*store.Hint)
+	for _, hint := range visibleHints(ctx, game, user, hints) {
+		byChallenge[hint.ChallengeID] = append(byChallenge[hint.ChallengeID], hint)
+	}
+
+	subFlags, err := ctx.Store.GetSubFlagsByGame(game)
+	if err != nil {
+		return Failed(&c, "Unable to fetch sub flags")
+	}
+
+	subFlagsByChallenge := make(map[uint][]*store.SubFlag)
+	for _, flag := range withFound(subFlags, foundSubFlags(ctx, game, user), manager) {
+		subFlagsByChallenge[flag.ChallengeID] = append(subFlagsByChallenge[flag.ChallengeID], flag)
+	}
+
+	progress := challengeProgress(ctx, game, user)
+	team := game.GetTeamByUser(ctx.Store, user)
+	result := make([]*store.Challenge, 0, len(challenges))
 	for _, challenge := range challenges {
-		if !game.Visibility && !user.HasPrivilege(store.UserPrivilegeAdministrator) && !game.IsManager(user) {
-			continue
+		if progress.Unlocked(challenge) {
+			challenge.Hints = byChallenge[challenge.ID]
+			challenge.SubFlags = subFlagsByChallenge[challenge.ID]
+			withTeamFlag(ctx.Store, game, challenge, team)
+			result = append(result, challenge)
+		} else if !challenge.HideLocked {
+			result = append(result, lockedChallenge(challenge))
 		}
-		filtered = append(filtered, challenge)
 	}
 
-	return OKWithData(&c, filtered)
+	return OKWithData(&c, result)
 }
 
 func GetChallengeStatus(c echo.Context) error {
@@ -163,34 +375,316 @@ func GetChallengeStatus(c echo.Context) error {
 		return PermissionDenied(&c)
 	}
 
-	challenges := game.Challenges
-	filtered := make([]*store.Challenge, 0)
-	for _, challenge := range challenges {
-		if !game.Visibility && !user.HasPrivilege(store.UserPrivilegeAdministrator) && !game.IsManager(user) {
-			continue
-		}
-		filtered = append(filtered, challenge)
+	if !canPlay(ctx.Store, game, user) {
+		return PermissionDenied(&c)
 	}
 
+	// hidden and disabled challenges are only listed to the managers
+	manager := game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator)
+	challenges := game.GetChallenges(manager)
+
 	resp := map[string]any{}
 
 	team := game.GetTeamByUser(ctx.Store, user)
+	frozen := scoreboardFrozen(game, user)
+	progress := challengeProgress(ctx, game, user)
+
+	subFlags, err := ctx.Store.GetSubFlagsByGame(game)
+	if err != nil {
+		return Failed(&c, "Unable to fetch sub flags")
+	}
+
+	subFlagsByChallenge := make(map[uint][]*store.SubFlag)
+	for _, flag := range withFound(subFlags, foundSubFlags(ctx, game, user), manager) {
+		subFlagsByChallenge[flag.ChallengeID] = append(subFlagsByChallenge[flag.ChallengeID], flag)
+	}
+
+	for _, challenge := range challenges {
+		locked := !progress.Unlocked(challenge)
+		if locked && challenge.HideLocked {
+			continue
+		}
+
+		// found sub flags of the multi-part challenges, by name and weight
+		parts := []map[string]any{}
+		found := 0
+		for _, flag := range subFlagsByChallenge[challenge.ID] {
+			parts = append(parts, map[string]any{
+				"name":   flag.Name,
+				"weight": flag.Weight,
+				"found":  flag.Found,
+			})
+			if flag.Found {
+				found += flag.Weight
+			}
+		}
+
+		bloods := ctx.Scoreboard.Bloods(game, challenge, frozen)
+
+		// solves after the freeze are not counted until the scoreboard is revealed
+		solvedCount := challenge.GetSolvedCount(ctx.Store)
+		if frozen {
+			solvedCount = ctx.Scoreboard.SolvedCount(game, challenge, true)
+		}
+
+		if team != nil && challenge.IsSolvedBy(team, ctx.Store) {
+			blood := 0
+			for _, b := range bloods {
+				if b.Team == team.UUID {
+					blood = b.Blood
+				}
+			}
 
-	for _, challenge := range filtered {
-		if challenge.IsSolvedBy(team, ctx.Store) {
 			resp[challenge.UUID] = map[string]any{
 				"solved":       true,
 				"score":        challenge.GetScore(team, ctx.Store),
-				"solved_count": challenge.GetSolvedCount(ctx.Store),
+				"solved_count": solvedCount,
+				"blood":        blood,
+				"bloods":       bloods,
+				"locked":       locked,
+				"sub_flags":    parts,
+				"progress":     100,
 			}
 		} else {
 			resp[challenge.UUID] = map[string]any{
 				"solved":       false,
 				"score":        0,
-				"solved_count": challenge.GetSolvedCount(ctx.Store),
+				"solved_count": solvedCount,
+				"blood":        0,
+				"bloods":       bloods,
+				"locked":       locked,
+				"sub_flags":    parts,
+				"progress":     found,
 			}
 		}
 	}
 
 	return OKWithData(&c, resp)
 }
+
+func UpdateChallenge(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	var payload UpdateChallengePayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	rescore := (payload.Score != nil && *payload.Score != challenge.Score) ||
+		(payload.Difficulty != nil && *payload.Difficulty != challenge.Difficulty) ||
+		(payload.ScoreFormula != nil && *payload.ScoreFormula != challenge.ScoreFormula)
+
+	if payload.Name != nil {
+		challenge.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		challenge.Description = *payload.Description
+	}
+	if payload.Category != nil {
+		challenge.Category = *payload.Category
+	}
+	if payload.Tags != nil {
+		challenge.Tags = *payload.Tags
+	}
+	if payload.StartTime != nil && *payload.StartTime != challenge.StartTime {
+		// schedule the release again
+		challenge.StartTime = *payload.StartTime
+		challenge.ReleasedAt = 0
+	}
+	if payload.ExpireTime != nil && *payload.ExpireTime != challenge.ExpireTime {
+		// schedule the close again
+		challenge.ExpireTime = *payload.ExpireTime
+		challenge.ClosedAt = 0
+	}
+	if payload.AfterExpiredOptions != nil {
+		challenge.AfterExpiredOperations = store.AfterExpireOp(*payload.AfterExpiredOptions)
+	}
+
+	// the image is created only when the payload configures it
+	if challenge.Image == nil && payload.hasImage() {
+		challenge.Image = &store.Image{}
+	}
+	if payload.Image != nil {
+		challenge.Image.Name = *payload.Image
+	}
+	if payload.MemoryLimit != nil {
+		challenge.Image.MemoryLimit = *payload.MemoryLimit
+	}
+	if payload.CPULimit != nil {
+		challenge.Image.CPULimit = *payload.CPULimit
+	}
+	if payload.StorageLimit != nil {
+		challenge.Image.StorageLimit = *payload.StorageLimit
+	}
+	if payload.ExposedPort != nil {
+		challenge.Image.ExposedPort = *payload.ExposedPort
+	}
+	if payload.RegistryAccessTokenUUID != nil {
+		challenge.Image.RegistryAccessTokenUUID = *payload.RegistryAccessTokenUUID
+	}
+
+	if payload.NoContainer != nil {
+		challenge.NoContainer = *payload.NoContainer
+	}
+	if payload.Score != nil {
+		challenge.Score = *payload.Score
+	}
+	if payload.Difficulty != nil {
+		challenge.Difficulty = *payload.Difficulty
+	}
+	if payload.ScoreFormula != nil {
+		challenge.ScoreFormula = *payload.ScoreFormula
+	}
+	if payload.DynamicFlag != nil {
+		challenge.DynamicFlag = *payload.DynamicFlag
+		if subFlags, _ := ctx.Store.GetSubFlagsByChallenge(challenge); challenge.DynamicFlag && len(subFlags) > 0 {
+			return Failed(&c, "Sub flags can't be used with dynamic flags")
+		}
+	}
+	if payload.StatelessFlag != nil {
+		challenge.StatelessFlag = *payload.StatelessFlag
+	}
+	if payload.TeamFlag != nil {
+		challenge.TeamFlag = store.TeamFlagDelivery(*payload.TeamFlag)
+	}
+	if payload.FlagFormat != nil {
+		challenge.FlagFormat = *payload.FlagFormat
+	}
+	if payload.FlagMatch != nil {
+		challenge.FlagMatch = store.FlagMatch(*payload.FlagMatch)
+	}
+	if payload.FakeFlag != nil {
+		challenge.FakeFlag = *payload.FakeFlag
+	}
+	if payload.Requires != nil {
+		challenge.Requires = *payload.Requires
+	}
+	if payload.RequiredScore != nil {
+		challenge.RequiredScore = *payload.RequiredScore
+	}
+	if payload.HideLocked != nil {
+		challenge.HideLocked = *payload.HideLocked
+	}
+
+	if err := scoreboard.ValidateFormula(challenge.ScoreFormula); err != nil {
+		return Failed(&c, "Invalid score formula: "+err.Error())
+	}
+	if err := flagcheck.Validate(challenge); err != nil {
+		return Failed(&c, "Invalid flag: "+err.Error())
+	}
+
+	// validate the prerequisites with the edited challenge in place
+	challenges := slices.Clone(game.Challenges)
+	for i, other := range challenges {
+		if other.ID == challenge.ID {
+			challenges[i] = challenge
+		}
+	}
+	if err := unlock.Validate(challenges); err != nil {
+		return Failed(&c, "Invalid prerequisites: "+err.Error())
+	}
+
+	if err := ctx.Store.UpdateChallenge(challenge); err != nil {
+		return Failed(&c, "Unable to update challenge")
+	}
+
+	if rescore {
+		ctx.Scoreboard.Rescore(game, challenge)
+	}
+
+	return OK(&c)
+}
+
+// SetChallengeState disables, hides or shows the challenge
+func SetChallengeState(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	var payload ChallengeStatePayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	state := store.ChallengeState(payload.State)
+	if state < store.ChallengeStateDisabled || state > store.ChallengeStateVisible {
+		return Failed(&c, "Invalid state")
+	}
+
+	challenge.State = state
+	if err := ctx.Store.UpdateChallenge(challenge); err != nil {
+		return Failed(&c, "Unable to update challenge")
+	}
+
+	return OK(&c)
+}
+
+// DeleteChallenge soft-deletes the challenge and removes its solves from the scoreboard
+func DeleteChallenge(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	for _, other := range game.Challenges {
+		if slices.Contains(other.Requires, challenge.UUID) {
+			return Failed(&c, fmt.Sprintf("Challenge is required by `%s`", other.Name))
+		}
+	}
+
+	if err := ctx.Store.DeleteChallenge(challenge); err != nil {
+		return Failed(&c, "Unable to delete challenge")
+	}
+
+	if err := ctx.Scoreboard.Reload(game); err != nil {
+		slog.Error(fmt.Sprintf("Failed to reload the scoreboard of game %s: %s", game.UUID, err.Error()))
+	}
+
+	return OK(&c)
+}
+
+// ReorderChallenges sets the positions of the challenges to the order of the payload
+func ReorderChallenges(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	var payload ReorderChallengesPayload
+	if err := c.Bind(&payload); err != nil || len(payload.Challenges) == 0 {
+		return Failed(&c, "Invalid payload")
+	}
+
+	if err := ctx.Store.UpdateChallengePositions(game, payload.Challenges); err != nil {
+		return Failed(&c, "Unable to reorder challenges")
+	}
+
+	return OK(&c)
+}
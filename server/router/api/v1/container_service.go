@@ -50,12 +50,34 @@ func CreateChallengeContainer(c echo.Context) error {
 		return Failed(&c, "You are not in a team.")
 	}
 
+	if !team.CanPlay(challenge.Game) {
+		return Failed(&c, "Your team has not been approved.")
+	}
+
+	if !challengeProgress(ctx, challenge.Game, user).Unlocked(challenge) {
+		return Failed(&c, "Challenge is locked.")
+	}
+
 	// the container is still allowed to be created after the challenge is solved
 	// if challenge.IsSolvedBy(team, ctx.Store) {
 	// 	return Failed(&c, "You have solved this challenge.")
 	// }
 
-	flag := fmt.Sprintf("%s{%s}", challenge.Game.FlagPrefix, util.GenerateFlagContent(challenge.FlagFormat, user.UUID))
+	secret, err := ctx.Store.GetFlagSecret(challenge)
+	if err != nil {
+		return Failed(&c, "Unable to create container.")
+	}
+	var flag string
+	if challenge.StatelessFlag {
+		flag = statelessFlag(challenge.Game, challenge, secret, team)
+	} else {
+		flag = renderFlag(challenge.Game, challenge.FlagFormat, util.FlagParams{
+			Seed: user.UUID,
+			Team: team.Name,
+			Key:  secret,
+			Time: time.Now(),
+		})
+	}
 
 	if !ctx.Store.CanCreateContainer(user) {
 		return Failed(&c, "You have reached the maximum number of containers.")
@@ -81,7 +103,10 @@ func CreateChallengeContainer(c echo.Context) error {
 	}
 
 	ctx.Store.CreateContainer(containerModel)
-	ctx.Store.CreateFlag(flagModel)
+	if !challenge.StatelessFlag {
+		// the stateless flags are recorded when they are submitted
+		ctx.Store.CreateFlag(flagModel)
+	}
 
 	if err != nil {
 		return Failed(&c, "Unable to create container.")
@@ -143,7 +168,12 @@ func CreateContainer(c echo.Context) error {
 
 	identifier := "test-" + util.SHA256(util.UUID())[:16]
 
-	flag := fmt.Sprintf("%s{%s}", game.FlagPrefix, util.GenerateFlagContent(c.QueryParams().Get("flag_format"), user.UUID))
+	flag := renderFlag(game, c.QueryParams().Get("flag_format"), util.FlagParams{
+		Seed: user.UUID,
+		Team: "test",
+		Key:  util.RandomHex(32),
+		Time: time.Now(),
+	})
 	// this is a test container, so we don't need to create the flag model
 
 	info := &k8s.ContainerInfo{
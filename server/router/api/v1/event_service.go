@@ -0,0 +1,240 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/cast"
+	"golang.org/x/net/websocket"
+	"rina.icu/hoshino/internal/hub"
+	"rina.icu/hoshino/server/context"
+	"rina.icu/hoshino/store"
+)
+
+type EventVisibilityPayload struct {
+	Visibility bool `json:"visibility"`
+}
+
+// GetGameEvents returns the events of the game, the players only see the visible ones.
+// Filtered by the type names, the challenge UUID and the team UUID in the query.
+func GetGameEvents(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil || !(game.Visibility || user.HasPrivilege(store.UserPrivilegeAdministrator) || game.IsManager(user)) {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !canPlay(ctx.Store, game, user) {
+		return PermissionDenied(&c)
+	}
+
+	filter := store.GameEventFilter{
+		VisibleOnly: !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator),
+	}
+
+	if types := c.QueryParam("type"); types != "" {
+		for _, name := range strings.Split(types, ",") {
+			t, ok := store.EventTypeByName(name)
+			if !ok {
+				return Failed(&c, "Invalid event type")
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	if uuid := c.QueryParam("challenge"); uuid != "" {
+		challenge, err := ctx.Store.GetChallengeByUUID(uuid)
+		if err != nil || challenge.GameID != game.ID {
+			return Failed(&c, "Unable to fetch challenge")
+		}
+		filter.ChallengeID = challenge.ID
+	}
+
+	if uuid := c.QueryParam("team"); uuid != "" {
+		team, err := ctx.Store.GetTeamByUUID(uuid)
+		if err != nil || team.GameID != game.ID {
+			return Failed(&c, "Unable to fetch team")
+		}
+		filter.TeamID = team.ID
+	}
+
+	page := max(cast.ToInt(c.QueryParam("page")), 1)
+	size := cast.ToInt(c.QueryParam("size"))
+	if size <= 0 || size > 100 {
+		size = 20
+	}
+
+	events, total, err := ctx.Store.GetGameEvents(game, filter, (page-1)*size, size)
+	if err != nil {
+		return Failed(&c, "Unable to fetch events")
+	}
+
+	briefs := make([]map[string]any, 0, len(events))
+	for _, event := range events {
+		briefs = append(briefs, event.Brief())
+	}
+
+	return OKWithData(&c, map[string]any{
+		"total":  total,
+		"page":   page,
+		"size":   size,
+		"events": briefs,
+	})
+}
+
+// SetGameEventVisibility publishes or hides an event, e.g. a silently logged cheat
+func SetGameEventVisibility(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	event, err := ctx.Store.GetGameEventByID(cast.ToUint(c.Param("event_id")))
+	if err != nil || event.GameID != game.ID {
+		return Failed(&c, "Unable to fetch event")
+	}
+
+	var payload EventVisibilityPayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	if payload.Visibility {
+		// the newly visible event goes to the players and the integrations
+		err = ctx.Store.PublishGameEvent(event)
+	} else {
+		event.Visibility = false
+		err = ctx.Store.UpdateGameEventVisibility(event)
+	}
+	if err != nil {
+		return Failed(&c, "Unable to update event")
+	}
+
+	return OK(&c)
+}
+
+// interval of the keep-alive messages of the event streams
+const streamKeepAlive = 30 * time.Second
+
+// StreamGameEvents streams the messages of the game over WebSocket or Server-Sent Events,
+// clients resume with the Last-Event-ID header or the last_event_id query
+func StreamGameEvents(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil || !(game.Visibility || user.HasPrivilege(store.UserPrivilegeAdministrator) || game.IsManager(user)) {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !canPlay(ctx.Store, game, user) {
+		return PermissionDenied(&c)
+	}
+
+	last := cast.ToUint64(c.Request().Header.Get("Last-Event-ID"))
+	if last == 0 {
+		last = cast.ToUint64(c.QueryParam("last_event_id"))
+	}
+
+	manager := game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator)
+	sub := ctx.Hub.Subscribe(game.ID, manager, last)
+	defer ctx.Hub.Unsubscribe(sub)
+
+	if c.IsWebSocket() {
+		streamWebSocket(c, sub)
+		return nil
+	}
+	return streamSSE(c, sub)
+}
+
+func streamWebSocket(c echo.Context, sub *hub.Subscriber) {
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		// the client sends nothing, reading only detects the closed connection
+		closed := make(chan struct{})
+		go func() {
+			io.Copy(io.Discard, ws)
+			close(closed)
+		}()
+
+		ticker := time.NewTicker(streamKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case m, ok := <-sub.C:
+				if !ok || websocket.JSON.Send(ws, m) != nil {
+					return
+				}
+			case <-ticker.C:
+				if websocket.JSON.Send(ws, &hub.Message{Type: hub.MessageTypePing}) != nil {
+					return
+				}
+			}
+		}
+	}).ServeHTTP(c.Response(), c.Request())
+}
+
+func streamSSE(c echo.Context, sub *hub.Subscriber) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case m, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+
+			data, err := json.Marshal(m.Data)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", m.ID, m.Type, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
@@ -15,13 +15,16 @@
 package v1
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
-	"math"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/Knetic/govaluate"
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+	"rina.icu/hoshino/internal/scoreboard"
 	"rina.icu/hoshino/internal/util"
 	"rina.icu/hoshino/server/context"
 	"rina.icu/hoshino/store"
@@ -31,6 +34,13 @@ type (
 	SubmitFlagPayloads struct {
 		Flag string `json:"flag" validate:"required"`
 	}
+
+	PreviewFlagPayload struct {
+		FlagFormat string `json:"flag_format"`
+
+		// team name filled into {team}, optional
+		Team string `json:"team"`
+	}
 )
 
 type CheatReason int
@@ -41,19 +51,58 @@ const (
 	CheatReasonFakeFlag
 )
 
-func anticheatCheck(_ *echo.Context, s *store.Store,
+// banTeam bans the team instantly and notifies its members
+func banTeam(s *store.Store, engine *scoreboard.Engine, game *store.Game, team *store.Team) {
+	team.Banned = true
+	s.UpdateTeam(team)
+	engine.UpdateTeam(game, team)
+
+	s.NotifyTeam(team, store.Notification{
+		GameID:   game.ID,
+		GameUUID: game.UUID,
+		Type:     store.NotificationTypeTeamBanned,
+		Title:    "Your team has been banned",
+		Content:  fmt.Sprintf("Team `%s` has been banned from `%s` for cheating.", team.Name, game.Name),
+	})
+}
+
+// createSolvedEvent records the solve of the team, the bloods are marked by the solve order
+// of the scoreboard, which is also the order used by the score formula.
+// Solves after the freeze are hidden until the scoreboard is revealed.
+func createSolvedEvent(s *store.Store, game *store.Game, challenge *store.Challenge, team *store.Team, order int) {
+	event := store.GameEvent{
+		Content:      fmt.Sprintf("Team `%s` solved `%s`", team.Name, challenge.Name),
+		Game:         game,
+		Challenge:    challenge,
+		RelatedTeams: []*store.Team{team},
+		Visibility:   !game.Frozen(),
+		Type:         store.GameEventTypeChallengeSolved,
+	}
+
+	if ordinal := store.BloodOrdinal(order); ordinal != "" {
+		event.Blood = order
+		event.Content = fmt.Sprintf("Team `%s` got the %s blood of `%s`", team.Name, ordinal, challenge.Name)
+	}
+
+	if err := s.CreateGameEvent(&event); err != nil {
+		slog.Error(fmt.Sprintf("Failed to create solved event: %s", err.Error()))
+	}
+}
+
+func anticheatCheck(c *echo.Context, s *store.Store,
 	flag string,
 	team *store.Team,
 	challenge *store.Challenge,
 ) (bool, CheatReason) {
 	// anti-cheat
+	engine := (*c).(*context.CustomContext).Scoreboard
 
 	if challenge.DynamicFlag {
 		// check if the flag was shared by multiple teams
-		flag, err := s.GetFlagByChallenge(flag, challenge)
+		flag, err := sharedFlag(s, challenge, flag)
 		if err != nil {
 			slog.Error(fmt.Sprintf("Failed to get flag: %s ", err.Error()))
-		} else {
+		} else if flag != nil {
 			if flag.Team.ID != team.ID {
 				event := store.GameEvent{
 					Content:      fmt.Sprintf("Team `%s` shared the flag `%s` with team `%s`", team.Name, flag.Flag, flag.Team.Name),
@@ -66,11 +115,10 @@ func anticheatCheck(_ *echo.Context, s *store.Store,
 
 				flag.State = store.FlagCheated
 				s.UpdateFlag(flag)
+				engine.Update(challenge.Game, flag)
 
 				if challenge.Game.AutoBan {
-					// ban the team instantly
-					team.Banned = true
-					s.UpdateTeam(team)
+					banTeam(s, engine, challenge.Game, team)
 				} else {
 					// log the cheat silently
 					event.Visibility = false
@@ -94,7 +142,7 @@ func anticheatCheck(_ *echo.Context, s *store.Store,
 			}
 
 			if attachment.Multiple {
-				ma, _ := s.GetAttachmentsByName(attachment.Name)
+				ma, _ := s.GetAttachmentsByChallengeAndName(challenge, attachment.Name)
 				index := util.SHA256Uint64(team.UUID+challenge.UUID+attachment.Name) % uint64(len(ma))
 				for i, a := range ma {
 					if a.Flag == flag && i == int(index) {
@@ -112,9 +160,7 @@ func anticheatCheck(_ *echo.Context, s *store.Store,
 						}
 
 						if challenge.Game.AutoBan {
-							// ban the team instantly
-							team.Banned = true
-							s.UpdateTeam(team)
+							banTeam(s, engine, challenge.Game, team)
 						} else {
 							// log the cheat silently
 							event.Visibility = false
@@ -143,9 +189,7 @@ func anticheatCheck(_ *echo.Context, s *store.Store,
 			}
 
 			if challenge.Game.AutoBan {
-				// ban the team instantly
-				team.Banned = true
-				s.UpdateTeam(team)
+				banTeam(s, engine, challenge.Game, team)
 			} else {
 				// log the cheat silently
 				event.Visibility = false
@@ -159,71 +203,6 @@ func anticheatCheck(_ *echo.Context, s *store.Store,
 	return true, CheatReasonNone
 }
 
-func updateScore(s *store.Store, challenge *store.Challenge) {
-	// update the score of the challenge
-	solvedFlags, err := s.GetSolvedFlagsByChallenge(challenge)
-	if err != nil {
-		slog.Error(fmt.Sprintf("Failed to get solved flags: %s ", err.Error()))
-		return
-	}
-
-	solvedRate := float64(len(solvedFlags)) / float64(len(challenge.Game.GetTeams(s)))
-	lossRate := float64(len(solvedFlags)-1) / float64(len(challenge.Game.GetTeams(s)))
-	exponentialScore := float64(challenge.Score) * math.Exp((float64(challenge.Difficulty)-2)*lossRate)
-	parameters := make(map[string]interface{})
-	parameters["original_score"] = challenge.Score
-	parameters["solved_count"] = len(solvedFlags)
-	parameters["team_count"] = len(challenge.Game.GetTeams(s))
-	parameters["difficulty"] = challenge.Difficulty
-	parameters["loss_rate"] = lossRate
-	parameters["solved_rate"] = solvedRate
-	parameters["unsolved_rate"] = 1 - solvedRate
-	parameters["linear_score"] = float64(challenge.Score) * (1 - lossRate)
-	parameters["exponential_score"] = exponentialScore
-
-	slog.Info(fmt.Sprintf("Original score: %d, Solved count: %d, Team count: %d, Difficulty: %f, Loss rate: %f, Solved rate: %f, Unsolved rate: %f, Linear score: %f, Exponential score: %f", challenge.Score, len(solvedFlags), len(challenge.Game.GetTeams(s)), challenge.Difficulty, lossRate, solvedRate, 1-solvedRate, float64(challenge.Score)*(1-lossRate), exponentialScore))
-
-	functions := map[string]govaluate.ExpressionFunction{
-		"max": func(args ...interface{}) (interface{}, error) {
-			return math.Max(args[0].(float64), args[1].(float64)), nil
-		},
-		"min": func(args ...interface{}) (interface{}, error) {
-			return math.Min(args[0].(float64), args[1].(float64)), nil
-		},
-		"exponential_score_with_top3_bonus": func(args ...interface{}) (interface{}, error) {
-			order := args[0].(uint32)
-			rate1 := args[1].(float64)
-			rate2 := args[2].(float64)
-			rate3 := args[3].(float64)
-			if order == 1 {
-				return exponentialScore * rate1, nil
-			} else if order == 2 {
-				return exponentialScore * rate2, nil
-			} else if order == 3 {
-				return exponentialScore * rate3, nil
-			}
-			return exponentialScore, nil
-		},
-	}
-
-	expression, err := govaluate.NewEvaluableExpressionWithFunctions(challenge.ScoreFormula, functions)
-
-	actualOrder := 1
-	for _, flag := range solvedFlags {
-		if flag.State == 2 && challenge.Game.AutoBan {
-			continue
-		}
-
-		parameters["order"] = actualOrder
-
-		result, _ := expression.Evaluate(parameters)
-		score := math.Round(result.(float64))
-		flag.Score = int(score)
-		s.UpdateFlag(flag)
-		actualOrder++
-	}
-}
-
 func SubmitFlag(c echo.Context) error {
 	ctx := c.(*context.CustomContext)
 
@@ -245,7 +224,7 @@ func SubmitFlag(c echo.Context) error {
 
 	team := challenge.Game.GetTeamByUser(ctx.Store, user)
 
-	if team == nil {
+	if team == nil || !team.CanPlay(challenge.Game) {
 		return Failed(&c, "Failed to submit the flag")
 	}
 
@@ -260,7 +239,35 @@ func SubmitFlag(c echo.Context) error {
 		return Failed(&c, "Failed to submit the flag")
 	}
 
-	if !challenge.DynamicFlag {
+	if !challengeProgress(ctx, challenge.Game, user).Unlocked(challenge) {
+		return Failed(&c, "Challenge is locked")
+	}
+
+	if challenge.IsSolvedBy(team, ctx.Store) {
+		return Failed(&c, "Flag has already been solved")
+	}
+
+	subFlags, err := ctx.Store.GetSubFlagsByChallenge(challenge)
+	if err != nil {
+		return Failed(&c, "Failed to submit the flag")
+	}
+	if len(subFlags) > 0 {
+		return submitSubFlag(c, challenge, team, user, subFlags, payload.Flag)
+	}
+
+	// the flag the team should submit, derived again for the stateless flags
+	expected := ""
+	if challenge.StatelessFlag {
+		secret, err := ctx.Store.GetFlagSecret(challenge)
+		if err != nil {
+			return Failed(&c, "Failed to submit the flag")
+		}
+		expected = statelessFlag(challenge.Game, challenge, secret, team)
+
+		if _, err := statelessFlagRecord(ctx.Store, challenge, team, expected); err != nil {
+			return Failed(&c, "Failed to submit the flag")
+		}
+	} else if !challenge.DynamicFlag {
 		// static flag case, create a flag first
 		// Create a new flag object here
 		f := store.Flag{
@@ -291,9 +298,11 @@ func SubmitFlag(c echo.Context) error {
 	if !ok {
 		storedFlag.State = store.FlagCheated
 		storedFlag.SolvedAt = time.Now().UnixMilli()
+		storedFlag.Submitter = user
 		ctx.Store.UpdateFlag(storedFlag)
 
 		if challenge.Game.AutoBan {
+			ctx.Scoreboard.Update(challenge.Game, storedFlag)
 			return Failed(&c, "Cheat detected")
 		}
 		// don't return if auto-ban is disabled
@@ -301,13 +310,18 @@ func SubmitFlag(c echo.Context) error {
 		// let admins decide whether to ban the team later
 	}
 
-	if storedFlag.Flag == payload.Flag {
+	if expected == "" {
+		expected = storedFlag.Flag
+	}
+
+	if challenge.FlagMatch.Match(expected, payload.Flag) {
 		if storedFlag.State == store.FlagUnsolved {
 			// we'll not update the flag state if it was cheated
 			storedFlag.State = store.FlagSolved
 		}
 
 		storedFlag.SolvedAt = time.Now().UnixMilli()
+		storedFlag.Submitter = user
 
 		ctx.Store.UpdateFlag(storedFlag)
 	} else {
@@ -315,7 +329,160 @@ func SubmitFlag(c echo.Context) error {
 		return Failed(&c, "Flag is incorrect")
 	}
 
-	go updateScore(ctx.Store, challenge)
+	s, engine := ctx.Store, ctx.Scoreboard
+	go func() {
+		order := engine.Update(challenge.Game, storedFlag)
+		createSolvedEvent(s, challenge.Game, challenge, team, order)
+	}()
 
 	return OK(&c)
 }
+
+// renderFlag fills the flag template and wraps it with the flag prefix of the game
+func renderFlag(game *store.Game, format string, params util.FlagParams) string {
+	return fmt.Sprintf("%s{%s}", game.FlagPrefix, util.RenderFlag(format, params))
+}
+
+// statelessFlag derives the stateless flag of the team from the flag secret of the challenge
+func statelessFlag(game *store.Game, challenge *store.Challenge, secret string, team *store.Team) string {
+	return renderFlag(game, challenge.FlagFormat, util.FlagParams{
+		Seed: team.UUID + challenge.UUID,
+		Key:  secret,
+	})
+}
+
+// statelessFlagRecord returns the flag record of the team for a stateless flag, which keeps the state
+// of the flag only, the record is created when the team submits a flag for the first time
+func statelessFlagRecord(s *store.Store, challenge *store.Challenge, team *store.Team, flag string) (*store.Flag, error) {
+	if record, err := s.GetFlagByChallengeAndTeam(challenge, team); err == nil {
+		return record, nil
+	}
+
+	record := &store.Flag{
+		Challenge: challenge,
+		Team:      team,
+		Flag:      flag,
+		State:     store.FlagUnsolved,
+	}
+	return record, s.CreateFlag(record)
+}
+
+// sharedFlag returns the flag record of the submitted dynamic flag, nil if it belongs to no team.
+// The owner of a stateless flag is found by deriving the flags of the teams in the game
+func sharedFlag(s *store.Store, challenge *store.Challenge, flag string) (*store.Flag, error) {
+	if !challenge.StatelessFlag {
+		record, err := s.GetFlagByChallenge(flag, challenge)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return record, err
+	}
+
+	secret, err := s.GetFlagSecret(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, team := range challenge.Game.GetTeams(s) {
+		owned := statelessFlag(challenge.Game, challenge, secret, team)
+		if challenge.FlagMatch.Match(owned, flag) {
+			return statelessFlagRecord(s, challenge, team, owned)
+		}
+	}
+	return nil, nil
+}
+
+// teamFlagPlaceholder is replaced by the flag of the team in the description, see store.TeamFlagDescription
+const teamFlagPlaceholder = "{{flag}}"
+
+// teamFlagLocks are striped by the challenge and the team, so that the members opening
+// the challenge at the same time get the same flag without blocking most of the other teams,
+// and the last sub flags found at the same time solve the challenge once
+var teamFlagLocks [64]sync.Mutex
+
+func teamFlagLock(challenge *store.Challenge, team *store.Team) *sync.Mutex {
+	h := uint64(challenge.ID)*0x9e3779b97f4a7c15 ^ uint64(team.ID)
+	return &teamFlagLocks[h%uint64(len(teamFlagLocks))]
+}
+
+// teamFlag returns the flag of the team for a containerless challenge with dynamic flags,
+// the flag is generated and recorded when the team opens the challenge for the first time
+func teamFlag(s *store.Store, game *store.Game, challenge *store.Challenge, team *store.Team) (string, error) {
+	secret, err := s.GetFlagSecret(challenge)
+	if err != nil {
+		return "", err
+	}
+
+	if challenge.StatelessFlag {
+		return statelessFlag(game, challenge, secret, team), nil
+	}
+
+	lock := teamFlagLock(challenge, team)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if record, err := s.GetFlagByChallengeAndTeam(challenge, team); err == nil {
+		return record.Flag, nil
+	}
+
+	flag := renderFlag(game, challenge.FlagFormat, util.FlagParams{
+		Seed: team.UUID + challenge.UUID,
+		Team: team.Name,
+		Key:  secret,
+		Time: time.Now(),
+	})
+	record := &store.Flag{
+		Challenge: challenge,
+		Team:      team,
+		Flag:      flag,
+		State:     store.FlagUnsolved,
+	}
+	return flag, s.CreateFlag(record)
+}
+
+// withTeamFlag fills the flag of the team into the description of the challenge
+// if the flag is delivered by the description
+func withTeamFlag(s *store.Store, game *store.Game, challenge *store.Challenge, team *store.Team) {
+	if challenge.TeamFlag != store.TeamFlagDescription || team == nil || !team.CanPlay(game) ||
+		!strings.Contains(challenge.Description, teamFlagPlaceholder) {
+		return
+	}
+
+	flag, err := teamFlag(s, game, challenge, team)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to generate the team flag: %s", err.Error()))
+		return
+	}
+	challenge.Description = strings.ReplaceAll(challenge.Description, teamFlagPlaceholder, flag)
+}
+
+// PreviewFlag validates the flag template and renders a sample flag with a random secret
+func PreviewFlag(c echo.Context) error {
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	var payload PreviewFlagPayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	if err := util.ValidateFlagTemplate(payload.FlagFormat); err != nil {
+		return Failed(&c, "Invalid flag format: "+err.Error())
+	}
+
+	team := payload.Team
+	if team == "" {
+		team = "team"
+	}
+
+	return OKWithData(&c, map[string]interface{}{
+		"flag": renderFlag(game, payload.FlagFormat, util.FlagParams{
+			Seed: util.UUID(),
+			Team: team,
+			Key:  util.RandomHex(32),
+			Time: time.Now(),
+		}),
+	})
+}
@@ -15,8 +15,14 @@
 package v1
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/labstack/echo/v4"
 	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/plugins/importer"
 	"rina.icu/hoshino/server/context"
 	"rina.icu/hoshino/store"
 )
@@ -24,13 +30,50 @@ import (
 type CreateGamePayload struct {
 	Name               string `json:"name" validate:"required"`
 	Description        string `json:"description" validate:"required"`
-	Visibility         bool   `json:"visibility" validate:"required"`
+	Visibility         *bool  `json:"visibility"`
 	FlagPrefix         string `json:"flag_prefix" validate:"required"`
 	StartTime          int64  `json:"start_time" validate:"required"`
 	EndTime            int64  `json:"end_time" validate:"required"`
 	MaxTeamSize        int    `json:"max_team_size" validate:"required"`
 	EnableChangeMember bool   `json:"enable_change_member" validate:"required"`
 	AutoBan            bool   `json:"auto_ban" validate:"required"`
+	FreezeTime         int64  `json:"freeze_time"`
+
+	RequireApproval bool     `json:"require_approval"`
+	Questions       []string `json:"questions"`
+	Rules           string   `json:"rules"`
+
+	WriteupDeadline int64 `json:"writeup_deadline"`
+	RequireWriteup  bool  `json:"require_writeup"`
+}
+
+type UpdateGamePayload struct {
+	Name               *string `json:"name"`
+	Description        *string `json:"description"`
+	Status             *int    `json:"status"`
+	Visibility         *bool   `json:"visibility"`
+	FlagPrefix         *string `json:"flag_prefix"`
+	StartTime          *int64  `json:"start_time"`
+	EndTime            *int64  `json:"end_time"`
+	MaxTeamSize        *int    `json:"max_team_size"`
+	EnableChangeMember *bool   `json:"enable_change_member"`
+	AutoBan            *bool   `json:"auto_ban"`
+	FreezeTime         *int64  `json:"freeze_time"`
+
+	RequireApproval *bool     `json:"require_approval"`
+	Questions       *[]string `json:"questions"`
+	Rules           *string   `json:"rules"`
+
+	WriteupDeadline *int64 `json:"writeup_deadline"`
+	RequireWriteup  *bool  `json:"require_writeup"`
+}
+
+type GameManagerPayload struct {
+	Username string `json:"username" validate:"required"`
+}
+
+type CloneGamePayload struct {
+	Name string `json:"name" validate:"required"`
 }
 
 func GetGame(c echo.Context) error {
@@ -43,6 +86,26 @@ func GetGame(c echo.Context) error {
 		return Failed(&c, "Unable to fetch game")
 	}
 
+	// the players see the visible challenges, the locked ones without their bodies,
+	// and none until their team is approved
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		challenges := []*store.Challenge{}
+		if team := game.GetTeamByUser(ctx.Store, user); team == nil || !team.CanPlay(game) {
+			game.Challenges = challenges
+			return OKWithData(&c, game)
+		}
+
+		progress := challengeProgress(ctx, game, user)
+		for _, challenge := range game.GetChallenges(false) {
+			if progress.Unlocked(challenge) {
+				challenges = append(challenges, challenge)
+			} else if !challenge.HideLocked {
+				challenges = append(challenges, lockedChallenge(challenge))
+			}
+		}
+		game.Challenges = challenges
+	}
+
 	return OKWithData(&c, game)
 }
 
@@ -69,6 +132,20 @@ func GetGames(c echo.Context) error {
 	}
 }
 
+// validateGame checks the status and the times of the game, the freeze time is 0 if not frozen
+func validateGame(game *store.Game) error {
+	if game.Status != store.GameStatusInactive && game.Status != store.GameStatusActive {
+		return errors.New("unknown status")
+	}
+	if game.EndTime < game.StartTime {
+		return errors.New("the game ends before it starts")
+	}
+	if game.FreezeTime != 0 && (game.FreezeTime < game.StartTime || game.FreezeTime > game.EndTime) {
+		return errors.New("the freeze time is out of the game")
+	}
+	return nil
+}
+
 func CreateGame(c echo.Context) error {
 	ctx := c.(*context.CustomContext)
 
@@ -88,19 +165,327 @@ func CreateGame(c echo.Context) error {
 		Name:               payload.Name,
 		Description:        payload.Description,
 		FlagPrefix:         payload.FlagPrefix,
-		Visibility:         payload.Visibility,
+		Visibility:         payload.Visibility == nil || *payload.Visibility,
 		StartTime:          payload.StartTime,
 		EndTime:            payload.EndTime,
 		MaxTeamSize:        payload.MaxTeamSize,
 		EnableChangeMember: payload.EnableChangeMember,
 		AutoBan:            payload.AutoBan,
+		FreezeTime:         payload.FreezeTime,
+		RequireApproval:    payload.RequireApproval,
+		Questions:          payload.Questions,
+		Rules:              payload.Rules,
+		WriteupDeadline:    payload.WriteupDeadline,
+		RequireWriteup:     payload.RequireWriteup,
 		Creator:            user,
 		Managers:           []*store.User{user},
 	}
 
+	if err := validateGame(&game); err != nil {
+		return Failed(&c, "Invalid game: "+err.Error())
+	}
+
 	if err := ctx.Store.CreateGame(&game); err != nil {
 		return Failed(&c, "Unable to create game")
 	}
 
 	return OK(&c)
 }
+
+func UpdateGame(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	var payload UpdateGamePayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	if payload.Name != nil {
+		game.Name = *payload.Name
+	}
+	if payload.Description != nil {
+		game.Description = *payload.Description
+	}
+	if payload.Status != nil {
+		game.Status = store.GameStatus(*payload.Status)
+	}
+	if payload.Visibility != nil {
+		game.Visibility = *payload.Visibility
+	}
+	if payload.FlagPrefix != nil {
+		game.FlagPrefix = *payload.FlagPrefix
+	}
+	if payload.StartTime != nil {
+		game.StartTime = *payload.StartTime
+	}
+	if payload.EndTime != nil {
+		game.EndTime = *payload.EndTime
+	}
+	if payload.MaxTeamSize != nil {
+		game.MaxTeamSize = *payload.MaxTeamSize
+	}
+	if payload.EnableChangeMember != nil {
+		game.EnableChangeMember = *payload.EnableChangeMember
+	}
+	if payload.AutoBan != nil {
+		game.AutoBan = *payload.AutoBan
+	}
+	if payload.FreezeTime != nil && *payload.FreezeTime != game.FreezeTime {
+		// freeze again with the new time
+		game.FreezeTime = *payload.FreezeTime
+		game.Revealed = false
+	}
+	if payload.RequireApproval != nil {
+		game.RequireApproval = *payload.RequireApproval
+	}
+	if payload.Questions != nil {
+		game.Questions = *payload.Questions
+	}
+	if payload.Rules != nil {
+		game.Rules = *payload.Rules
+	}
+	if payload.WriteupDeadline != nil {
+		game.WriteupDeadline = *payload.WriteupDeadline
+	}
+	if payload.RequireWriteup != nil {
+		game.RequireWriteup = *payload.RequireWriteup
+	}
+
+	if err := validateGame(game); err != nil {
+		return Failed(&c, "Invalid game: "+err.Error())
+	}
+
+	if err := ctx.Store.UpdateGame(game); err != nil {
+		return Failed(&c, "Unable to update game")
+	}
+
+	return OK(&c)
+}
+
+func DeleteGame(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	if !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if err := ctx.Store.DeleteGame(game); err != nil {
+		return Failed(&c, "Unable to delete game")
+	}
+
+	return OK(&c)
+}
+
+func AddGameManager(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	var payload GameManagerPayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	manager, err := ctx.Store.GetUserByUsername(payload.Username)
+	if err != nil {
+		return Failed(&c, "Unable to fetch user")
+	}
+
+	if game.IsManager(manager) {
+		return Failed(&c, "User is already a manager")
+	}
+
+	if err := ctx.Store.AddGameManager(game, manager); err != nil {
+		return Failed(&c, "Unable to add manager")
+	}
+
+	return OK(&c)
+}
+
+func RemoveGameManager(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	manager, err := ctx.Store.GetUserByUUID(c.Param("user_uuid"))
+	if err != nil || !game.IsManager(manager) {
+		return Failed(&c, "User is not a manager")
+	}
+
+	if manager.ID == game.CreatorID {
+		return Failed(&c, "Unable to remove the creator")
+	}
+
+	if err := ctx.Store.RemoveGameManager(game, manager); err != nil {
+		return Failed(&c, "Unable to remove manager")
+	}
+
+	return OK(&c)
+}
+
+func CloneGame(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	if !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	var payload CloneGamePayload
+	if err := c.Bind(&payload); err != nil || payload.Name == "" {
+		return Failed(&c, "Invalid payload")
+	}
+
+	// the cloned game is a hidden draft until the managers publish it
+	clone := &store.Game{
+		UUID:               util.UUID(),
+		Name:               payload.Name,
+		Description:        game.Description,
+		Status:             store.GameStatusInactive,
+		Visibility:         false,
+		MaxTeamSize:        game.MaxTeamSize,
+		EnableChangeMember: game.EnableChangeMember,
+		FlagPrefix:         game.FlagPrefix,
+		AutoBan:            game.AutoBan,
+		RequireApproval:    game.RequireApproval,
+		Questions:          game.Questions,
+		Rules:              game.Rules,
+		RequireWriteup:     game.RequireWriteup,
+		Creator:            user,
+		Managers:           []*store.User{user},
+	}
+
+	if err := ctx.Store.CloneGame(game, clone); err != nil {
+		return Failed(&c, "Unable to clone game")
+	}
+
+	return OKWithData(&c, map[string]any{"uuid": clone.UUID})
+}
+
+// ExportGame downloads the game with its challenges, hints, images and attachment files as an archive,
+// the format is zip by default or tar.gz
+func ExportGame(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	if !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "zip"
+	}
+
+	// the archive is built into a temporary file first to report the errors
+	f, err := os.CreateTemp("", "hoshino-export-")
+	if err != nil {
+		return Failed(&c, "Unable to export game")
+	}
+	defer os.Remove(f.Name())
+
+	err = importer.ExportGame(ctx.Store, game, f, format)
+	f.Close()
+	if err != nil {
+		return Failed(&c, "Unable to export game: "+err.Error())
+	}
+
+	return c.Attachment(f.Name(), fmt.Sprintf("%s.%s", game.Name, format))
+}
+
+// ImportGame creates a hidden game from an uploaded game archive, the requesting user
+// becomes a manager and replaces the users not found in this instance
+func ImportGame(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	if !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	dir, err := extractUpload(c)
+	if err != nil {
+		return Failed(&c, "Invalid archive: "+err.Error())
+	}
+	defer os.RemoveAll(filepath.Dir(dir))
+
+	result, err := importer.ImportGame(ctx.Store, dir, ctx.Config.DataDir, user, c.FormValue("name"))
+	if err != nil {
+		return Failed(&c, "Failed to import game: "+err.Error())
+	}
+
+	return OKWithData(&c, result)
+}
+
+// ImportCTFdGame creates a hidden game from an uploaded CTFd export, the records which could
+// not be mapped are reported
+func ImportCTFdGame(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	if !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	dir, err := extractUpload(c)
+	if err != nil {
+		return Failed(&c, "Invalid archive: "+err.Error())
+	}
+	defer os.RemoveAll(filepath.Dir(dir))
+
+	result, err := importer.ImportCTFd(ctx.Store, dir, ctx.Config.DataDir, user, c.FormValue("name"))
+	if err != nil {
+		return Failed(&c, "Failed to import game: "+err.Error())
+	}
+
+	// the imported solves are scored by the scoreboard
+	if game, err := ctx.Store.GetGameByUUID(result.UUID); err == nil {
+		for _, challenge := range game.Challenges {
+			ctx.Scoreboard.Rescore(game, challenge)
+		}
+	}
+
+	return OKWithData(&c, result)
+}
@@ -0,0 +1,319 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/server/context"
+	"rina.icu/hoshino/store"
+)
+
+type HintPayload struct {
+	// Markdown supported
+	Content string `json:"content"`
+
+	// score deducted when unlocking, free if 0
+	Cost *int `json:"cost"`
+
+	// hidden from the players before the release time, 0 for released
+	ReleaseTime *int64 `json:"release_time"`
+}
+
+func (p *HintPayload) valid() bool {
+	return (p.Cost == nil || *p.Cost >= 0) && (p.ReleaseTime == nil || *p.ReleaseTime >= 0)
+}
+
+// apply updates the hint with the non-empty fields of the payload
+func (p *HintPayload) apply(hint *store.Hint) {
+	if p.Content != "" {
+		hint.Content = p.Content
+	}
+	if p.Cost != nil {
+		hint.Cost = *p.Cost
+	}
+	if p.ReleaseTime != nil {
+		hint.ReleaseTime = *p.ReleaseTime
+	}
+}
+
+// newHints creates the hints of a new challenge from the payloads
+func newHints(payloads []HintPayload) ([]*store.Hint, bool) {
+	hints := make([]*store.Hint, 0, len(payloads))
+	for _, payload := range payloads {
+		if payload.Content == "" || !payload.valid() {
+			return nil, false
+		}
+
+		hint := &store.Hint{UUID: util.UUID()}
+		payload.apply(hint)
+		hints = append(hints, hint)
+	}
+	return hints, true
+}
+
+// visibleHints returns the hints visible to the user, managers see all the hints,
+// players see the released hints whose content is stripped until unlocked by their team
+func visibleHints(ctx *context.CustomContext, game *store.Game, user *store.User, hints []*store.Hint) []*store.Hint {
+	manager := game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator)
+
+	unlocked := make(map[uint]bool)
+	if !manager {
+		if team := game.GetTeamByUser(ctx.Store, user); team != nil {
+			ids, err := ctx.Store.GetUnlockedHintIDs(team)
+			if err != nil {
+				slog.Error(fmt.Sprintf("Failed to get unlocked hints: %s", err.Error()))
+			}
+			for _, id := range ids {
+				unlocked[id] = true
+			}
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	visible := make([]*store.Hint, 0, len(hints))
+	for _, hint := range hints {
+		h := *hint
+		switch {
+		case manager:
+			h.Unlocked = true
+		case !hint.Released(now):
+			continue
+		case hint.Cost == 0 || unlocked[hint.ID]:
+			h.Unlocked = true
+		default:
+			h.Content = ""
+		}
+		visible = append(visible, &h)
+	}
+	return visible
+}
+
+func getChallengeHint(c echo.Context, challenge *store.Challenge) (*store.Hint, error) {
+	ctx := c.(*context.CustomContext)
+
+	hint, err := ctx.Store.GetHintByUUID(c.Param("hint_uuid"))
+	if err != nil || hint.ChallengeID != challenge.ID {
+		return nil, Failed(&c, "Unable to fetch hint")
+	}
+	return hint, nil
+}
+
+func GetHints(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+	user, _ := GetUserFromToken(&c)
+
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.Visibility && !user.HasPrivilege(store.UserPrivilegeAdministrator) && !game.IsManager(user) {
+		return PermissionDenied(&c)
+	}
+
+	if !canPlay(ctx.Store, game, user) {
+		return PermissionDenied(&c)
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	if !challengeProgress(ctx, game, user).Unlocked(challenge) {
+		return PermissionDenied(&c)
+	}
+
+	hints, err := ctx.Store.GetHintsByChallenge(challenge)
+	if err != nil {
+		return Failed(&c, "Unable to fetch hints")
+	}
+
+	return OKWithData(&c, visibleHints(ctx, game, user, hints))
+}
+
+func CreateHint(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	var payload HintPayload
+	if err := c.Bind(&payload); err != nil || payload.Content == "" || !payload.valid() {
+		return Failed(&c, "Invalid payload")
+	}
+
+	hint := &store.Hint{UUID: util.UUID(), ChallengeID: challenge.ID}
+	payload.apply(hint)
+
+	if err := ctx.Store.CreateHint(hint); err != nil {
+		return Failed(&c, "Unable to create hint")
+	}
+
+	return OKWithData(&c, map[string]any{"uuid": hint.UUID})
+}
+
+// UpdateHint edits the hint, the teams which have unlocked it keep paying the former cost
+func UpdateHint(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	hint, err := getChallengeHint(c, challenge)
+	if hint == nil {
+		return err
+	}
+
+	var payload HintPayload
+	if err := c.Bind(&payload); err != nil || !payload.valid() {
+		return Failed(&c, "Invalid payload")
+	}
+
+	payload.apply(hint)
+	if err := ctx.Store.UpdateHint(hint); err != nil {
+		return Failed(&c, "Unable to update hint")
+	}
+
+	return OK(&c)
+}
+
+// DeleteHint deletes the hint and refunds the teams which have unlocked it
+func DeleteHint(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	hint, err := getChallengeHint(c, challenge)
+	if hint == nil {
+		return err
+	}
+
+	if err := ctx.Store.DeleteHint(hint); err != nil {
+		return Failed(&c, "Unable to delete hint")
+	}
+
+	if hint.Cost > 0 {
+		if err := ctx.Scoreboard.Reload(game); err != nil {
+			slog.Error(fmt.Sprintf("Failed to reload the scoreboard of game %s: %s", game.UUID, err.Error()))
+		}
+	}
+
+	return OK(&c)
+}
+
+// UnlockHint unlocks the hint for the team of the user, the cost is deducted from the team's score
+// teamHintLocks are striped by the team, the hints of a team are unlocked one at a time
+var teamHintLocks [64]sync.Mutex
+
+func teamHintLock(team *store.Team) *sync.Mutex {
+	return &teamHintLocks[team.ID%uint(len(teamHintLocks))]
+}
+
+func UnlockHint(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+	user, _ := GetUserFromToken(&c)
+
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	team := game.GetTeamByUser(ctx.Store, user)
+	if team == nil || !team.CanPlay(game) {
+		return Failed(&c, "Unable to unlock the hint")
+	}
+
+	if game.Status == store.GameStatusInactive {
+		return Failed(&c, "Unable to unlock the hint")
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	if challenge.State != store.ChallengeStateVisible || !challengeProgress(ctx, game, user).Unlocked(challenge) {
+		return Failed(&c, "Challenge is locked")
+	}
+
+	hint, err := getChallengeHint(c, challenge)
+	if hint == nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	if !hint.Released(now) {
+		return Failed(&c, "Unable to fetch hint")
+	}
+
+	if hint.Cost > 0 {
+		// the score is checked and deducted at once, so that the concurrent unlocks can't overspend it
+		lock := teamHintLock(team)
+		lock.Lock()
+		defer lock.Unlock()
+
+		score, _ := ctx.Scoreboard.Rank(game, team, false)
+		if score < hint.Cost {
+			return Failed(&c, "Not enough score to unlock the hint")
+		}
+
+		unlock := &store.HintUnlock{
+			HintID:     hint.ID,
+			Team:       team,
+			TeamID:     team.ID,
+			UserID:     user.ID,
+			Cost:       hint.Cost,
+			UnlockedAt: now,
+		}
+
+		if err := ctx.Store.CreateHintUnlock(unlock); err != nil {
+			return Failed(&c, "Hint has already been unlocked")
+		}
+
+		ctx.Scoreboard.Unlock(game, unlock)
+	}
+
+	hint.Unlocked = true
+	return OKWithData(&c, hint)
+}
@@ -0,0 +1,127 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/cast"
+	"rina.icu/hoshino/plugins/importer"
+	"rina.icu/hoshino/server/context"
+)
+
+// size limit of an uploaded archive
+const importSizeLimit = 200 << 20
+
+// extractUpload saves the uploaded archive and extracts it into a temporary directory,
+// the directory should be removed by the caller
+func extractUpload(c echo.Context) (string, error) {
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, importSizeLimit)
+	file, err := c.FormFile("file")
+	if err != nil {
+		return "", err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dir, err := os.MkdirTemp("", "hoshino-import-")
+	if err != nil {
+		return "", err
+	}
+
+	// keep the extension to detect the format
+	archive := filepath.Join(dir, "upload"+archiveExt(file.Filename))
+	dst, err := os.Create(archive)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	_, err = io.Copy(dst, src)
+	dst.Close()
+	if err == nil {
+		err = importer.Extract(archive, filepath.Join(dir, "content"))
+	}
+	os.Remove(archive)
+
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return filepath.Join(dir, "content"), nil
+}
+
+func archiveExt(name string) string {
+	ext := filepath.Ext(name)
+	if ext == ".gz" {
+		return filepath.Ext(strings.TrimSuffix(name, ext)) + ext
+	}
+	return ext
+}
+
+// ImportChallenges creates or updates the challenges of the game from an uploaded archive
+// of challenge.yml directories, the changes are only reported if dry_run is set
+func ImportChallenges(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, user, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	dir, err := extractUpload(c)
+	if err != nil {
+		return Failed(&c, "Invalid archive: "+err.Error())
+	}
+	defer os.RemoveAll(filepath.Dir(dir))
+
+	i := importer.NewImporter(ctx.Store, game, ctx.Config.DataDir)
+	i.DryRun = cast.ToBool(c.FormValue("dry_run"))
+	i.User = user
+
+	results, err := i.Import(dir)
+
+	reload := false
+	for _, result := range results {
+		if result.Rescore {
+			if challenge, err := ctx.Store.GetChallengeByUUID(result.UUID); err == nil {
+				ctx.Scoreboard.Rescore(game, challenge)
+			}
+		}
+		reload = reload || result.Refund
+	}
+	if reload {
+		if err := ctx.Scoreboard.Reload(game); err != nil {
+			slog.Error(fmt.Sprintf("Failed to reload the scoreboard of game %s: %s", game.UUID, err.Error()))
+		}
+	}
+
+	if err != nil {
+		return Failed(&c, "Failed to import challenges: "+err.Error())
+	}
+
+	return OKWithData(&c, results)
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+	"rina.icu/hoshino/server/context"
+)
+
+func GetNotifications(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	notifications, err := ctx.Store.GetNotificationsByUser(user, c.QueryParam("unread") == "true")
+	if err != nil {
+		return Failed(&c, "Unable to fetch notifications")
+	}
+
+	return OKWithData(&c, notifications)
+}
+
+func MarkNotificationRead(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	notification, err := ctx.Store.GetNotificationByUUID(c.Param("notification_uuid"))
+	if err != nil || notification.UserID != user.ID {
+		return Failed(&c, "Unable to fetch notification")
+	}
+
+	notification.Read = true
+	if err := ctx.Store.UpdateNotification(notification); err != nil {
+		return Failed(&c, "Unable to update notification")
+	}
+
+	return OK(&c)
+}
+
+func MarkAllNotificationsRead(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	if err := ctx.Store.MarkAllNotificationsRead(user); err != nil {
+		return Failed(&c, "Unable to update notifications")
+	}
+
+	return OK(&c)
+}
+
+func DeleteNotification(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	notification, err := ctx.Store.GetNotificationByUUID(c.Param("notification_uuid"))
+	if err != nil || notification.UserID != user.ID {
+		return Failed(&c, "Unable to fetch notification")
+	}
+
+	if err := ctx.Store.DeleteNotification(notification); err != nil {
+		return Failed(&c, "Unable to delete notification")
+	}
+
+	return OK(&c)
+}
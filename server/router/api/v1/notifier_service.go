@@ -0,0 +1,211 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"github.com/labstack/echo/v4"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/plugins/notifier"
+	"rina.icu/hoshino/server/context"
+	"rina.icu/hoshino/store"
+)
+
+type NotifierPayload struct {
+	Name     string `json:"name"`
+	Platform string `json:"platform"`
+
+	// webhook URL of Discord, Slack and Lark
+	URL string `json:"url"`
+
+	// bot token and chat ID of Telegram
+	Token  string `json:"token"`
+	ChatID string `json:"chat_id"`
+
+	// signing secret of Lark
+	Secret string `json:"secret"`
+
+	// names of the events to post, empty for all
+	Events []string `json:"events"`
+
+	// message templates keyed by the event name
+	Templates map[string]string `json:"templates"`
+
+	Enabled *bool `json:"enabled"`
+}
+
+func getGameNotifier(c echo.Context, game *store.Game) (*store.Notifier, error) {
+	ctx := c.(*context.CustomContext)
+
+	n, err := ctx.Store.GetNotifierByUUID(c.Param("notifier_uuid"))
+	if err != nil || n.GameID != game.ID {
+		return nil, Failed(&c, "Unable to fetch notifier")
+	}
+	return n, nil
+}
+
+func GetNotifiers(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	notifiers, err := ctx.Store.GetNotifiersByGame(game)
+	if err != nil {
+		return Failed(&c, "Unable to fetch notifiers")
+	}
+
+	return OKWithData(&c, map[string]any{
+		"notifiers": notifiers,
+		"events":    notifier.Events(),
+		"templates": notifier.DefaultTemplates,
+	})
+}
+
+func CreateNotifier(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	var payload NotifierPayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	n := &store.Notifier{
+		UUID:      util.UUID(),
+		Game:      game,
+		Name:      payload.Name,
+		Platform:  store.NotifierPlatform(payload.Platform),
+		URL:       payload.URL,
+		Token:     payload.Token,
+		ChatID:    payload.ChatID,
+		Secret:    payload.Secret,
+		Events:    payload.Events,
+		Templates: payload.Templates,
+		Enabled:   payload.Enabled == nil || *payload.Enabled,
+	}
+
+	if err := notifier.Validate(n); err != nil {
+		return Failed(&c, "Invalid notifier: "+err.Error())
+	}
+
+	if err := ctx.Store.CreateNotifier(n); err != nil {
+		return Failed(&c, "Unable to create notifier")
+	}
+
+	return OKWithData(&c, map[string]any{"uuid": n.UUID})
+}
+
+func UpdateNotifier(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	n, err := getGameNotifier(c, game)
+	if n == nil {
+		return err
+	}
+
+	var payload NotifierPayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	if payload.Name != "" {
+		n.Name = payload.Name
+	}
+	if payload.Platform != "" {
+		n.Platform = store.NotifierPlatform(payload.Platform)
+	}
+	if payload.URL != "" {
+		n.URL = payload.URL
+	}
+	if payload.Token != "" {
+		n.Token = payload.Token
+	}
+	if payload.ChatID != "" {
+		n.ChatID = payload.ChatID
+	}
+	if payload.Secret != "" {
+		n.Secret = payload.Secret
+	}
+	if payload.Events != nil {
+		n.Events = payload.Events
+	}
+	if payload.Templates != nil {
+		n.Templates = payload.Templates
+	}
+	if payload.Enabled != nil {
+		n.Enabled = *payload.Enabled
+	}
+
+	if err := notifier.Validate(n); err != nil {
+		return Failed(&c, "Invalid notifier: "+err.Error())
+	}
+
+	if err := ctx.Store.UpdateNotifier(n); err != nil {
+		return Failed(&c, "Unable to update notifier")
+	}
+
+	return OK(&c)
+}
+
+func DeleteNotifier(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	n, err := getGameNotifier(c, game)
+	if n == nil {
+		return err
+	}
+
+	if err := ctx.Store.DeleteNotifier(n); err != nil {
+		return Failed(&c, "Unable to delete notifier")
+	}
+
+	return OK(&c)
+}
+
+func TestNotifier(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	n, err := getGameNotifier(c, game)
+	if n == nil {
+		return err
+	}
+
+	if err := ctx.Notifier.SendTest(n, game); err != nil {
+		return Failed(&c, "Unable to send test message: "+err.Error())
+	}
+
+	return OK(&c)
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/cast"
+	"rina.icu/hoshino/server/context"
+	"rina.icu/hoshino/store"
+)
+
+// scoreboardFrozen reports whether the user should see the frozen scoreboard,
+// the managers always see the live one
+func scoreboardFrozen(game *store.Game, user *store.User) bool {
+	return game.Frozen() && !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator)
+}
+
+func GetScoreboard(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil || !(game.Visibility || user.HasPrivilege(store.UserPrivilegeAdministrator) || game.IsManager(user)) {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	page := max(cast.ToInt(c.QueryParam("page")), 1)
+	size := cast.ToInt(c.QueryParam("size"))
+	if size <= 0 || size > 200 {
+		size = 50
+	}
+	top := cast.ToInt(c.QueryParam("top"))
+	if top <= 0 || top > 50 {
+		top = 10
+	}
+
+	frozen := scoreboardFrozen(game, user)
+	sb := ctx.Scoreboard.Scoreboard(game, frozen)
+
+	// the locked challenges hidden from the team are left out, as in the challenge list
+	progress := challengeProgress(ctx, game, user)
+	challenges := []map[string]any{}
+	for _, challenge := range game.GetChallenges(game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator)) {
+		if challenge.HideLocked && !progress.Unlocked(challenge) {
+			continue
+		}
+		challenges = append(challenges, map[string]any{
+			"uuid":     challenge.UUID,
+			"name":     challenge.Name,
+			"category": challenge.Category,
+		})
+	}
+
+	return OKWithData(&c, map[string]any{
+		"total":      len(sb.Standings),
+		"page":       page,
+		"size":       size,
+		"frozen":     frozen,
+		"challenges": challenges,
+		"standings":  sb.Page(page, size),
+		"timeline":   sb.Timeline(top),
+	})
+}
+
+func ExportScoreboard(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	sb := ctx.Scoreboard.Scoreboard(game, false)
+
+	switch c.QueryParam("format") {
+	case "", "ctftime":
+		return c.JSON(http.StatusOK, sb.CTFtime())
+	case "csv":
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", game.Name+".csv"))
+		c.Response().WriteHeader(http.StatusOK)
+		return sb.WriteCSV(c.Response())
+	default:
+		return Failed(&c, "Unsupported format")
+	}
+}
+
+func RevealScoreboard(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	if game.FreezeTime == 0 || game.Revealed {
+		return Failed(&c, "Scoreboard is not frozen")
+	}
+
+	flags, err := ctx.Store.GetSolvedFlagsByGame(game)
+	if err != nil {
+		return Failed(&c, "Unable to fetch solves")
+	}
+
+	// reveal from the lowest ranked team to the highest one,
+	// the hidden solves of each team are revealed in chronological order
+	standings := slices.Clone(ctx.Scoreboard.Scoreboard(game, true).Standings)
+	slices.Reverse(standings)
+
+	// only one of the concurrent or retried reveals announces the hidden solves
+	revealed, err := ctx.Store.RevealGame(game)
+	if err != nil {
+		return Failed(&c, "Unable to reveal scoreboard")
+	}
+	if !revealed {
+		return Failed(&c, "Scoreboard is not frozen")
+	}
+
+	reveals := []map[string]any{}
+	for _, standing := range standings {
+		for _, flag := range flags {
+			// cheated solves are not counted in the scoreboard
+			if flag.TeamID != standing.TeamID || flag.State != store.FlagSolved || flag.SolvedAt < game.FreezeTime {
+				continue
+			}
+
+			event := store.GameEvent{
+				Content:      fmt.Sprintf("Team `%s` solved `%s` after the scoreboard was frozen", flag.Team.Name, flag.Challenge.Name),
+				Game:         game,
+				Challenge:    flag.Challenge,
+				RelatedTeams: []*store.Team{flag.Team},
+				Visibility:   true,
+				Type:         store.GameEventTypeScoreboardRevealed,
+			}
+			ctx.Store.CreateGameEvent(&event)
+
+			reveals = append(reveals, map[string]any{
+				"event_id":  event.ID,
+				"team":      flag.Team.UUID,
+				"challenge": flag.Challenge.UUID,
+				"score":     flag.Score,
+				"solved_at": flag.SolvedAt,
+			})
+		}
+	}
+
+	// the solves hidden during the freeze are announced by the reveal events above,
+	// the events hidden by the managers before the freeze stay hidden
+	if err := ctx.Store.ShowGameEvents(game, store.GameEventTypeChallengeSolved, game.FreezeTime); err != nil {
+		return Failed(&c, "Unable to reveal solves")
+	}
+
+	return OKWithData(&c, reveals)
+}
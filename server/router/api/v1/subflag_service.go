@@ -0,0 +1,247 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/server/context"
+	"rina.icu/hoshino/store"
+)
+
+type SubFlagPayload struct {
+	// shown to the players
+	Name string `json:"name"`
+	Flag string `json:"flag"`
+
+	// how the submitted flags are compared, see store.FlagMatch
+	Match int `json:"match"`
+
+	// share of the challenge score in percent
+	Weight int `json:"weight"`
+}
+
+type SetSubFlagsPayload struct {
+	// the sub flags of the challenge, empty to make it a single flag challenge
+	SubFlags []SubFlagPayload `json:"sub_flags"`
+}
+
+// newSubFlags creates the sub flags from the payloads, the names and the flags should be unique
+// and the weights should add up to 100
+func newSubFlags(payloads []SubFlagPayload) ([]*store.SubFlag, error) {
+	flags := make([]*store.SubFlag, 0, len(payloads))
+	total := 0
+	for _, payload := range payloads {
+		if payload.Name == "" || payload.Flag == "" {
+			return nil, errors.New("name and flag are required")
+		}
+		if payload.Weight <= 0 {
+			return nil, fmt.Errorf("weight of %s should be positive", payload.Name)
+		}
+		if slices.ContainsFunc(flags, func(f *store.SubFlag) bool { return f.Name == payload.Name || f.Flag == payload.Flag }) {
+			return nil, fmt.Errorf("sub flag %s is duplicated", payload.Name)
+		}
+		if err := store.FlagMatch(payload.Match).Validate(payload.Flag); err != nil {
+			return nil, fmt.Errorf("flag of %s is invalid: %w", payload.Name, err)
+		}
+
+		total += payload.Weight
+		flags = append(flags, &store.SubFlag{
+			UUID:   util.UUID(),
+			Name:   payload.Name,
+			Flag:   payload.Flag,
+			Match:  store.FlagMatch(payload.Match),
+			Weight: payload.Weight,
+		})
+	}
+
+	if len(flags) > 0 && total != 100 {
+		return nil, fmt.Errorf("weights add up to %d instead of 100", total)
+	}
+	return flags, nil
+}
+
+// foundSubFlags returns the IDs of the sub flags found by the team of the user
+func foundSubFlags(ctx *context.CustomContext, game *store.Game, user *store.User) map[uint]bool {
+	found := make(map[uint]bool)
+
+	team := game.GetTeamByUser(ctx.Store, user)
+	if team == nil {
+		return found
+	}
+
+	ids, err := ctx.Store.GetFoundSubFlagIDs(team)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to get found sub flags: %s", err.Error()))
+	}
+	for _, id := range ids {
+		found[id] = true
+	}
+	return found
+}
+
+// withFound returns copies of the sub flags marked with whether they're found,
+// the flags and the matching modes are only kept for the managers
+func withFound(flags []*store.SubFlag, found map[uint]bool, manager bool) []*store.SubFlag {
+	result := make([]*store.SubFlag, 0, len(flags))
+	for _, flag := range flags {
+		f := *flag
+		f.Found = found[flag.ID]
+		if !manager {
+			f.Flag = ""
+			f.Match = 0
+		}
+		result = append(result, &f)
+	}
+	return result
+}
+
+// SetSubFlags replaces the sub flags of the challenge, the existing sub flags are matched
+// by the name and keep their solves
+func SetSubFlags(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	challenge, err := getGameChallenge(c, game)
+	if challenge == nil {
+		return err
+	}
+
+	var payload SetSubFlagsPayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	flags, err := newSubFlags(payload.SubFlags)
+	if err != nil {
+		return Failed(&c, "Invalid sub flags: "+err.Error())
+	}
+	if len(flags) > 0 && challenge.DynamicFlag {
+		return Failed(&c, "Sub flags can't be used with dynamic flags")
+	}
+
+	if err := ctx.Store.SetSubFlags(challenge, flags); err != nil {
+		return Failed(&c, "Unable to update sub flags")
+	}
+
+	// the weights of the found sub flags may have changed
+	if err := ctx.Scoreboard.Reload(game); err != nil {
+		slog.Error(fmt.Sprintf("Failed to reload the scoreboard of game %s: %s", game.UUID, err.Error()))
+	}
+
+	return OK(&c)
+}
+
+// submitSubFlag records the sub flag found by the team, the challenge is solved
+// when all of its sub flags are found
+func submitSubFlag(c echo.Context, challenge *store.Challenge, team *store.Team, user *store.User, flags []*store.SubFlag, submitted string) error {
+	ctx := c.(*context.CustomContext)
+
+	if ok, _ := anticheatCheck(&c, ctx.Store, submitted, team, challenge); !ok && challenge.Game.AutoBan {
+		return Failed(&c, "Cheat detected")
+	}
+
+	i := slices.IndexFunc(flags, func(f *store.SubFlag) bool {
+		return f.Match.Match(f.Flag, submitted)
+	})
+	if i < 0 {
+		return Failed(&c, "Flag is incorrect")
+	}
+	flag := flags[i]
+
+	solve := &store.SubFlagSolve{
+		SubFlagID:   flag.ID,
+		ChallengeID: challenge.ID,
+		Challenge:   challenge,
+		TeamID:      team.ID,
+		Team:        team,
+		SubmitterID: user.ID,
+		Weight:      flag.Weight,
+		SolvedAt:    time.Now().UnixMilli(),
+	}
+	if err := ctx.Store.CreateSubFlagSolve(solve); err != nil {
+		return Failed(&c, "Sub flag has already been found")
+	}
+	ctx.Scoreboard.SolvePart(challenge.Game, solve)
+
+	ids, err := ctx.Store.GetFoundSubFlagIDs(team)
+	if err != nil {
+		return Failed(&c, "Failed to submit the flag")
+	}
+
+	found := 0
+	for _, f := range flags {
+		if slices.Contains(ids, f.ID) {
+			found++
+		}
+	}
+
+	solved := found == len(flags)
+	if solved {
+		if err := solveSubFlags(ctx, challenge, team, user, submitted, solve.SolvedAt); err != nil {
+			return Failed(&c, "Failed to submit the flag")
+		}
+	}
+
+	return OKWithData(&c, map[string]any{
+		"sub_flag": flag.Name,
+		"found":    found,
+		"total":    len(flags),
+		"solved":   solved,
+	})
+}
+
+// solveSubFlags records the challenge as solved by the team once all of its sub flags are found,
+// the last sub flags found at the same time solve the challenge only once
+func solveSubFlags(ctx *context.CustomContext, challenge *store.Challenge, team *store.Team, user *store.User, submitted string, solvedAt int64) error {
+	lock := teamFlagLock(challenge, team)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if challenge.IsSolvedBy(team, ctx.Store) {
+		return nil
+	}
+
+	f := &store.Flag{
+		Challenge: challenge,
+		Team:      team,
+		Flag:      submitted,
+		State:     store.FlagSolved,
+		SolvedAt:  solvedAt,
+		Submitter: user,
+	}
+	if err := ctx.Store.CreateFlag(f); err != nil {
+		return err
+	}
+
+	s, engine := ctx.Store, ctx.Scoreboard
+	go func() {
+		if order := engine.Update(challenge.Game, f); order != 0 {
+			createSolvedEvent(s, challenge.Game, challenge, team, order)
+		}
+	}()
+
+	return nil
+}
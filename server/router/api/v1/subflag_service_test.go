@@ -0,0 +1,39 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/store"
+)
+
+func TestWithFound(t *testing.T) {
+	flag := &store.SubFlag{Name: "part", Flag: "flag{part}", Match: store.FlagMatchRegex, Weight: 50}
+	flag.ID = 1
+	flags := []*store.SubFlag{flag}
+
+	played := withFound(flags, map[uint]bool{1: true}, false)
+	assert.True(t, played[0].Found)
+	assert.Empty(t, played[0].Flag, "the flags should be cleared for the players")
+	assert.Zero(t, played[0].Match)
+	assert.Equal(t, "flag{part}", flag.Flag, "the stored sub flag should not be changed")
+
+	managed := withFound(flags, nil, true)
+	assert.False(t, managed[0].Found)
+	assert.Equal(t, "flag{part}", managed[0].Flag)
+	assert.Equal(t, store.FlagMatchRegex, managed[0].Match)
+}
@@ -16,6 +16,7 @@ package v1
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/spf13/cast"
 	"rina.icu/hoshino/internal/util"
 	"rina.icu/hoshino/server/context"
 	"rina.icu/hoshino/store"
@@ -23,6 +24,27 @@ import (
 
 type CreateTeamRequest struct {
 	Name string `json:"name"`
+
+	// Answers to the registration questions of the game
+	Answers []string `json:"answers"`
+
+	// Whether the rules of the game are accepted
+	AcceptRules bool `json:"accept_rules"`
+}
+
+type ReviewTeamRequest struct {
+	Comment string `json:"comment"`
+}
+
+// canPlay reports whether the user is able to see the challenges and submit flags,
+// the teams need to be approved if the game requires registration approval
+func canPlay(s *store.Store, game *store.Game, user *store.User) bool {
+	if !game.RequireApproval || game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return true
+	}
+
+	team := game.GetTeamByUser(s, user)
+	return team != nil && team.CanPlay(game)
 }
 
 func GetUserTeamIngame(c echo.Context) error {
@@ -63,21 +85,109 @@ func CreateTeam(c echo.Context) error {
 		return Failed(&c, "You are already in a team")
 	}
 
+	if game.Rules != "" && !req.AcceptRules {
+		return Failed(&c, "You must accept the rules")
+	}
+
+	if len(req.Answers) != len(game.Questions) {
+		return Failed(&c, "Please answer all the questions")
+	}
+
+	status := store.TeamStatusApproved
+	if game.RequireApproval {
+		status = store.TeamStatusPending
+	}
+
 	uuid := util.UUID()
-	ctx.Store.CreateTeam(&store.Team{
+	team := &store.Team{
 		Name:     req.Name,
 		UUID:     uuid,
 		Game:     game,
 		Creator:  user,
 		Managers: []*store.User{user},
 		Members:  []*store.User{user},
-	})
+		Status:   status,
+		Answers:  req.Answers,
+	}
+
+	if err := ctx.Store.CreateTeam(team); err != nil {
+		return Failed(&c, "Unable to create team")
+	}
+	ctx.Scoreboard.UpdateTeam(game, team)
 
 	return OKWithData(&c, map[string]any{
 		"team_uuid": uuid,
+		"status":    status,
 	})
 }
 
+func GetTeamRegistrations(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(ctx.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	status := store.TeamStatusPending
+	if c.QueryParam("status") != "" {
+		status = store.TeamStatus(cast.ToInt(c.QueryParam("status")))
+	}
+
+	teams, err := ctx.Store.GetTeamsByStatus(game, status)
+	if err != nil {
+		return Failed(&c, "Unable to fetch teams")
+	}
+
+	return OKWithData(&c, teams)
+}
+
+func reviewTeam(c echo.Context, status store.TeamStatus) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(ctx.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return PermissionDenied(&c)
+	}
+
+	var req ReviewTeamRequest
+	if err := ctx.Bind(&req); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	team, err := ctx.Store.GetTeamByUUID(ctx.Param("team_uuid"))
+	if err != nil || team.GameID != game.ID {
+		return Failed(&c, "Unable to fetch team")
+	}
+
+	team.Status = status
+	team.ReviewComment = req.Comment
+	if err := ctx.Store.UpdateTeam(team); err != nil {
+		return Failed(&c, "Unable to update team")
+	}
+	ctx.Scoreboard.UpdateTeam(game, team)
+
+	return OK(&c)
+}
+
+func ApproveTeam(c echo.Context) error {
+	return reviewTeam(c, store.TeamStatusApproved)
+}
+
+func RejectTeam(c echo.Context) error {
+	return reviewTeam(c, store.TeamStatusRejected)
+}
+
 func GetTeamScore(c echo.Context) error {
 	ctx := c.(*context.CustomContext)
 
@@ -93,8 +203,12 @@ func GetTeamScore(c echo.Context) error {
 		return Failed(&c, "You are not in a team")
 	}
 
+	frozen := scoreboardFrozen(game, user)
+	score, rank := ctx.Scoreboard.Rank(game, team, frozen)
+
 	return OKWithData(&c, map[string]any{
-		"score": team.GetTeamScore(ctx.Store),
-		"rank":  team.GetTeamRank(ctx.Store),
+		"score":  score,
+		"rank":   rank,
+		"frozen": frozen,
 	})
 }
@@ -0,0 +1,243 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/cast"
+	"rina.icu/hoshino/internal/util"
+	"rina.icu/hoshino/server/context"
+	"rina.icu/hoshino/store"
+)
+
+type WebhookPayload struct {
+	URL string `json:"url"`
+
+	// generated if empty on creation
+	Secret string `json:"secret"`
+
+	// names of the event types, empty for all
+	Events []string `json:"events"`
+
+	Enabled *bool `json:"enabled"`
+}
+
+func (p *WebhookPayload) valid() bool {
+	// the responses are shown in the delivery log, internal addresses are refused
+	if p.URL != "" && util.CheckPublicURL(p.URL) != nil {
+		return false
+	}
+
+	names := store.EventNames()
+	for _, event := range p.Events {
+		if !slices.Contains(names, event) {
+			return false
+		}
+	}
+	return true
+}
+
+// getManagedGame returns the game of the request if the user manages it
+func getManagedGame(c echo.Context) (*store.Game, *store.User, error) {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return nil, nil, Failed(&c, "Unable to fetch game")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		return nil, nil, PermissionDenied(&c)
+	}
+
+	return game, user, nil
+}
+
+func getGameWebhook(c echo.Context, game *store.Game) (*store.Webhook, error) {
+	ctx := c.(*context.CustomContext)
+
+	webhook, err := ctx.Store.GetWebhookByUUID(c.Param("webhook_uuid"))
+	if err != nil || webhook.GameID != game.ID {
+		return nil, Failed(&c, "Unable to fetch webhook")
+	}
+	return webhook, nil
+}
+
+func GetWebhooks(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	webhooks, err := ctx.Store.GetWebhooksByGame(game)
+	if err != nil {
+		return Failed(&c, "Unable to fetch webhooks")
+	}
+
+	return OKWithData(&c, webhooks)
+}
+
+func CreateWebhook(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, user, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	var payload WebhookPayload
+	if err := c.Bind(&payload); err != nil || payload.URL == "" || !payload.valid() {
+		return Failed(&c, "Invalid payload")
+	}
+
+	webhook := &store.Webhook{
+		UUID:    util.UUID(),
+		Game:    game,
+		URL:     payload.URL,
+		Secret:  payload.Secret,
+		Events:  payload.Events,
+		Enabled: payload.Enabled == nil || *payload.Enabled,
+		Owner:   user,
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret = util.RandomHex(32)
+	}
+
+	if err := ctx.Store.CreateWebhook(webhook); err != nil {
+		return Failed(&c, "Unable to create webhook")
+	}
+
+	return OKWithData(&c, map[string]any{
+		"uuid":   webhook.UUID,
+		"secret": webhook.Secret,
+	})
+}
+
+func UpdateWebhook(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	webhook, err := getGameWebhook(c, game)
+	if webhook == nil {
+		return err
+	}
+
+	var payload WebhookPayload
+	if err := c.Bind(&payload); err != nil || !payload.valid() {
+		return Failed(&c, "Invalid payload")
+	}
+
+	if payload.URL != "" {
+		webhook.URL = payload.URL
+	}
+	if payload.Secret != "" {
+		webhook.Secret = payload.Secret
+	}
+	if payload.Events != nil {
+		webhook.Events = payload.Events
+	}
+	if payload.Enabled != nil {
+		webhook.Enabled = *payload.Enabled
+	}
+
+	if err := ctx.Store.UpdateWebhook(webhook); err != nil {
+		return Failed(&c, "Unable to update webhook")
+	}
+
+	return OK(&c)
+}
+
+func DeleteWebhook(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	webhook, err := getGameWebhook(c, game)
+	if webhook == nil {
+		return err
+	}
+
+	if err := ctx.Store.DeleteWebhook(webhook); err != nil {
+		return Failed(&c, "Unable to delete webhook")
+	}
+
+	return OK(&c)
+}
+
+func GetWebhookDeliveries(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	webhook, err := getGameWebhook(c, game)
+	if webhook == nil {
+		return err
+	}
+
+	page := max(cast.ToInt(c.QueryParam("page")), 1)
+	size := cast.ToInt(c.QueryParam("size"))
+	if size <= 0 || size > 100 {
+		size = 20
+	}
+
+	deliveries, total, err := ctx.Store.GetWebhookDeliveries(webhook, (page-1)*size, size)
+	if err != nil {
+		return Failed(&c, "Unable to fetch deliveries")
+	}
+
+	return OKWithData(&c, map[string]any{
+		"total":      total,
+		"page":       page,
+		"size":       size,
+		"deliveries": deliveries,
+	})
+}
+
+func TestWebhook(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	webhook, err := getGameWebhook(c, game)
+	if webhook == nil {
+		return err
+	}
+
+	delivery, err := ctx.Webhook.SendTest(webhook, game.UUID)
+	if err != nil {
+		return Failed(&c, "Unable to send test event")
+	}
+
+	return OKWithData(&c, delivery)
+}
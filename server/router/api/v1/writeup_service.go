@@ -14,6 +14,300 @@
 
 package v1
 
-func UploadWriteup() {
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/labstack/echo/v4"
+	"rina.icu/hoshino/server/context"
+	"rina.icu/hoshino/store"
+)
+
+// size limit of a writeup file
+const writeupSizeLimit = 20 << 20
+
+var writeupExtensions = []string{".pdf", ".md", ".markdown"}
+
+type ReviewWriteupPayload struct {
+	Comment string `json:"comment"`
+}
+
+func writeupBrief(w *store.Writeup) map[string]any {
+	brief := map[string]any{
+		"uuid":          w.UUID,
+		"download_name": w.DownloadName,
+		"size":          w.Size,
+		"status":        w.Status,
+		"comment":       w.Comment,
+		"submitted_at":  w.SubmittedAt,
+		"reviewed_at":   w.ReviewedAt,
+		"challenge":     nil,
+	}
+
+	if w.Team != nil {
+		brief["team"] = map[string]any{"uuid": w.Team.UUID, "name": w.Team.Name}
+	}
+	if w.Challenge != nil {
+		brief["challenge"] = map[string]any{"uuid": w.Challenge.UUID, "name": w.Challenge.Name}
+	}
+	if w.Uploader != nil {
+		brief["uploader"] = w.Uploader.Nickname
+	}
+
+	return brief
+}
+
+// updateWriteupApproved applies whether the team has an approved writeup to the team and the scoreboard
+func updateWriteupApproved(ctx *context.CustomContext, game *store.Game, team *store.Team) {
+	approved := ctx.Store.HasApprovedWriteup(team)
+	if approved == team.WriteupApproved {
+		return
+	}
+
+	team.WriteupApproved = approved
+	ctx.Store.UpdateTeam(team)
+	ctx.Scoreboard.UpdateTeam(game, team)
+}
+
+// parseWriteupForm limits the size of the request body before reading the form,
+// the route skips the global body limit
+func parseWriteupForm(c echo.Context) (*multipart.Form, error) {
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, writeupSizeLimit)
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if len(form.File["file"]) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	return form, nil
+}
+
+// UploadWriteup uploads the writeup of the team for the game, or the challenge in the form,
+// a writeup uploaded again replaces the previous one and waits for review again
+func UploadWriteup(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil || !(game.Visibility || user.HasPrivilege(store.UserPrivilegeAdministrator) || game.IsManager(user)) {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	team := game.GetTeamByUser(ctx.Store, user)
+	if team == nil {
+		return Failed(&c, "You are not in a team")
+	}
+
+	if !team.CanPlay(game) {
+		return PermissionDenied(&c)
+	}
+
+	if !game.WriteupOpen() {
+		return Failed(&c, "The writeup deadline has passed")
+	}
+
+	form, err := parseWriteupForm(c)
+	if err != nil {
+		return Failed(&c, "Failed to get file from request")
+	}
+	file := form.File["file"][0]
+
+	var challenge *store.Challenge
+	if values := form.Value["challenge"]; len(values) > 0 && values[0] != "" {
+		challenge, err = ctx.Store.GetChallengeByUUID(values[0])
+		if err != nil || challenge.GameID != game.ID || challenge.State != store.ChallengeStateVisible {
+			return Failed(&c, "Unable to fetch challenge")
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !slices.Contains(writeupExtensions, ext) {
+		return Failed(&c, "Writeups should be PDF or Markdown files")
+	}
+
+	uuid, path, err := saveUpload(ctx, file)
+	if err != nil {
+		return Failed(&c, "Failed to save the file")
+	}
+
+	downloadName := fmt.Sprintf("%s%s", team.Name, ext)
+	if challenge != nil {
+		downloadName = fmt.Sprintf("%s-%s%s", team.Name, challenge.Name, ext)
+	}
+
+	challengeID := uint(0)
+	if challenge != nil {
+		challengeID = challenge.ID
+	}
+
+	// the replaced file is removed once the new one is recorded
+	replaced := ""
+	writeup, err := ctx.Store.GetWriteupByTeam(team, challengeID)
+	if err != nil {
+		writeup = &store.Writeup{
+			UUID:        uuid,
+			GameID:      game.ID,
+			ChallengeID: challengeID,
+			TeamID:      team.ID,
+		}
+	} else {
+		replaced = writeup.SavePath
+	}
+
+	writeup.UploaderID = user.ID
+	writeup.SavePath = path
+	writeup.DownloadName = downloadName
+	writeup.Size = file.Size
+	writeup.Status = store.WriteupStatusPending
+	writeup.SubmittedAt = time.Now().UnixMilli()
+	writeup.ReviewedAt = 0
+
+	if writeup.ID == 0 {
+		err = ctx.Store.CreateWriteup(writeup)
+	} else {
+		err = ctx.Store.UpdateWriteup(writeup)
+	}
+	if err != nil {
+		os.Remove(path)
+		return Failed(&c, "Unable to save writeup")
+	}
+	if replaced != "" {
+		os.Remove(replaced)
+	}
+
+	updateWriteupApproved(ctx, game, team)
+
+	return OKWithData(&c, map[string]any{"uuid": writeup.UUID})
+}
+
+// GetWriteups returns the writeups of the team, or all the writeups for the managers
+func GetWriteups(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil || !(game.Visibility || user.HasPrivilege(store.UserPrivilegeAdministrator) || game.IsManager(user)) {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	var team *store.Team
+	if game.IsManager(user) || user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		if uuid := c.QueryParam("team"); uuid != "" {
+			team, err = ctx.Store.GetTeamByUUID(uuid)
+			if err != nil || team.GameID != game.ID {
+				return Failed(&c, "Unable to fetch team")
+			}
+		}
+	} else {
+		team = game.GetTeamByUser(ctx.Store, user)
+		if team == nil {
+			return Failed(&c, "You are not in a team")
+		}
+	}
+
+	writeups, err := ctx.Store.GetWriteups(game, team)
+	if err != nil {
+		return Failed(&c, "Unable to fetch writeups")
+	}
+
+	briefs := make([]map[string]any, 0, len(writeups))
+	for _, writeup := range writeups {
+		briefs = append(briefs, writeupBrief(writeup))
+	}
+
+	return OKWithData(&c, briefs)
+}
+
+func DownloadWriteup(c echo.Context) error {
+	ctx := c.(*context.CustomContext)
+
+	user, _ := GetUserFromToken(&c)
+	game, err := ctx.Store.GetGameByUUID(c.Param("game_uuid"))
+	if err != nil {
+		return Failed(&c, "Unable to fetch game")
+	}
+
+	writeup, err := ctx.Store.GetWriteupByUUID(c.Param("writeup_uuid"))
+	if err != nil || writeup.GameID != game.ID {
+		return Failed(&c, "Unable to fetch writeup")
+	}
+
+	if !game.IsManager(user) && !user.HasPrivilege(store.UserPrivilegeAdministrator) {
+		team := game.GetTeamByUser(ctx.Store, user)
+		if team == nil || team.ID != writeup.TeamID {
+			return PermissionDenied(&c)
+		}
+	}
+
+	return c.Attachment(writeup.SavePath, writeup.DownloadName)
+}
+
+// reviewWriteup comments on the writeup, and approves or rejects it if the status is given
+func reviewWriteup(c echo.Context, status *store.WriteupStatus) error {
+	ctx := c.(*context.CustomContext)
+
+	game, _, err := getManagedGame(c)
+	if game == nil {
+		return err
+	}
+
+	var payload ReviewWriteupPayload
+	if err := c.Bind(&payload); err != nil {
+		return Failed(&c, "Invalid payload")
+	}
+
+	writeup, err := ctx.Store.GetWriteupByUUID(c.Param("writeup_uuid"))
+	if err != nil || writeup.GameID != game.ID {
+		return Failed(&c, "Unable to fetch writeup")
+	}
+
+	writeup.Comment = payload.Comment
+	if status != nil {
+		writeup.Status = *status
+		writeup.ReviewedAt = time.Now().UnixMilli()
+	}
+
+	if err := ctx.Store.UpdateWriteup(writeup); err != nil {
+		return Failed(&c, "Unable to update writeup")
+	}
+
+	if status != nil {
+		updateWriteupApproved(ctx, game, writeup.Team)
+
+		result := "approved"
+		if *status == store.WriteupStatusRejected {
+			result = "rejected"
+		}
+
+		ctx.Store.NotifyTeam(writeup.Team, store.Notification{
+			GameID:   game.ID,
+			GameUUID: game.UUID,
+			Type:     store.NotificationTypeSystem,
+			Title:    fmt.Sprintf("Your writeup has been %s", result),
+			Content:  payload.Comment,
+		})
+	}
+
+	return OK(&c)
+}
+
+func ApproveWriteup(c echo.Context) error {
+	status := store.WriteupStatusApproved
+	return reviewWriteup(c, &status)
+}
+
+func RejectWriteup(c echo.Context) error {
+	status := store.WriteupStatusRejected
+	return reviewWriteup(c, &status)
+}
+
+func CommentWriteup(c echo.Context) error {
+	return reviewWriteup(c, nil)
 }
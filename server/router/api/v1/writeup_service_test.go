@@ -0,0 +1,52 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWriteupRequest(t *testing.T, size int) echo.Context {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	assert.NoError(t, w.WriteField("challenge", "challenge"))
+	part, err := w.CreateFormFile("file", "writeup.md")
+	assert.NoError(t, err)
+	_, err = part.Write(bytes.Repeat([]byte("a"), size))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+func TestParseWriteupForm(t *testing.T) {
+	form, err := parseWriteupForm(newWriteupRequest(t, 1024))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"challenge"}, form.Value["challenge"])
+	assert.EqualValues(t, 1024, form.File["file"][0].Size)
+
+	// the body over the limit is rejected before the form is read
+	_, err = parseWriteupForm(newWriteupRequest(t, writeupSizeLimit+1))
+	assert.Error(t, err)
+}
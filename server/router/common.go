@@ -28,3 +28,18 @@ func RequireEmailVerified(path string) bool {
 		path != "/api/v1/user" &&
 		RequireLogin(path))
 }
+
+// AcceptQueryToken reports whether the token can be passed in the query,
+// for browsers cannot set headers on WebSocket and EventSource
+func AcceptQueryToken(path string) bool {
+	return path == "/api/v1/game/:game_uuid/events/stream"
+}
+
+// SkipBodyLimit reports whether the path accepts bodies larger than the default limit,
+// these handlers should limit the size themselves
+func SkipBodyLimit(path string) bool {
+	return path == "/api/v1/game/:game_uuid/writeup" ||
+		path == "/api/v1/game/:game_uuid/challenge/import" ||
+		path == "/api/v1/game/import" ||
+		path == "/api/v1/game/import/ctfd"
+}
@@ -26,8 +26,12 @@ import (
 	"golang.org/x/time/rate"
 	"k8s.io/client-go/kubernetes"
 
+	"rina.icu/hoshino/internal/hub"
 	"rina.icu/hoshino/internal/k8s"
+	"rina.icu/hoshino/internal/scoreboard"
 	"rina.icu/hoshino/plugins/cron"
+	"rina.icu/hoshino/plugins/notifier"
+	"rina.icu/hoshino/plugins/webhook"
 	"rina.icu/hoshino/server/config"
 	cc "rina.icu/hoshino/server/context"
 	hmw "rina.icu/hoshino/server/middleware"
@@ -61,8 +65,7 @@ func registerRouter(s *Server) {
 		middleware.BodyLimitConfig{
 			Limit: "2M",
 			Skipper: func(c echo.Context) bool {
-				// TODO: skip some upload cases
-				return false
+				return router.SkipBodyLimit(c.Path())
 			},
 		},
 	))
@@ -72,6 +75,15 @@ func registerRouter(s *Server) {
 		Skipper: func(c echo.Context) bool {
 			return !router.RequireLogin(c.Path())
 		},
+		TokenLookup: "header:Authorization:Bearer ",
+		TokenLookupFuncs: []middleware.ValuesExtractor{
+			func(c echo.Context) ([]string, error) {
+				if token := c.QueryParam("token"); token != "" && router.AcceptQueryToken(c.Path()) {
+					return []string{token}, nil
+				}
+				return nil, nil
+			},
+		},
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return new(jwt.MapClaims)
 		},
@@ -90,6 +102,13 @@ func registerRouter(s *Server) {
 	userApi.POST("/email/verify", v1.EmailVerify).Name = "verify-email"
 	userApi.POST("/email/send", v1.SendVerificationEmail).Name = "send-email"
 
+	// Notification APIs
+	notificationApi := userApi.Group("/notification")
+	notificationApi.GET("", v1.GetNotifications).Name = "get-notifications"
+	notificationApi.POST("/read", v1.MarkAllNotificationsRead).Name = "read-all-notifications"
+	notificationApi.POST("/:notification_uuid/read", v1.MarkNotificationRead).Name = "read-notification"
+	notificationApi.DELETE("/:notification_uuid", v1.DeleteNotification).Name = "delete-notification"
+
 	// Container APIs
 	containersApi := g.Group("/container")
 	containersApi.POST("/create", v1.CreateContainer).Name = "create-container"
@@ -105,6 +124,14 @@ func registerRouter(s *Server) {
 	gameApi.GET("", v1.GetGames).Name = "get-games"
 	gameApi.GET("/:game_uuid", v1.GetGame).Name = "get-game"
 	gameApi.POST("/create", v1.CreateGame).Name = "create-game"
+	gameApi.POST("/import", v1.ImportGame).Name = "import-game"
+	gameApi.POST("/import/ctfd", v1.ImportCTFdGame).Name = "import-ctfd-game"
+	gameApi.POST("/:game_uuid", v1.UpdateGame).Name = "update-game"
+	gameApi.DELETE("/:game_uuid", v1.DeleteGame).Name = "delete-game"
+	gameApi.POST("/:game_uuid/clone", v1.CloneGame).Name = "clone-game"
+	gameApi.GET("/:game_uuid/export", v1.ExportGame).Name = "export-game"
+	gameApi.POST("/:game_uuid/manager", v1.AddGameManager).Name = "add-game-manager"
+	gameApi.DELETE("/:game_uuid/manager/:user_uuid", v1.RemoveGameManager).Name = "remove-game-manager"
 
 	// Team APIs
 	teamApi := gameApi.Group("/:game_uuid/team")
@@ -113,8 +140,55 @@ func registerRouter(s *Server) {
 	// teamApi.GET("/:uuid", v1.GetTeam).Name = "get-team"
 	teamApi.POST("/create", v1.CreateTeam).Name = "create-team"
 	teamApi.GET("/score", v1.GetTeamScore).Name = "get-team-score"
+	teamApi.GET("/registration", v1.GetTeamRegistrations).Name = "get-team-registrations"
+	teamApi.POST("/:team_uuid/approve", v1.ApproveTeam).Name = "approve-team"
+	teamApi.POST("/:team_uuid/reject", v1.RejectTeam).Name = "reject-team"
 	// teamApi.POST("/:uuid/ban", v1.BanTeam).Name = "ban-team"
 
+	// Announcement APIs
+	announcementApi := gameApi.Group("/:game_uuid/announcement")
+	announcementApi.GET("", v1.GetAnnouncements).Name = "get-announcements"
+	announcementApi.POST("", v1.CreateAnnouncement).Name = "create-announcement"
+	announcementApi.DELETE("/:announcement_uuid", v1.DeleteAnnouncement).Name = "delete-announcement"
+
+	// Writeup APIs
+	writeupApi := gameApi.Group("/:game_uuid/writeup")
+	writeupApi.GET("", v1.GetWriteups).Name = "get-writeups"
+	writeupApi.POST("", v1.UploadWriteup).Name = "upload-writeup"
+	writeupApi.GET("/:writeup_uuid", v1.DownloadWriteup).Name = "download-writeup"
+	writeupApi.POST("/:writeup_uuid/approve", v1.ApproveWriteup).Name = "approve-writeup"
+	writeupApi.POST("/:writeup_uuid/reject", v1.RejectWriteup).Name = "reject-writeup"
+	writeupApi.POST("/:writeup_uuid/comment", v1.CommentWriteup).Name = "comment-writeup"
+
+	// Scoreboard APIs
+	scoreboardApi := gameApi.Group("/:game_uuid/scoreboard")
+	scoreboardApi.GET("", v1.GetScoreboard).Name = "get-scoreboard"
+	scoreboardApi.GET("/export", v1.ExportScoreboard).Name = "export-scoreboard"
+	scoreboardApi.POST("/reveal", v1.RevealScoreboard).Name = "reveal-scoreboard"
+
+	// Event APIs
+	eventApi := gameApi.Group("/:game_uuid/events")
+	eventApi.GET("", v1.GetGameEvents).Name = "get-game-events"
+	eventApi.GET("/stream", v1.StreamGameEvents).Name = "stream-game-events"
+	eventApi.POST("/:event_id/visibility", v1.SetGameEventVisibility).Name = "set-game-event-visibility"
+
+	// Webhook APIs
+	webhookApi := gameApi.Group("/:game_uuid/webhook")
+	webhookApi.GET("", v1.GetWebhooks).Name = "get-webhooks"
+	webhookApi.POST("", v1.CreateWebhook).Name = "create-webhook"
+	webhookApi.POST("/:webhook_uuid", v1.UpdateWebhook).Name = "update-webhook"
+	webhookApi.DELETE("/:webhook_uuid", v1.DeleteWebhook).Name = "delete-webhook"
+	webhookApi.GET("/:webhook_uuid/delivery", v1.GetWebhookDeliveries).Name = "get-webhook-deliveries"
+	webhookApi.POST("/:webhook_uuid/test", v1.TestWebhook).Name = "test-webhook"
+
+	// Notifier APIs
+	notifierApi := gameApi.Group("/:game_uuid/notifier")
+	notifierApi.GET("", v1.GetNotifiers).Name = "get-notifiers"
+	notifierApi.POST("", v1.CreateNotifier).Name = "create-notifier"
+	notifierApi.POST("/:notifier_uuid", v1.UpdateNotifier).Name = "update-notifier"
+	notifierApi.DELETE("/:notifier_uuid", v1.DeleteNotifier).Name = "delete-notifier"
+	notifierApi.POST("/:notifier_uuid/test", v1.TestNotifier).Name = "test-notifier"
+
 	// Challenge APIs
 	challengeApi := gameApi.Group("/:game_uuid/challenge")
 	challengeApi.GET("", v1.GetFullChallenges).Name = "get-challenges"
@@ -122,8 +196,15 @@ func registerRouter(s *Server) {
 	challengeApi.POST("/create", v1.CreateChallenge).Name = "create-challenge"
 	challengeApi.POST("/create/container", v1.CreateContainer).Name = "create-test-container"
 	challengeApi.DELETE("/create/container/:container_uuid", v1.DisposeContainer).Name = "dispose-test-container"
+	challengeApi.POST("/flag/preview", v1.PreviewFlag).Name = "preview-flag"
 	challengeApi.POST("/:challenge_uuid/flag", v1.SubmitFlag).Name = "submit-flag"
 	challengeApi.GET("/status", v1.GetChallengeStatus).Name = "get-challenge-status"
+	challengeApi.POST("/order", v1.ReorderChallenges).Name = "reorder-challenges"
+	challengeApi.POST("/import", v1.ImportChallenges).Name = "import-challenges"
+	challengeApi.POST("/:challenge_uuid", v1.UpdateChallenge).Name = "update-challenge"
+	challengeApi.DELETE("/:challenge_uuid", v1.DeleteChallenge).Name = "delete-challenge"
+	challengeApi.POST("/:challenge_uuid/state", v1.SetChallengeState).Name = "set-challenge-state"
+	challengeApi.POST("/:challenge_uuid/subflag", v1.SetSubFlags).Name = "set-sub-flags"
 
 	// Container APIs
 	containerApi := challengeApi.Group("/:challenge_uuid/container")
@@ -131,9 +212,18 @@ func registerRouter(s *Server) {
 	containerApi.DELETE("", v1.DisposeChallengeContainer).Name = "dispose-container"
 	containerApi.GET("", v1.GetChallengeRunningContainer).Name = "get-container"
 
+	// Hint APIs
+	hintApi := challengeApi.Group("/:challenge_uuid/hint")
+	hintApi.GET("", v1.GetHints).Name = "get-hints"
+	hintApi.POST("", v1.CreateHint).Name = "create-hint"
+	hintApi.POST("/:hint_uuid", v1.UpdateHint).Name = "update-hint"
+	hintApi.DELETE("/:hint_uuid", v1.DeleteHint).Name = "delete-hint"
+	hintApi.POST("/:hint_uuid/unlock", v1.UnlockHint).Name = "unlock-hint"
+
 	// Attachment APIs
 	attachmentApi := challengeApi.Group("/:challenge_uuid/attachment")
 	attachmentApi.POST("/", v1.UploadAttachment).Name = "upload-attachment"
+	attachmentApi.GET("/flag", v1.GetFlagAttachment).Name = "get-flag-attachment"
 	attachmentApi.GET("/:attachment_uuid", v1.GetAttachment).Name = "get-attachment"
 	attachmentApi.GET("/", v1.GetAttachmentList).Name = "get-attachments"
 
@@ -174,6 +264,11 @@ func NewServer(ctx context.Context, config *config.Config, clientSet *kubernetes
 		Store:     store,
 	}
 
+	scoreboardEngine := scoreboard.NewEngine(store)
+	eventHub := hub.New(256)
+	webhookDispatcher := webhook.NewDispatcher(store)
+	notifierDispatcher := notifier.NewDispatcher(store)
+
 	echoServer.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			ctx := &cc.CustomContext{
@@ -181,6 +276,10 @@ func NewServer(ctx context.Context, config *config.Config, clientSet *kubernetes
 				Config:           s.config,
 				Store:            s.store,
 				ContainerManager: containerManager,
+				Scoreboard:       scoreboardEngine,
+				Hub:              eventHub,
+				Webhook:          webhookDispatcher,
+				Notifier:         notifierDispatcher,
 			}
 			return next(ctx)
 		}
@@ -193,9 +292,26 @@ func NewServer(ctx context.Context, config *config.Config, clientSet *kubernetes
 
 	s.store = store
 
+	// Event stream
+	store.OnGameEvent(eventHub.PublishGameEvent)
+	scoreboardEngine.OnChange(eventHub.PublishScoreboard)
+
+	// Webhooks
+	store.OnGameEvent(webhookDispatcher.HandleGameEvent)
+	webhookDispatcher.Start()
+
+	// Chat notifiers
+	store.OnGameEvent(notifierDispatcher.HandleGameEvent)
+
+	if err := scoreboardEngine.Load(); err != nil {
+		slog.Error("Failed to load the scoreboards")
+		panic(err)
+	}
+
 	// Cron
 
 	cron.InitContainerCron(store, containerManager)
+	cron.InitChallengeCron(store, containerManager)
 
 	registerRouter(s)
 
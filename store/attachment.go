@@ -52,9 +52,10 @@ func (s *Store) GetAttachmentsByChallenge(challenge *Challenge) ([]Attachment, e
 	return attachments, err
 }
 
-func (s *Store) GetAttachmentsByName(name string) ([]Attachment, error) {
+// GetAttachmentsByChallengeAndName returns the variants of a multiple attachment in the challenge
+func (s *Store) GetAttachmentsByChallengeAndName(challenge *Challenge, name string) ([]Attachment, error) {
 	var attachments []Attachment
-	err := s.db.Where("name = ?", name).Find(&attachments).Error
+	err := s.db.Where("challenge_id = ? AND name = ?", challenge.ID, name).Order("id ASC").Find(&attachments).Error
 	return attachments, err
 }
 
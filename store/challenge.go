@@ -15,9 +15,12 @@
 package store
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"rina.icu/hoshino/store/types"
 )
 
@@ -38,6 +41,17 @@ const (
 	AfterExpireScore                         = 4
 )
 
+// TeamFlagDelivery is how a containerless challenge with dynamic flags delivers the flags of the teams
+type TeamFlagDelivery int
+
+const (
+	TeamFlagNone TeamFlagDelivery = iota
+	// {{flag}} in the description is replaced by the flag of the team
+	TeamFlagDescription
+	// the flag of the team is downloaded as a generated flag.txt attachment
+	TeamFlagAttachment
+)
+
 type Challenge struct {
 	gorm.Model `json:"-"`
 
@@ -68,6 +82,9 @@ type Challenge struct {
 	// Category of the challenge
 	Category string `json:"category"`
 
+	// Position of the challenge in the game, challenges are listed in ascending order
+	Position int `gorm:"default:0" json:"position"`
+
 	// StartTime of the challenge
 	StartTime int64 `gorm:"default:0" json:"start_time"`
 
@@ -77,6 +94,12 @@ type Challenge struct {
 	// Is this challenge available (to create container, submit flag etc.) after the deadline
 	AfterExpiredOperations AfterExpireOp `gorm:"default:0" json:"after_expired_operations"`
 
+	// When the start time was handled by the scheduler, 0 if not yet
+	ReleasedAt int64 `gorm:"default:0" json:"released_at" priv:"2"`
+
+	// When the expire time was handled by the scheduler, 0 if not yet
+	ClosedAt int64 `gorm:"default:0" json:"closed_at" priv:"2"`
+
 	// Image of the challenge
 	ImageID uint   `json:"-"`
 	Image   *Image `gorm:"foreignKey:ImageID" json:"image" priv:"2"`
@@ -87,9 +110,24 @@ type Challenge struct {
 	// Dynamic flag or not
 	DynamicFlag bool `gorm:"default:false" json:"dynamic_flag" priv:"2"`
 
+	// Stateless dynamic flags are derived from the flag secret, the team and the challenge,
+	// so they are verified without the flag records created by the containers
+	StatelessFlag bool `gorm:"default:false" json:"stateless_flag" priv:"2"`
+
+	// How the dynamic flags of a containerless challenge are delivered to the teams,
+	// the flag of a team is generated when the team opens the challenge for the first time
+	TeamFlag TeamFlagDelivery `gorm:"default:0" json:"team_flag"`
+
 	// FlagFormat template of the challenge
 	FlagFormat string `json:"flag" priv:"2"`
 
+	// Key of the {hmac:N} placeholder of the flag format, generated when the challenge is created
+	FlagSecret string `json:"flag_secret" priv:"2"`
+
+	// How the submitted flags are compared, the flag format is a pattern in regex mode
+	// Regex mode is not available for dynamic flags
+	FlagMatch FlagMatch `gorm:"default:0" json:"flag_match" priv:"2"`
+
 	// Score of the challenge (if not dynamic)
 	Score int `gorm:"default:0" json:"score"`
 
@@ -103,15 +141,127 @@ type Challenge struct {
 	// Set for anti-cheat
 	FakeFlag types.StringArray `gorm:"type:text" json:"fake_flag" priv:"2"`
 
-	// Hints of the challenge
-	// Markdown supported
-	Hints types.StringArray `gorm:"type:text" json:"hints"`
+	// Hints of the challenge, filled by the handlers with the hints visible to the user
+	Hints []*Hint `gorm:"foreignKey:ChallengeID" json:"hints,omitempty"`
+
+	// Sub flags of a multi-part challenge, the flag format is not used if there are any
+	SubFlags []*SubFlag `gorm:"foreignKey:ChallengeID" json:"sub_flags,omitempty"`
+
+	// UUIDs of the challenges which should be solved before the challenge is unlocked
+	Requires types.StringArray `gorm:"type:text" json:"requires"`
+
+	// Score the team should reach before the challenge is unlocked
+	RequiredScore int `gorm:"default:0" json:"required_score"`
+
+	// Hide the challenge instead of showing it as locked until it is unlocked
+	HideLocked bool `gorm:"default:false" json:"hide_locked"`
+
+	// Is the challenge locked for the team of the requesting user, not persisted
+	Locked bool `gorm:"-" json:"locked"`
+}
+
+// newFlagSecret returns a random key of the {hmac:N} flags
+func newFlagSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
 func (s *Store) CreateChallenge(challenge *Challenge) error {
+	if challenge.FlagSecret == "" {
+		challenge.FlagSecret = newFlagSecret()
+	}
 	return s.db.Create(challenge).Error
 }
 
+// CreateGameChallenge creates the challenge and adds it to the game
+func (s *Store) CreateGameChallenge(game *Game, challenge *Challenge) error {
+	if challenge.FlagSecret == "" {
+		challenge.FlagSecret = newFlagSecret()
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(challenge).Error; err != nil {
+			return err
+		}
+		return tx.Model(game).Association("Challenges").Append(challenge)
+	})
+}
+
+// GetFlagSecret returns the flag secret of the challenge,
+// a new one is saved for the challenges created before the secrets were introduced
+func (s *Store) GetFlagSecret(challenge *Challenge) (string, error) {
+	if challenge.FlagSecret != "" {
+		return challenge.FlagSecret, nil
+	}
+
+	secret := newFlagSecret()
+	if err := s.db.Model(challenge).Update("flag_secret", secret).Error; err != nil {
+		return "", err
+	}
+	challenge.FlagSecret = secret
+	return secret, nil
+}
+
+// UpdateChallenge saves the challenge and its image, the image is created if it's new
+func (s *Store) UpdateChallenge(challenge *Challenge) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if challenge.Image != nil {
+			if err := tx.Save(challenge.Image).Error; err != nil {
+				return err
+			}
+			challenge.ImageID = challenge.Image.ID
+		}
+		return tx.Omit(clause.Associations).Save(challenge).Error
+	})
+}
+
+// ReleaseChallenge records the release of the challenge by the scheduler and sets its state,
+// returns false if the challenge has been released or its state has changed since it was fetched
+func (s *Store) ReleaseChallenge(challenge *Challenge, state ChallengeState, now int64) (bool, error) {
+	return s.scheduleChallenge(challenge, "released_at", state, now)
+}
+
+// CloseChallenge records the close of the challenge by the scheduler and sets its state,
+// returns false if the challenge has been closed or its state has changed since it was fetched
+func (s *Store) CloseChallenge(challenge *Challenge, state ChallengeState, now int64) (bool, error) {
+	return s.scheduleChallenge(challenge, "closed_at", state, now)
+}
+
+// scheduleChallenge updates only the state and the scheduler time of the challenge,
+// so that the changes made by the managers meanwhile are kept
+func (s *Store) scheduleChallenge(challenge *Challenge, column string, state ChallengeState, now int64) (bool, error) {
+	result := s.db.Model(challenge).
+		Where("state = ? AND "+column+" = 0", challenge.State).
+		Updates(map[string]any{"state": state, column: now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	challenge.State = state
+	return true, nil
+}
+
+// DeleteChallenge soft-deletes the challenge, its solves no longer count in the scoreboard
+func (s *Store) DeleteChallenge(challenge *Challenge) error {
+	return s.db.Delete(challenge).Error
+}
+
+// UpdateChallengePositions sets the positions of the challenges of the game to the order of the UUIDs
+func (s *Store) UpdateChallengePositions(game *Game, uuids []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for i, uuid := range uuids {
+			result := tx.Model(&Challenge{}).Where("uuid = ? AND game_id = ?", uuid, game.ID).Update("position", i)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return nil
+	})
+}
+
 func (s *Store) GetChallenges() ([]*Challenge, error) {
 	var challenges []*Challenge
 	err := s.db.Preload("Game").Preload("Creator").Preload("Image").Find(&challenges).Error
@@ -126,10 +276,42 @@ func (s *Store) GetChallengeByID(id int) (*Challenge, error) {
 
 func (s *Store) GetChallengeByUUID(uuid string) (*Challenge, error) {
 	var challenge Challenge
-	err := s.db.Preload("Game").Preload("Creator").Preload("Image").Where("uuid = ?", uuid).First(&challenge).Error
+	err := s.db.Preload("Game").Preload("Game.Managers").Preload("Creator").Preload("Image").Where("uuid = ?", uuid).First(&challenge).Error
 	return &challenge, err
 }
 
+// GetChallengesByGame returns the challenges of the game with their images, hints, sub flags and creators
+func (s *Store) GetChallengesByGame(game *Game) ([]*Challenge, error) {
+	var challenges []*Challenge
+	err := orderChallenges(s.db.Preload("Image").Preload("Hints").Preload("SubFlags").Preload("Creator").Where("game_id = ?", game.ID)).
+		Find(&challenges).Error
+	return challenges, err
+}
+
+// Staged reports whether the time was set ahead of the creation of the challenge,
+// only the staged start and expire times are applied automatically
+func (c *Challenge) Staged(t int64) bool {
+	return t != 0 && t > c.CreatedAt.UnixMilli()
+}
+
+// GetChallengesToRelease returns the hidden challenges whose start time has passed but not yet released
+func (s *Store) GetChallengesToRelease(now int64) ([]*Challenge, error) {
+	var challenges []*Challenge
+	err := s.db.Preload("Game").
+		Where("state = ? AND start_time > 0 AND start_time <= ? AND released_at = 0", ChallengeStateHidden, now).
+		Find(&challenges).Error
+	return challenges, err
+}
+
+// GetChallengesToClose returns the challenges whose expire time has passed but not yet closed
+func (s *Store) GetChallengesToClose(now int64) ([]*Challenge, error) {
+	var challenges []*Challenge
+	err := s.db.Preload("Game").
+		Where("expire_time > 0 AND expire_time <= ? AND closed_at = 0", now).
+		Find(&challenges).Error
+	return challenges, err
+}
+
 func (c *Challenge) Expired() bool {
 	return c.ExpireTime != 0 && c.ExpireTime < time.Now().UnixMilli()
 }
@@ -150,6 +332,16 @@ func (c *Challenge) GetScore(team *Team, s *Store) int {
 	return score
 }
 
+// GetSolvedChallengeUUIDs returns the UUIDs of the challenges solved by the team
+func (s *Store) GetSolvedChallengeUUIDs(team *Team) ([]string, error) {
+	var uuids []string
+	err := s.db.Model(&Challenge{}).
+		Joins("JOIN flags ON flags.challenge_id = challenges.id AND flags.deleted_at IS NULL").
+		Where("flags.team_id = ? AND flags.state = ?", team.ID, FlagSolved).
+		Distinct().Pluck("challenges.uuid", &uuids).Error
+	return uuids, err
+}
+
 func (c *Challenge) GetSolvedCount(s *Store) int {
 	var count int64
 	s.db.Model(Flag{}).Where("challenge_id = ? AND state >= 1", c.ID).Count(&count)
@@ -79,7 +79,14 @@ func (s *Store) GetContainerByChallengeAndUser(c *Challenge, u *User) (*Containe
 
 func (s *Store) GetExpiredRunningContainers() ([]*Container, error) {
 	var containers []*Container
-	err := s.db.Preload("Creator").Preload("Challenge").Where("expire_time < ? AND status = 1", time.Now().UnixMilli()).Find(&containers).Error
+	err := s.db.Preload("Creator").Preload("Challenge.Game").Where("expire_time < ? AND status = 1", time.Now().UnixMilli()).Find(&containers).Error
+	return containers, err
+}
+
+// GetRunningContainersByChallenge returns the running containers of the challenge
+func (s *Store) GetRunningContainersByChallenge(challenge *Challenge) ([]*Container, error) {
+	var containers []*Container
+	err := s.db.Preload("Creator").Where("challenge_id = ? AND status = ?", challenge.ID, ContainerStatusRunning).Find(&containers).Error
 	return containers, err
 }
 
@@ -15,6 +15,8 @@
 package store
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -24,8 +26,56 @@ const (
 	GameEventTypeNormal EventType = iota
 	GameEventTypeChallengeSolved
 	GameEventTypeCheatDetected
+	GameEventTypeScoreboardRevealed
+	GameEventTypeAnnouncement
 )
 
+var eventTypeNames = map[EventType]string{
+	GameEventTypeNormal:             "normal",
+	GameEventTypeChallengeSolved:    "challenge_solved",
+	GameEventTypeCheatDetected:      "cheat_detected",
+	GameEventTypeScoreboardRevealed: "scoreboard_revealed",
+	GameEventTypeAnnouncement:       "announcement",
+}
+
+// ordinals of the first three solves, the events of the bloods are named like first_blood
+var bloodOrdinals = []string{"first", "second", "third"}
+
+// BloodOrdinal returns the ordinal of the blood, empty if it's not a blood
+func BloodOrdinal(blood int) string {
+	if blood < 1 || blood > len(bloodOrdinals) {
+		return ""
+	}
+	return bloodOrdinals[blood-1]
+}
+
+// Name of the event type used by the integrations
+func (t EventType) Name() string {
+	return eventTypeNames[t]
+}
+
+// EventTypeByName returns the event type of the name
+func EventTypeByName(name string) (EventType, bool) {
+	for t, n := range eventTypeNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// EventNames returns the names of all the event types and the bloods
+func EventNames() []string {
+	names := make([]string, 0, len(eventTypeNames)+len(bloodOrdinals))
+	for t := GameEventTypeNormal; t <= GameEventTypeAnnouncement; t++ {
+		names = append(names, t.Name())
+	}
+	for _, ordinal := range bloodOrdinals {
+		names = append(names, ordinal+"_blood")
+	}
+	return names
+}
+
 // Events during the game
 type GameEvent struct {
 	gorm.Model
@@ -45,28 +95,151 @@ type GameEvent struct {
 	RelatedTeams []*Team `gorm:"many2many:event_teams;" json:"related_teams"`
 
 	// Visibility to the normal players
-	Visibility bool `gorm:"default:true" json:"visibility"`
+	Visibility bool `json:"visibility"`
 
 	// Type of the event
 	Type EventType `gorm:"default:0" json:"type"`
+
+	// Blood of the solved event, 1~3 for the first three solves, 0 otherwise
+	Blood int `gorm:"default:0" json:"blood"`
+}
+
+// Name of the event used by the integrations, the solved events of the bloods are named by the blood
+func (e *GameEvent) Name() string {
+	if ordinal := BloodOrdinal(e.Blood); e.Type == GameEventTypeChallengeSolved && ordinal != "" {
+		return ordinal + "_blood"
+	}
+	return e.Type.Name()
+}
+
+// Brief returns the public fields of the event for the streams and the integrations
+func (e *GameEvent) Brief() map[string]any {
+	brief := map[string]any{
+		"id":         e.ID,
+		"type":       e.Type,
+		"blood":      e.Blood,
+		"content":    e.Content,
+		"visibility": e.Visibility,
+		"created_at": e.CreatedAt.UnixMilli(),
+	}
+
+	if e.Challenge != nil {
+		brief["challenge"] = map[string]any{
+			"uuid": e.Challenge.UUID,
+			"name": e.Challenge.Name,
+		}
+	}
+
+	teams := []map[string]any{}
+	for _, team := range e.RelatedTeams {
+		teams = append(teams, map[string]any{
+			"uuid": team.UUID,
+			"name": team.Name,
+		})
+	}
+	brief["teams"] = teams
+
+	return brief
+}
+
+// OnGameEvent registers a hook called after a game event is created or published,
+// hooks should be registered before serving and should not block
+func (s *Store) OnGameEvent(hook func(*GameEvent)) {
+	s.eventHooks = append(s.eventHooks, hook)
 }
 
 func (s *Store) CreateGameEvent(event *GameEvent) error {
-	// TODO: use websocket to broadcast the event
-	// TODO: use webhook to notify the event
-	return s.db.Create(event).Error
+	if err := s.db.Create(event).Error; err != nil {
+		return err
+	}
+
+	s.runEventHooks(event)
+	return nil
+}
+
+// PublishGameEvent makes a hidden event visible and passes it to the hooks as a new one,
+// the event should be loaded with its challenge and related teams
+func (s *Store) PublishGameEvent(event *GameEvent) error {
+	if event.Visibility {
+		return nil
+	}
+
+	event.Visibility = true
+	if err := s.UpdateGameEventVisibility(event); err != nil {
+		event.Visibility = false
+		return err
+	}
+
+	s.runEventHooks(event)
+	return nil
+}
+
+func (s *Store) runEventHooks(event *GameEvent) {
+	for _, hook := range s.eventHooks {
+		hook(event)
+	}
 }
 
 func (s *Store) UpdateGameEvent(event *GameEvent) error {
 	return s.db.Save(event).Error
 }
 
+// UpdateGameEventVisibility updates only the visibility of the event
+func (s *Store) UpdateGameEventVisibility(event *GameEvent) error {
+	return s.db.Model(&GameEvent{}).Where("id = ?", event.ID).Update("visibility", event.Visibility).Error
+}
+
+// ShowGameEvents makes the hidden events of the type in the game created since the time (in milliseconds)
+// visible without running the hooks
+func (s *Store) ShowGameEvents(game *Game, t EventType, since int64) error {
+	return s.db.Model(&GameEvent{}).
+		Where("game_id = ? AND type = ? AND visibility = ? AND created_at >= ?", game.ID, t, false, time.UnixMilli(since)).
+		Update("visibility", true).Error
+}
+
 func (s *Store) DeleteGameEvent(event *GameEvent) error {
 	return s.db.Delete(event).Error
 }
 
-func (s *Store) GetAllGameEvents(visibility bool) ([]*GameEvent, error) {
+// GameEventFilter filters the events of a game, zero values match all
+type GameEventFilter struct {
+	Types       []EventType
+	ChallengeID uint
+	TeamID      uint
+
+	// only the events visible to the normal players
+	VisibleOnly bool
+}
+
+// GetGameEvents returns the events of the game matching the filter, latest first
+func (s *Store) GetGameEvents(game *Game, filter GameEventFilter, offset int, limit int) ([]*GameEvent, int64, error) {
 	var events []*GameEvent
-	err := s.db.Where("visibility = ?", visibility).Find(&events).Error
-	return events, err
+	var total int64
+
+	query := s.db.Model(&GameEvent{}).Where("game_id = ?", game.ID)
+	if filter.VisibleOnly {
+		query = query.Where("visibility = ?", true)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if filter.ChallengeID != 0 {
+		query = query.Where("challenge_id = ?", filter.ChallengeID)
+	}
+	if filter.TeamID != 0 {
+		query = query.Where("id IN (?)", s.db.Table("event_teams").Select("game_event_id").Where("team_id = ?", filter.TeamID))
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Challenge").Preload("RelatedTeams").Order("id DESC").Offset(offset).Limit(limit).Find(&events).Error
+	return events, total, err
+}
+
+func (s *Store) GetGameEventByID(id uint) (*GameEvent, error) {
+	var event GameEvent
+	err := s.db.Preload("Challenge").Preload("RelatedTeams").First(&event, id).Error
+	return &event, err
 }
@@ -14,7 +14,19 @@
 
 package store
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlclark/regexp2"
+	"gorm.io/gorm"
+)
+
+// FlagRegexTimeout bounds the time of matching a submitted flag against a pattern,
+// so that a catastrophic pattern can't hang the submissions
+const FlagRegexTimeout = 100 * time.Millisecond
 
 type FlagState int
 
@@ -24,6 +36,74 @@ const (
 	FlagCheated
 )
 
+// FlagMatch is how a submitted flag is compared with the flag of the challenge
+type FlagMatch int
+
+const (
+	FlagMatchExact FlagMatch = iota
+	FlagMatchCaseInsensitive
+	FlagMatchTrimmed
+	FlagMatchRegex
+)
+
+// Validate checks the mode and the flag, the flag should be a valid pattern in regex mode
+func (m FlagMatch) Validate(flag string) error {
+	switch m {
+	case FlagMatchExact, FlagMatchCaseInsensitive, FlagMatchTrimmed:
+		return nil
+	case FlagMatchRegex:
+		_, err := compileFlagRegex(flag)
+		return err
+	default:
+		return errors.New("unknown flag match mode")
+	}
+}
+
+// Match reports whether the submitted flag matches the flag in the mode
+func (m FlagMatch) Match(flag string, submitted string) bool {
+	switch m {
+	case FlagMatchCaseInsensitive:
+		return strings.EqualFold(flag, submitted)
+	case FlagMatchTrimmed:
+		return strings.TrimSpace(flag) == strings.TrimSpace(submitted)
+	case FlagMatchRegex:
+		re, err := compileFlagRegex(flag)
+		if err != nil {
+			return false
+		}
+		// a timed out match is a mismatch
+		ok, err := re.MatchString(submitted)
+		return err == nil && ok
+	default:
+		return flag == submitted
+	}
+}
+
+// flagRegexes caches the compiled patterns of the regex flags, keyed by the pattern
+var flagRegexes sync.Map
+
+// compileFlagRegex compiles the pattern of a regex flag, the whole submitted flag should match the pattern
+func compileFlagRegex(pattern string) (*regexp2.Regexp, error) {
+	if re, ok := flagRegexes.Load(pattern); ok {
+		return re.(*regexp2.Regexp), nil
+	}
+
+	// the pattern should be valid on its own, or it could close the anchoring group
+	// like `x)|(.*` and match anything
+	if _, err := regexp2.Compile(pattern, regexp2.None); err != nil {
+		return nil, err
+	}
+
+	re, err := regexp2.Compile(`^(?:`+pattern+`)\z`, regexp2.None)
+	if err != nil {
+		return nil, err
+	}
+
+	re.MatchTimeout = FlagRegexTimeout
+	flagRegexes.Store(pattern, re)
+	return re, nil
+}
+
 type Flag struct {
 	gorm.Model
 
@@ -51,6 +131,10 @@ type Flag struct {
 	// The team that should submit this flag
 	TeamID uint
 	Team   *Team `gorm:"foreignKey:TeamID"`
+
+	// The user who submitted this flag, 0 if not submitted yet
+	SubmitterID uint
+	Submitter   *User `gorm:"foreignKey:SubmitterID"`
 }
 
 func (s *Store) CreateFlag(flag *Flag) error {
@@ -94,3 +178,17 @@ func (s *Store) GetSolvedFlagsByChallenge(challenge *Challenge) ([]*Flag, error)
 	err := s.db.Preload("Team").Preload("Challenge").Where("challenge_id = ? AND state >= 1", challenge.ID).Order("solved_at ASC").Find(&flags).Error
 	return flags, err
 }
+
+// GetSolvedFlagsByGame returns the solved flags of all the challenges in the game
+func (s *Store) GetSolvedFlagsByGame(game *Game) ([]*Flag, error) {
+	var flags []*Flag
+	err := s.db.Preload("Team").Preload("Challenge").
+		Where("state >= 1 AND challenge_id IN (?)", s.db.Model(&Challenge{}).Select("id").Where("game_id = ?", game.ID)).
+		Order("solved_at ASC").Find(&flags).Error
+	return flags, err
+}
+
+// UpdateFlagScore updates only the score of the flag
+func (s *Store) UpdateFlagScore(flag *Flag) error {
+	return s.db.Model(&Flag{}).Where("id = ?", flag.ID).Update("score", flag.Score).Error
+}
@@ -0,0 +1,57 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlagMatch(t *testing.T) {
+	assert.True(t, FlagMatchExact.Match("flag{a}", "flag{a}"))
+	assert.False(t, FlagMatchExact.Match("flag{a}", "FLAG{a}"))
+	assert.False(t, FlagMatchExact.Match("flag{a}", " flag{a}"))
+
+	assert.True(t, FlagMatchCaseInsensitive.Match("flag{a}", "FLAG{A}"))
+	assert.False(t, FlagMatchCaseInsensitive.Match("flag{a}", "flag{b}"))
+
+	assert.True(t, FlagMatchTrimmed.Match("flag{a}", " flag{a}\n"))
+	assert.False(t, FlagMatchTrimmed.Match("flag{a}", "flag{ a }"))
+
+	assert.True(t, FlagMatchRegex.Match(`flag\{[0-9]+\}`, "flag{42}"))
+	assert.False(t, FlagMatchRegex.Match(`flag\{[0-9]+\}`, "flag{42}x"), "the whole flag should match")
+	assert.False(t, FlagMatchRegex.Match(`flag\{[0-9]+\}`, "xflag{42}"))
+}
+
+func TestFlagMatchValidate(t *testing.T) {
+	assert.NoError(t, FlagMatchExact.Validate("flag{(}"))
+	assert.NoError(t, FlagMatchRegex.Validate(`flag\{x\}|flag\{y\}`))
+	assert.Error(t, FlagMatchRegex.Validate("flag{("))
+	assert.Error(t, FlagMatch(42).Validate("flag"))
+
+	// the pattern can't escape the anchoring group
+	escaping := `flag\{x\})|(.*`
+	assert.Error(t, FlagMatchRegex.Validate(escaping))
+	assert.False(t, FlagMatchRegex.Match(escaping, "anything"))
+}
+
+func TestFlagMatchTimeout(t *testing.T) {
+	start := time.Now()
+	assert.False(t, FlagMatchRegex.Match(`(a+)+b`, strings.Repeat("a", 64)), "a timed out match is a mismatch")
+	assert.Less(t, time.Since(start), 10*FlagRegexTimeout)
+}
@@ -15,9 +15,16 @@
 package store
 
 import (
+	"io"
+	"os"
+	"path/filepath"
 	"slices"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rina.icu/hoshino/store/types"
 )
 
 type GameStatus int
@@ -44,7 +51,7 @@ type Game struct {
 	Status GameStatus `gorm:"default:0" json:"status"`
 
 	// Visibility of the game
-	Visibility bool `gorm:"default:true" json:"visibility"`
+	Visibility bool `json:"visibility"`
 
 	// Start time of the game
 	StartTime int64 `gorm:"default:0" json:"start_time"`
@@ -73,6 +80,29 @@ type Game struct {
 
 	// Auto ban the team when cheating
 	AutoBan bool `gorm:"default:false" json:"auto_ban" priv:"2"`
+
+	// Freeze time of the scoreboard, 0 means never freeze
+	// Solves after this time are hidden from the players until revealed
+	FreezeTime int64 `gorm:"default:0" json:"freeze_time"`
+
+	// Is the frozen scoreboard revealed
+	Revealed bool `gorm:"default:false" json:"revealed"`
+
+	// Teams need to be approved by the managers before playing
+	RequireApproval bool `gorm:"default:false" json:"require_approval"`
+
+	// Questions answered by the teams when registering
+	Questions types.StringArray `gorm:"type:text" json:"questions"`
+
+	// Rules accepted by the teams when registering
+	// Markdown supported
+	Rules string `gorm:"type:text" json:"rules"`
+
+	// Deadline of the writeups, 0 means until the game ends
+	WriteupDeadline int64 `gorm:"default:0" json:"writeup_deadline"`
+
+	// Teams need an approved writeup to stay ranked after the deadline
+	RequireWriteup bool `gorm:"default:false" json:"require_writeup"`
 }
 
 func (s *Store) CreateGame(game *Game) error {
@@ -83,20 +113,270 @@ func (s *Store) UpdateGame(game *Game) error {
 	return s.db.Save(game).Error
 }
 
+// RevealGame marks the frozen scoreboard of the game as revealed,
+// returns false if it has been revealed already
+func (s *Store) RevealGame(game *Game) (bool, error) {
+	result := s.db.Model(game).Where("revealed = ?", false).Update("revealed", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	game.Revealed = true
+	return true, nil
+}
+
+// DeleteGame soft deletes the game and its challenges
+func (s *Store) DeleteGame(game *Game) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("game_id = ?", game.ID).Delete(&Challenge{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(game).Error
+	})
+}
+
+func (s *Store) AddGameManager(game *Game, user *User) error {
+	return s.db.Model(game).Association("Managers").Append(user)
+}
+
+func (s *Store) RemoveGameManager(game *Game, user *User) error {
+	return s.db.Model(game).Association("Managers").Delete(user)
+}
+
+// CloneGame deep copies the challenges, hints, sub flags, images and attachments of the game into the new game,
+// the attachment files are copied as well
+func (s *Store) CloneGame(game *Game, clone *Game) error {
+	var challenges []*Challenge
+	if err := s.db.Preload("Image").Preload("Hints").Preload("SubFlags").Where("game_id = ?", game.ID).Find(&challenges).Error; err != nil {
+		return err
+	}
+
+	// new UUIDs are assigned up front so that the prerequisites can refer to the cloned challenges
+	uuids := make(map[string]string, len(challenges))
+	for _, challenge := range challenges {
+		uuids[challenge.UUID] = uuid.New().String()
+	}
+
+	copied := []string{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(clone).Error; err != nil {
+			return err
+		}
+
+		for _, challenge := range challenges {
+			var attachments []*Attachment
+			if err := tx.Where("challenge_id = ?", challenge.ID).Find(&attachments).Error; err != nil {
+				return err
+			}
+
+			c := *challenge
+			c.Model = gorm.Model{}
+			c.UUID = uuids[challenge.UUID]
+			c.GameID = clone.ID
+			c.Game = nil
+			c.Creator = nil
+			c.FlagSecret = newFlagSecret()
+
+			// the draft starts over, the visible challenges are staged again for the scheduler
+			c.ReleasedAt = 0
+			c.ClosedAt = 0
+			if c.State != ChallengeStateDisabled {
+				c.State = ChallengeStateHidden
+			}
+
+			c.Requires = make(types.StringArray, 0, len(challenge.Requires))
+			for _, required := range challenge.Requires {
+				if u, ok := uuids[required]; ok {
+					c.Requires = append(c.Requires, u)
+				}
+			}
+
+			c.Hints = make([]*Hint, 0, len(challenge.Hints))
+			for _, hint := range challenge.Hints {
+				h := *hint
+				h.Model = gorm.Model{}
+				h.UUID = uuid.New().String()
+				h.ChallengeID = 0
+				c.Hints = append(c.Hints, &h)
+			}
+
+			c.SubFlags = make([]*SubFlag, 0, len(challenge.SubFlags))
+			for _, flag := range challenge.SubFlags {
+				f := *flag
+				f.Model = gorm.Model{}
+				f.UUID = uuid.New().String()
+				f.ChallengeID = 0
+				c.SubFlags = append(c.SubFlags, &f)
+			}
+
+			if challenge.Image != nil {
+				image := *challenge.Image
+				image.Model = gorm.Model{}
+				c.Image = &image
+			}
+
+			if err := tx.Create(&c).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(clone).Association("Challenges").Append(&c); err != nil {
+				return err
+			}
+
+			for _, attachment := range attachments {
+				a := *attachment
+				a.Model = gorm.Model{}
+				a.UUID = uuid.New().String()
+				a.ChallengeID = c.ID
+				a.Challenge = nil
+				a.SavePath = filepath.Join(filepath.Dir(attachment.SavePath), a.UUID)
+
+				if err := copyFile(attachment.SavePath, a.SavePath); err != nil {
+					return err
+				}
+				copied = append(copied, a.SavePath)
+
+				if err := tx.Create(&a).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		// clean up the copied files
+		for _, path := range copied {
+			os.Remove(path)
+		}
+	}
+
+	return err
+}
+
+// GameRecords are the records created along with the game by ImportGame
+type GameRecords struct {
+	Challenges []*Challenge
+
+	// attachments keyed by the UUIDs of their challenges
+	Attachments map[string][]*Attachment
+
+	Teams []*Team
+
+	// solves of the teams, referring to their challenges and teams by the pointers
+	Flags []*Flag
+}
+
+// ImportGame creates the game with the records in a transaction
+func (s *Store) ImportGame(game *Game, records *GameRecords) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(game).Error; err != nil {
+			return err
+		}
+
+		for _, challenge := range records.Challenges {
+			challenge.GameID = game.ID
+			if challenge.FlagSecret == "" {
+				challenge.FlagSecret = newFlagSecret()
+			}
+			if err := tx.Create(challenge).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Model(game).Association("Challenges").Append(challenge); err != nil {
+				return err
+			}
+
+			for _, attachment := range records.Attachments[challenge.UUID] {
+				attachment.ChallengeID = challenge.ID
+				if err := tx.Create(attachment).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, team := range records.Teams {
+			team.GameID = game.ID
+			if err := tx.Create(team).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, flag := range records.Flags {
+			flag.ChallengeID = flag.Challenge.ID
+			flag.TeamID = flag.Team.ID
+			if err := tx.Omit(clause.Associations).Create(flag).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
 func (s *Store) GetGames() ([]*Game, error) {
 	var games []*Game
-	err := s.db.Preload("Creator").Preload("Managers").Preload("Challenges").Preload("Challenges.Creator").Find(&games).Error
+	err := s.db.Preload("Creator").Preload("Managers").Preload("Challenges", orderChallenges).Preload("Challenges.Creator").Find(&games).Error
 	return games, err
 }
 
 func (s *Store) GetGameByUUID(uuid string) (*Game, error) {
 	var game Game
-	err := s.db.Preload("Creator").Preload("Managers").Preload("Challenges").Preload("Challenges.Creator").Where("uuid = ?", uuid).First(&game).Error
+	err := s.db.Preload("Creator").Preload("Managers").Preload("Challenges", orderChallenges).Preload("Challenges.Creator").Where("uuid = ?", uuid).First(&game).Error
 	return &game, err
 }
 
 func (g Game) IsManager(user *User) bool {
-	return slices.Contains(g.Managers, user)
+	return slices.ContainsFunc(g.Managers, func(manager *User) bool {
+		return manager.ID == user.ID
+	})
+}
+
+// writeupDue returns the deadline of the writeups, 0 if there's none
+func (g *Game) writeupDue() int64 {
+	if g.WriteupDeadline != 0 {
+		return g.WriteupDeadline
+	}
+	return g.EndTime
+}
+
+// WriteupOpen reports whether the teams can submit writeups now
+func (g *Game) WriteupOpen() bool {
+	due := g.writeupDue()
+	return due == 0 || time.Now().UnixMilli() <= due
+}
+
+// WriteupEnforced reports whether the teams without an approved writeup are unranked now
+func (g *Game) WriteupEnforced() bool {
+	due := g.writeupDue()
+	return g.RequireWriteup && due != 0 && due < time.Now().UnixMilli()
+}
+
+// Frozen reports whether the public scoreboard is frozen now
+func (g *Game) Frozen() bool {
+	return g.FreezeTime != 0 && !g.Revealed && g.FreezeTime < time.Now().UnixMilli()
+}
+
+func orderChallenges(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC, id ASC")
 }
 
 func (g *Game) GetChallenges(withInvisible bool) []*Challenge {
@@ -0,0 +1,98 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"rina.icu/hoshino/server/config"
+)
+
+func TestCloneFinishedGame(t *testing.T) {
+	s, err := GetStore(&config.Config{Driver: "sqlite", DataDir: t.TempDir()})
+	assert.NoError(t, err)
+
+	game := &Game{UUID: "game", Name: "game"}
+	assert.NoError(t, s.CreateGame(game))
+
+	// released and closed by the scheduler during the game
+	now := time.Now().UnixMilli()
+	assert.NoError(t, s.CreateChallenge(&Challenge{
+		UUID:       "released",
+		Name:       "released",
+		Game:       game,
+		State:      ChallengeStateVisible,
+		StartTime:  now - 2000,
+		ExpireTime: now - 1000,
+		ReleasedAt: now - 2000,
+		ClosedAt:   now - 1000,
+	}))
+	assert.NoError(t, s.CreateChallenge(&Challenge{UUID: "disabled", Name: "disabled", Game: game}))
+
+	clone := &Game{UUID: "clone", Name: "clone"}
+	assert.NoError(t, s.CloneGame(game, clone))
+
+	clone, err = s.GetGameByUUID("clone")
+	assert.NoError(t, err)
+	challenges := clone.GetChallenges(true)
+	assert.Len(t, challenges, 2)
+
+	states := map[string]ChallengeState{}
+	for _, challenge := range challenges {
+		states[challenge.Name] = challenge.State
+		assert.Zero(t, challenge.ReleasedAt)
+		assert.Zero(t, challenge.ClosedAt)
+	}
+	assert.Equal(t, ChallengeStateHidden, states["released"])
+	assert.Equal(t, ChallengeStateDisabled, states["disabled"])
+
+	// the managers schedule the draft again
+	var released *Challenge
+	for _, challenge := range challenges {
+		if challenge.Name == "released" {
+			released = challenge
+		}
+	}
+	released.StartTime = released.CreatedAt.UnixMilli() + 1000
+	assert.NoError(t, s.UpdateChallenge(released))
+
+	toRelease, err := s.GetChallengesToRelease(released.StartTime)
+	assert.NoError(t, err)
+	assert.Len(t, toRelease, 1)
+	assert.Equal(t, released.UUID, toRelease[0].UUID)
+	assert.True(t, toRelease[0].Staged(toRelease[0].StartTime))
+}
+
+func TestRevealGame(t *testing.T) {
+	s, err := GetStore(&config.Config{Driver: "sqlite", DataDir: t.TempDir()})
+	assert.NoError(t, err)
+
+	game := &Game{UUID: "game", Name: "game", FreezeTime: time.Now().UnixMilli()}
+	assert.NoError(t, s.CreateGame(game))
+
+	// a retry with the game fetched before the reveal
+	stale := *game
+
+	revealed, err := s.RevealGame(game)
+	assert.NoError(t, err)
+	assert.True(t, revealed)
+	assert.True(t, game.Revealed)
+
+	revealed, err = s.RevealGame(&stale)
+	assert.NoError(t, err)
+	assert.False(t, revealed)
+}
@@ -0,0 +1,172 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Hint of a challenge
+type Hint struct {
+	gorm.Model `json:"-"`
+
+	UUID string `gorm:"unique" json:"uuid"`
+
+	// Challenge of the hint
+	ChallengeID uint       `gorm:"index" json:"-"`
+	Challenge   *Challenge `gorm:"foreignKey:ChallengeID" json:"-"`
+
+	// Content of the hint
+	// Markdown supported
+	Content string `gorm:"type:text" json:"content"`
+
+	// Score deducted from the team when unlocking the hint, free if 0
+	Cost int `gorm:"default:0" json:"cost"`
+
+	// The hint is hidden from the players before the release time, 0 for released
+	ReleaseTime int64 `gorm:"default:0" json:"release_time"`
+
+	// Is the hint unlocked by the team of the requesting user, not persisted
+	Unlocked bool `gorm:"-" json:"unlocked"`
+}
+
+// HintUnlock records a hint unlocked by a team
+type HintUnlock struct {
+	gorm.Model `json:"-"`
+
+	// The unlocked hint
+	HintID uint  `gorm:"uniqueIndex:idx_hint_team" json:"-"`
+	Hint   *Hint `gorm:"foreignKey:HintID" json:"-"`
+
+	// The team which unlocked the hint
+	TeamID uint  `gorm:"uniqueIndex:idx_hint_team" json:"-"`
+	Team   *Team `gorm:"foreignKey:TeamID" json:"-"`
+
+	// The member who unlocked the hint
+	UserID uint  `json:"-"`
+	User   *User `gorm:"foreignKey:UserID" json:"-"`
+
+	// Score deducted from the team, the cost of the hint when it was unlocked
+	Cost int `json:"cost"`
+
+	UnlockedAt int64 `json:"unlocked_at"`
+}
+
+func (h *Hint) Released(now int64) bool {
+	return h.ReleaseTime == 0 || h.ReleaseTime <= now
+}
+
+func (s *Store) CreateHint(hint *Hint) error {
+	return s.db.Omit(clause.Associations).Create(hint).Error
+}
+
+func (s *Store) UpdateHint(hint *Hint) error {
+	return s.db.Omit(clause.Associations).Save(hint).Error
+}
+
+func (s *Store) DeleteHint(hint *Hint) error {
+	return s.db.Delete(hint).Error
+}
+
+func (s *Store) GetHintByUUID(uuid string) (*Hint, error) {
+	var hint Hint
+	err := s.db.Preload("Challenge").Preload("Challenge.Game").Where("uuid = ?", uuid).First(&hint).Error
+	return &hint, err
+}
+
+// GetHintsByChallenge returns the hints of the challenge sorted by the release time
+func (s *Store) GetHintsByChallenge(challenge *Challenge) ([]*Hint, error) {
+	var hints []*Hint
+	err := s.db.Where("challenge_id = ?", challenge.ID).Order("release_time ASC, id ASC").Find(&hints).Error
+	return hints, err
+}
+
+// GetHintsByGame returns the hints of all the challenges in the game sorted by the release time
+func (s *Store) GetHintsByGame(game *Game) ([]*Hint, error) {
+	var hints []*Hint
+	err := s.db.Where("challenge_id IN (?)", s.db.Model(&Challenge{}).Select("id").Where("game_id = ?", game.ID)).
+		Order("release_time ASC, id ASC").Find(&hints).Error
+	return hints, err
+}
+
+// CreateHintUnlock records the unlock, fails if the team has unlocked the hint
+func (s *Store) CreateHintUnlock(unlock *HintUnlock) error {
+	return s.db.Omit(clause.Associations).Create(unlock).Error
+}
+
+// GetUnlockedHintIDs returns the IDs of the hints unlocked by the team
+func (s *Store) GetUnlockedHintIDs(team *Team) ([]uint, error) {
+	var ids []uint
+	err := s.db.Model(&HintUnlock{}).Where("team_id = ?", team.ID).Pluck("hint_id", &ids).Error
+	return ids, err
+}
+
+// GetHintUnlocksByGame returns the unlocks of the existing hints in the game
+func (s *Store) GetHintUnlocksByGame(game *Game) ([]*HintUnlock, error) {
+	var unlocks []*HintUnlock
+	err := s.db.Preload("Team").
+		Where("hint_id IN (?)", s.db.Model(&Hint{}).Select("id").
+			Where("challenge_id IN (?)", s.db.Model(&Challenge{}).Select("id").Where("game_id = ?", game.ID))).
+		Order("unlocked_at ASC").Find(&unlocks).Error
+	return unlocks, err
+}
+
+// migrateHints converts the hints stored as a string array of the challenges into free hints
+func (s *Store) migrateHints() {
+	if !s.db.Migrator().HasColumn("challenges", "hints") {
+		return
+	}
+
+	var rows []struct {
+		ID    uint
+		Hints *string
+	}
+	if err := s.db.Table("challenges").Select("id, hints").Scan(&rows).Error; err != nil {
+		slog.Error(fmt.Sprintf("Failed to read the hints of the challenges: %s", err.Error()))
+		return
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			if row.Hints == nil {
+				continue
+			}
+
+			var contents []string
+			if err := json.Unmarshal([]byte(*row.Hints), &contents); err != nil {
+				continue
+			}
+
+			for _, content := range contents {
+				hint := &Hint{UUID: uuid.New().String(), ChallengeID: row.ID, Content: content}
+				if err := tx.Create(hint).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Exec("ALTER TABLE challenges DROP COLUMN hints").Error
+	})
+
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to migrate the hints of the challenges: %s", err.Error()))
+	}
+}
@@ -13,3 +13,169 @@
 // limitations under the License.
 
 package store
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Announcement posted by the managers of a game
+type Announcement struct {
+	gorm.Model `json:"-"`
+
+	UUID string `gorm:"unique" json:"uuid"`
+
+	// Game of the announcement
+	GameID uint  `json:"-"`
+	Game   *Game `gorm:"foreignKey:GameID" json:"-"`
+
+	// Title of the announcement
+	Title string `json:"title"`
+
+	// Content of the announcement
+	// Markdown supported
+	Content string `gorm:"type:text" json:"content"`
+
+	// Author of the announcement
+	AuthorID uint  `json:"-"`
+	Author   *User `gorm:"foreignKey:AuthorID" json:"author"`
+
+	// Time when the announcement was posted
+	PostedAt int64 `json:"posted_at"`
+}
+
+type NotificationType int
+
+const (
+	NotificationTypeSystem NotificationType = iota
+	NotificationTypeAnnouncement
+	NotificationTypeContainerExpired
+	NotificationTypeTeamBanned
+)
+
+// Notification in the inbox of a user
+type Notification struct {
+	gorm.Model `json:"-"`
+
+	UUID string `gorm:"unique" json:"uuid"`
+
+	// Receiver of the notification
+	UserID uint  `gorm:"index" json:"-"`
+	User   *User `gorm:"foreignKey:UserID" json:"-"`
+
+	// Related game of the notification, 0 if none
+	GameID uint `json:"-"`
+
+	// UUID of the related game, empty if none
+	GameUUID string `json:"game_uuid"`
+
+	Type NotificationType `gorm:"default:0" json:"type"`
+
+	Title string `json:"title"`
+
+	// Markdown supported
+	Content string `gorm:"type:text" json:"content"`
+
+	// "read" is reserved in some databases
+	Read bool `gorm:"column:is_read;default:false" json:"read"`
+
+	// Time when the notification was created
+	CreatedTime int64 `json:"created_time"`
+}
+
+func (s *Store) CreateAnnouncement(announcement *Announcement) error {
+	return s.db.Create(announcement).Error
+}
+
+func (s *Store) GetAnnouncementsByGame(game *Game) ([]*Announcement, error) {
+	var announcements []*Announcement
+	err := s.db.Preload("Author").Where("game_id = ?", game.ID).Order("posted_at DESC").Find(&announcements).Error
+	return announcements, err
+}
+
+func (s *Store) GetAnnouncementByUUID(uuid string) (*Announcement, error) {
+	var announcement Announcement
+	err := s.db.Where("uuid = ?", uuid).First(&announcement).Error
+	return &announcement, err
+}
+
+func (s *Store) DeleteAnnouncement(announcement *Announcement) error {
+	return s.db.Delete(announcement).Error
+}
+
+func (s *Store) CreateNotifications(notifications []*Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return s.db.CreateInBatches(notifications, 100).Error
+}
+
+// NotifyUsers sends a copy of the notification to each user
+func (s *Store) NotifyUsers(users []*User, notification Notification) error {
+	notifications := make([]*Notification, 0, len(users))
+	for _, user := range users {
+		n := notification
+		n.UUID = uuid.New().String()
+		n.UserID = user.ID
+		n.CreatedTime = time.Now().UnixMilli()
+		notifications = append(notifications, &n)
+	}
+	return s.CreateNotifications(notifications)
+}
+
+// NotifyTeam sends the notification to all the members of the team
+func (s *Store) NotifyTeam(team *Team, notification Notification) error {
+	members, err := team.GetMembers(s.db)
+	if err != nil {
+		return err
+	}
+
+	users := make([]*User, 0, len(members))
+	for i := range members {
+		users = append(users, &members[i])
+	}
+	return s.NotifyUsers(users, notification)
+}
+
+// NotifyGame sends the notification to all the players of the game
+func (s *Store) NotifyGame(game *Game, notification Notification) error {
+	users := []*User{}
+	for _, team := range game.GetTeams(s) {
+		users = append(users, team.Members...)
+	}
+
+	notification.GameID = game.ID
+	notification.GameUUID = game.UUID
+	return s.NotifyUsers(users, notification)
+}
+
+func (s *Store) GetNotificationsByUser(user *User, unreadOnly bool) ([]*Notification, error) {
+	var notifications []*Notification
+	query := s.db.Where("user_id = ?", user.ID)
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	err := query.Order("id DESC").Find(&notifications).Error
+	return notifications, err
+}
+
+func (s *Store) GetNotificationByUUID(uuid string) (*Notification, error) {
+	var notification Notification
+	err := s.db.Where("uuid = ?", uuid).First(&notification).Error
+	return &notification, err
+}
+
+func (s *Store) UpdateNotification(notification *Notification) error {
+	return s.db.Save(notification).Error
+}
+
+// MarkAllNotificationsRead marks all the notifications of the user as read
+func (s *Store) MarkAllNotificationsRead(user *User) error {
+	return s.db.Model(&Notification{}).Where("user_id = ? AND is_read = ?", user.ID, false).Update("is_read", true).Error
+}
+
+func (s *Store) DeleteNotification(notification *Notification) error {
+	return s.db.Delete(notification).Error
+}
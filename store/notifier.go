@@ -0,0 +1,107 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+	"rina.icu/hoshino/store/types"
+)
+
+type NotifierPlatform string
+
+const (
+	NotifierPlatformDiscord  NotifierPlatform = "discord"
+	NotifierPlatformSlack    NotifierPlatform = "slack"
+	NotifierPlatformTelegram NotifierPlatform = "telegram"
+	NotifierPlatformLark     NotifierPlatform = "lark"
+)
+
+// Notifier posting the events of a game into a chat
+type Notifier struct {
+	gorm.Model `json:"-"`
+
+	UUID string `gorm:"unique" json:"uuid"`
+
+	// Game of the notifier
+	GameID uint  `gorm:"index" json:"-"`
+	Game   *Game `gorm:"foreignKey:GameID" json:"-"`
+
+	Name     string           `json:"name"`
+	Platform NotifierPlatform `json:"platform"`
+
+	// Incoming webhook URL of Discord, Slack and Lark
+	URL string `json:"url"`
+
+	// Bot token and chat ID of Telegram
+	Token  string `json:"token"`
+	ChatID string `json:"chat_id"`
+
+	// Signing secret of Lark, optional
+	Secret string `json:"secret"`
+
+	// Names of the events to post, empty for all
+	Events types.StringArray `gorm:"type:text" json:"events"`
+
+	// Message templates keyed by the event name, the default is used if missing
+	Templates types.StringMap `gorm:"type:text" json:"templates"`
+
+	Enabled bool `json:"enabled"`
+}
+
+// Accepts reports whether any of the names of the event is in the filter
+func (n *Notifier) Accepts(names ...string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+
+	for _, e := range n.Events {
+		if slices.Contains(names, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CreateNotifier(notifier *Notifier) error {
+	return s.db.Create(notifier).Error
+}
+
+func (s *Store) UpdateNotifier(notifier *Notifier) error {
+	return s.db.Save(notifier).Error
+}
+
+func (s *Store) DeleteNotifier(notifier *Notifier) error {
+	return s.db.Delete(notifier).Error
+}
+
+func (s *Store) GetNotifierByUUID(uuid string) (*Notifier, error) {
+	var notifier Notifier
+	err := s.db.Where("uuid = ?", uuid).First(&notifier).Error
+	return &notifier, err
+}
+
+func (s *Store) GetNotifiersByGame(game *Game) ([]*Notifier, error) {
+	var notifiers []*Notifier
+	err := s.db.Where("game_id = ?", game.ID).Find(&notifiers).Error
+	return notifiers, err
+}
+
+func (s *Store) GetEnabledNotifiersByGameID(gameID uint) ([]*Notifier, error) {
+	var notifiers []*Notifier
+	err := s.db.Preload("Game").Where("game_id = ? AND enabled = ?", gameID, true).Find(&notifiers).Error
+	return notifiers, err
+}
@@ -28,6 +28,9 @@ import (
 type Store struct {
 	config *config.Config
 	db     *gorm.DB
+
+	// hooks called after a game event is created
+	eventHooks []func(*GameEvent)
 }
 
 func (s *Store) initSetting() {
@@ -52,7 +55,19 @@ func (s *Store) migrate() {
 		&GameEvent{},
 		&Team{},
 		&Image{},
+		&Announcement{},
+		&Notification{},
+		&Webhook{},
+		&WebhookDelivery{},
+		&Notifier{},
+		&Writeup{},
+		&Hint{},
+		&HintUnlock{},
+		&SubFlag{},
+		&SubFlagSolve{},
 	)
+
+	s.migrateHints()
 }
 
 func (u *User) UserPriv(s *Store) func(reflect.Type, reflect.Value) int {
@@ -177,7 +192,7 @@ func GetStore(c *config.Config) (*Store, error) {
 		err = errors.New("Unrecognizable database driver " + c.Driver)
 	}
 
-	store := &Store{c, db}
+	store := &Store{config: c, db: db}
 
 	store.migrate()
 
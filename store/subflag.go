@@ -0,0 +1,154 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SubFlag is a named part of a multi-part challenge, found independently for a share of the score.
+// The challenge is solved when all of its sub flags are found
+type SubFlag struct {
+	gorm.Model `json:"-"`
+
+	UUID string `gorm:"unique" json:"uuid"`
+
+	// Challenge of the sub flag
+	ChallengeID uint       `gorm:"index" json:"-"`
+	Challenge   *Challenge `gorm:"foreignKey:ChallengeID" json:"-"`
+
+	// Name of the part, shown to the players
+	Name string `json:"name"`
+
+	// Flag of the part, cleared by the handlers for the players
+	Flag string `json:"flag"`
+
+	// How the submitted flags are compared with the flag of the part, cleared for the players
+	Match FlagMatch `gorm:"default:0" json:"match"`
+
+	// Share of the challenge score in percent, the weights of the sub flags add up to 100
+	Weight int `json:"weight"`
+
+	// Is the sub flag found by the team of the requesting user, not persisted
+	Found bool `gorm:"-" json:"found"`
+}
+
+// SubFlagSolve records a sub flag found by a team
+type SubFlagSolve struct {
+	gorm.Model `json:"-"`
+
+	// The found sub flag
+	SubFlagID uint     `gorm:"uniqueIndex:idx_sub_flag_team" json:"-"`
+	SubFlag   *SubFlag `gorm:"foreignKey:SubFlagID" json:"-"`
+
+	// The challenge of the sub flag
+	ChallengeID uint       `gorm:"index" json:"-"`
+	Challenge   *Challenge `gorm:"foreignKey:ChallengeID" json:"-"`
+
+	// The team which found the sub flag
+	TeamID uint  `gorm:"uniqueIndex:idx_sub_flag_team" json:"-"`
+	Team   *Team `gorm:"foreignKey:TeamID" json:"-"`
+
+	// The member who submitted the sub flag
+	SubmitterID uint  `json:"-"`
+	Submitter   *User `gorm:"foreignKey:SubmitterID" json:"-"`
+
+	// Share of the challenge score, the weight of the sub flag
+	Weight int `json:"weight"`
+
+	SolvedAt int64 `json:"solved_at"`
+}
+
+// GetSubFlagsByChallenge returns the sub flags of the challenge in the creation order
+func (s *Store) GetSubFlagsByChallenge(challenge *Challenge) ([]*SubFlag, error) {
+	var flags []*SubFlag
+	err := s.db.Where("challenge_id = ?", challenge.ID).Order("id ASC").Find(&flags).Error
+	return flags, err
+}
+
+// GetSubFlagsByGame returns the sub flags of all the challenges in the game
+func (s *Store) GetSubFlagsByGame(game *Game) ([]*SubFlag, error) {
+	var flags []*SubFlag
+	err := s.db.Where("challenge_id IN (?)", s.db.Model(&Challenge{}).Select("id").Where("game_id = ?", game.ID)).
+		Order("id ASC").Find(&flags).Error
+	return flags, err
+}
+
+// SetSubFlags replaces the sub flags of the challenge, the existing ones are matched by the name
+// and keep their solves, the solves of the removed ones are deleted
+func (s *Store) SetSubFlags(challenge *Challenge, flags []*SubFlag) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var existing []*SubFlag
+		if err := tx.Where("challenge_id = ?", challenge.ID).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		kept := map[uint]bool{}
+		for _, flag := range flags {
+			flag.ChallengeID = challenge.ID
+			for _, e := range existing {
+				if e.Name == flag.Name {
+					flag.Model = e.Model
+					flag.UUID = e.UUID
+					kept[e.ID] = true
+				}
+			}
+
+			if err := tx.Omit(clause.Associations).Save(flag).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&SubFlagSolve{}).Where("sub_flag_id = ?", flag.ID).Update("weight", flag.Weight).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, e := range existing {
+			if kept[e.ID] {
+				continue
+			}
+			if err := tx.Where("sub_flag_id = ?", e.ID).Delete(&SubFlagSolve{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(e).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CreateSubFlagSolve records the solve, fails if the team has found the sub flag
+func (s *Store) CreateSubFlagSolve(solve *SubFlagSolve) error {
+	return s.db.Omit(clause.Associations).Create(solve).Error
+}
+
+// GetFoundSubFlagIDs returns the IDs of the sub flags found by the team
+func (s *Store) GetFoundSubFlagIDs(team *Team) ([]uint, error) {
+	var ids []uint
+	err := s.db.Model(&SubFlagSolve{}).Where("team_id = ?", team.ID).Pluck("sub_flag_id", &ids).Error
+	return ids, err
+}
+
+// GetSubFlagSolvesByGame returns the solves of the existing sub flags in the game
+func (s *Store) GetSubFlagSolvesByGame(game *Game) ([]*SubFlagSolve, error) {
+	var solves []*SubFlagSolve
+	err := s.db.Preload("Team").Preload("Challenge").
+		Where("sub_flag_id IN (?)", s.db.Model(&SubFlag{}).Select("id").
+			Where("challenge_id IN (?)", s.db.Model(&Challenge{}).Select("id").Where("game_id = ?", game.ID))).
+		Order("solved_at ASC").Find(&solves).Error
+	return solves, err
+}
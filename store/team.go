@@ -14,7 +14,10 @@
 
 package store
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"rina.icu/hoshino/store/types"
+)
 
 type TeamError struct {
 	Msg string
@@ -33,6 +36,15 @@ func (e *TeamError) Error() string {
 	return e.Msg
 }
 
+type TeamStatus int
+
+const (
+	// teams created without approval are approved by default
+	TeamStatusApproved TeamStatus = iota
+	TeamStatusPending
+	TeamStatusRejected
+)
+
 type Team struct {
 	gorm.Model `json:"-"`
 
@@ -53,6 +65,18 @@ type Team struct {
 	// The managers and members of the team
 	Managers []*User `gorm:"many2many:team_managers;" json:"managers"`
 	Members  []*User `gorm:"many2many:team_members;" json:"members"`
+
+	// Registration status of the team
+	Status TeamStatus `gorm:"default:0" json:"status"`
+
+	// Answers to the registration questions of the game
+	Answers types.StringArray `gorm:"type:text" json:"answers" priv:"2"`
+
+	// Comment of the managers when reviewing the registration
+	ReviewComment string `json:"review_comment"`
+
+	// Does the team have an approved writeup
+	WriteupApproved bool `gorm:"default:false" json:"writeup_approved"`
 }
 
 func (s *Store) CreateTeam(t *Team) error {
@@ -63,6 +87,16 @@ func (s *Store) UpdateTeam(t *Team) error {
 	return s.db.Save(t).Error
 }
 
+func (t *Team) Approved() bool {
+	return t.Status == TeamStatusApproved
+}
+
+// CanPlay reports whether the team is able to see the challenges and submit flags,
+// the teams need to be approved only if the game requires registration approval
+func (t *Team) CanPlay(game *Game) bool {
+	return !game.RequireApproval || t.Approved()
+}
+
 func (t *Team) HasMember(user *User) bool {
 	for _, member := range t.Members {
 		if member.ID == user.ID {
@@ -117,14 +151,9 @@ func (s *Store) GetTeamByUUID(uuid string) (*Team, error) {
 	return &team, err
 }
 
-func (t *Team) GetTeamScore(s *Store) int {
-	var score int
-	s.db.Model(Flag{}).Where("team_id = ? AND state >= 1", t.ID).Select("sum(score)").Row().Scan(&score)
-	return score
-}
-
-func (t *Team) GetTeamRank(s *Store) int64 {
-	var rank int64
-	s.db.Model(&Team{}).Where("game_id = ? AND (SELECT sum(score) FROM flags WHERE team_id = teams.id AND state >= 1) > ?", t.GameID, t.GetTeamScore(s)).Count(&rank)
-	return rank + 1
+// GetTeamsByStatus returns the teams of the game in the registration status
+func (s *Store) GetTeamsByStatus(game *Game, status TeamStatus) ([]*Team, error) {
+	var teams []*Team
+	err := s.db.Preload("Creator").Preload("Members").Where("game_id = ? AND status = ?", game.ID, status).Find(&teams).Error
+	return teams, err
 }
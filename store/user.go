@@ -89,6 +89,12 @@ func (s *Store) GetUserByUsername(username string) (*User, error) {
 	return &user, err
 }
 
+func (s *Store) GetUserByUUID(uuid string) (*User, error) {
+	var user User
+	err := s.db.Model(&User{}).Where("uuid = ?", uuid).First(&user).Error
+	return &user, err
+}
+
 func (s *Store) GetUserByEmail(email string) (*User, error) {
 	var user User
 	err := s.db.Model(&User{}).Where("email = ?", email).First(&user).Error
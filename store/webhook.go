@@ -14,13 +14,160 @@
 
 package store
 
-import "gorm.io/gorm"
+import (
+	"slices"
+	"time"
 
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rina.icu/hoshino/store/types"
+)
+
+// Webhook receiving the events of a game
 type Webhook struct {
-	gorm.Model
+	gorm.Model `json:"-"`
+
+	UUID string `gorm:"unique" json:"uuid"`
+
+	// Game of the webhook
+	GameID uint  `gorm:"index" json:"-"`
+	Game   *Game `gorm:"foreignKey:GameID" json:"-"`
+
+	// URL receiving the payloads
+	URL string `json:"url"`
+
+	// Secret to sign the payloads with HMAC-SHA256
+	Secret string `json:"secret"`
+
+	// Names of the event types to deliver, empty for all
+	Events types.StringArray `gorm:"type:text" json:"events"`
+
+	Enabled bool `json:"enabled"`
+
+	// Creator of the webhook
+	OwnerID uint  `json:"-"`
+	Owner   *User `gorm:"foreignKey:OwnerID" json:"owner"`
+}
+
+type WebhookDeliveryStatus int
+
+const (
+	WebhookDeliveryPending WebhookDeliveryStatus = iota
+	WebhookDeliverySucceeded
+	WebhookDeliveryFailed
+)
+
+// Delivery of a payload to a webhook, pending deliveries form the delivery queue
+type WebhookDelivery struct {
+	gorm.Model `json:"-"`
+
+	UUID string `gorm:"unique" json:"uuid"`
+
+	WebhookID uint     `gorm:"index" json:"-"`
+	Webhook   *Webhook `gorm:"foreignKey:WebhookID" json:"-"`
+
+	// Name of the event type
+	Event string `json:"event"`
+
+	// Signed JSON body, the same on every attempt
+	Payload string `gorm:"type:text" json:"payload"`
+
+	Status WebhookDeliveryStatus `gorm:"index;default:0" json:"status"`
+
+	// Count of the attempts made
+	Attempts int `json:"attempts"`
+
+	// Time of the next attempt of a pending delivery
+	NextAttempt int64 `gorm:"index" json:"next_attempt"`
+
+	// HTTP status of the last attempt, 0 if no response
+	ResponseStatus int `json:"response_status"`
+
+	// Truncated response body or the error of the last attempt
+	Response string `gorm:"type:text" json:"response"`
+
+	CreatedTime   int64 `json:"created_time"`
+	DeliveredTime int64 `json:"delivered_time"`
+}
+
+// Accepts reports whether any of the names of the event is in the filter
+func (w *Webhook) Accepts(names ...string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, e := range w.Events {
+		if slices.Contains(names, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CreateWebhook(webhook *Webhook) error {
+	return s.db.Create(webhook).Error
+}
+
+func (s *Store) UpdateWebhook(webhook *Webhook) error {
+	return s.db.Save(webhook).Error
+}
+
+func (s *Store) DeleteWebhook(webhook *Webhook) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhook.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(webhook).Error
+	})
+}
+
+func (s *Store) GetWebhookByUUID(uuid string) (*Webhook, error) {
+	var webhook Webhook
+	err := s.db.Preload("Owner").Where("uuid = ?", uuid).First(&webhook).Error
+	return &webhook, err
+}
+
+func (s *Store) GetWebhooksByGame(game *Game) ([]*Webhook, error) {
+	var webhooks []*Webhook
+	err := s.db.Preload("Owner").Where("game_id = ?", game.ID).Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (s *Store) GetEnabledWebhooksByGameID(gameID uint) ([]*Webhook, error) {
+	var webhooks []*Webhook
+	err := s.db.Preload("Game").Where("game_id = ? AND enabled = ?", gameID, true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (s *Store) CreateWebhookDelivery(delivery *WebhookDelivery) error {
+	return s.db.Omit(clause.Associations).Create(delivery).Error
+}
+
+func (s *Store) UpdateWebhookDelivery(delivery *WebhookDelivery) error {
+	return s.db.Omit(clause.Associations).Save(delivery).Error
+}
+
+// GetDueWebhookDeliveries returns at most limit pending deliveries whose next attempt is due
+func (s *Store) GetDueWebhookDeliveries(limit int) ([]*WebhookDelivery, error) {
+	var deliveries []*WebhookDelivery
+	err := s.db.Preload("Webhook").
+		Where("status = ? AND next_attempt <= ?", WebhookDeliveryPending, time.Now().UnixMilli()).
+		Order("next_attempt").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+// GetWebhookDeliveries returns the delivery log of the webhook, latest first
+func (s *Store) GetWebhookDeliveries(webhook *Webhook, offset int, limit int) ([]*WebhookDelivery, int64, error) {
+	var deliveries []*WebhookDelivery
+	var total int64
 
-	URL    string `gorm:"unique"`
-	Secret string `gorm:"unique"`
+	query := s.db.Model(&WebhookDelivery{}).Where("webhook_id = ?", webhook.ID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 
-	Owner User `gorm:"foreignKey:ID;references:UserID"`
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&deliveries).Error
+	return deliveries, total, err
 }
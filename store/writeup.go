@@ -0,0 +1,105 @@
+// Copyright 2025 Rina
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WriteupStatus int
+
+const (
+	WriteupStatusPending WriteupStatus = iota
+	WriteupStatusApproved
+	WriteupStatusRejected
+)
+
+// Writeup of a team for a game or one of its challenges
+type Writeup struct {
+	gorm.Model `json:"-"`
+
+	UUID string `gorm:"unique" json:"uuid"`
+
+	// Game of the writeup
+	GameID uint  `gorm:"index" json:"-"`
+	Game   *Game `gorm:"foreignKey:GameID" json:"-"`
+
+	// Challenge of the writeup, 0 for the whole game
+	ChallengeID uint       `json:"-"`
+	Challenge   *Challenge `gorm:"foreignKey:ChallengeID" json:"-"`
+
+	// Team of the writeup
+	TeamID uint  `gorm:"index" json:"-"`
+	Team   *Team `gorm:"foreignKey:TeamID" json:"-"`
+
+	// The member who uploaded the writeup
+	UploaderID uint  `json:"-"`
+	Uploader   *User `gorm:"foreignKey:UploaderID" json:"uploader"`
+
+	// Path of the file, stored with the attachments
+	SavePath     string `json:"-"`
+	DownloadName string `json:"download_name"`
+	Size         int64  `json:"size"`
+
+	Status WriteupStatus `gorm:"default:0" json:"status"`
+
+	// Comment of the managers
+	Comment string `gorm:"type:text" json:"comment"`
+
+	SubmittedAt int64 `json:"submitted_at"`
+	ReviewedAt  int64 `json:"reviewed_at"`
+}
+
+func (s *Store) CreateWriteup(writeup *Writeup) error {
+	return s.db.Omit(clause.Associations).Create(writeup).Error
+}
+
+func (s *Store) UpdateWriteup(writeup *Writeup) error {
+	return s.db.Omit(clause.Associations).Save(writeup).Error
+}
+
+func (s *Store) GetWriteupByUUID(uuid string) (*Writeup, error) {
+	var writeup Writeup
+	err := s.db.Preload("Challenge").Preload("Team").Preload("Uploader").Where("uuid = ?", uuid).First(&writeup).Error
+	return &writeup, err
+}
+
+// GetWriteupByTeam returns the writeup of the team for the challenge, 0 for the whole game
+func (s *Store) GetWriteupByTeam(team *Team, challengeID uint) (*Writeup, error) {
+	var writeup Writeup
+	err := s.db.Where("team_id = ? AND challenge_id = ?", team.ID, challengeID).First(&writeup).Error
+	return &writeup, err
+}
+
+// GetWriteups returns the writeups of the game, filtered by the team if it's not nil
+func (s *Store) GetWriteups(game *Game, team *Team) ([]*Writeup, error) {
+	var writeups []*Writeup
+
+	query := s.db.Preload("Challenge").Preload("Team").Preload("Uploader").Where("game_id = ?", game.ID)
+	if team != nil {
+		query = query.Where("team_id = ?", team.ID)
+	}
+
+	err := query.Order("submitted_at DESC").Find(&writeups).Error
+	return writeups, err
+}
+
+// HasApprovedWriteup reports whether any writeup of the team is approved
+func (s *Store) HasApprovedWriteup(team *Team) bool {
+	var count int64
+	s.db.Model(&Writeup{}).Where("team_id = ? AND status = ?", team.ID, WriteupStatusApproved).Count(&count)
+	return count > 0
+}